@@ -5,10 +5,12 @@ import (
 	"database/sql"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"syscall"
 	"text/tabwriter"
@@ -20,7 +22,13 @@ import (
 )
 
 var addr = flag.String("http", "127.0.0.1:8080", "Address to serve HTTP")
-var dsn = flag.String("dsn", "test:test@tcp(127.0.0.1)/test?parseTime=true", "Database connection string (MariaDB)")
+var dsn = flag.String("dsn", "test:test@tcp(127.0.0.1)/test?parseTime=true", "Database connection string: a MariaDB DSN, a postgres:// URL, a sqlite:///path/todos.db file or a file:///path directory")
+var trashRetention = flag.Duration("trash-retention", 30*24*time.Hour, "How long deleted todos are kept in the trash. 0 keeps them forever")
+var purgeInterval = flag.Duration("purge-interval", time.Hour, "How often the trash is purged")
+var blobDir = flag.String("blob-dir", "", "Directory of the attachment files. Empty keeps them in memory, or in the blobs directory of the file storage")
+var attachmentLimit = flag.Int64("attachment-limit", 10<<20, "Maximum size of an attachment in bytes")
+var compactEvery = flag.Int("compact-every", 1000, "How many changes the file storage logs before it writes a snapshot")
+var undoDepth = flag.Int("undo-depth", 20, "How many operations of every client can be undone. 0 disables undo")
 
 func init() {
 	flag.Parse()
@@ -28,25 +36,86 @@ func init() {
 
 func Execute() {
 
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(flag.Args()[1:]); err != nil {
+			log.Fatalln(err)
+		}
+		return
+	}
+
 	var dbRepo todos.Repository
+	var history todos.HistoryRepository
+	var comments todos.CommentRepository
+	var attachments todos.AttachmentRepository
 	var conn *sql.DB
 	var err error
+	blobPath := *blobDir
 
 	if *dsn == "" {
 		dbRepo = todos.NewInMemoryRepository()
+		history = todos.NewInMemoryHistory()
+		comments = todos.NewInMemoryComments()
+		attachments = todos.NewInMemoryAttachments()
+	} else if dir, ok := strings.CutPrefix(*dsn, "file://"); ok {
+		if dbRepo, err = todos.NewFileRepository(dir, *compactEvery); err != nil {
+			log.Fatalln("Opening the file storage", err)
+		}
+		fmt.Println("Opened file storage")
+		// the file storage keeps the history, comments and attachments of the todo's in the same log
+		history = dbRepo.(todos.HistoryRepository)
+		comments = dbRepo.(todos.CommentRepository)
+		attachments = dbRepo.(todos.AttachmentRepository)
+		if blobPath == "" {
+			blobPath = filepath.Join(dir, "blobs")
+		}
 	} else {
 		conn, err = db.ConnWithRetry(db.Conn, 5, time.Second, time.Minute)(context.Background(), *dsn)
 		if err != nil {
 			log.Println("DB connection failed", err)
 			log.Println("Using in-memory storage")
 			dbRepo = todos.NewInMemoryRepository()
+			history = todos.NewInMemoryHistory()
+			comments = todos.NewInMemoryComments()
+			attachments = todos.NewInMemoryAttachments()
+		} else if err := migrateIfEnabled(); err != nil {
+			log.Fatalln("Migrating the schema", err)
+		} else if db.Driver(*dsn) == "sqlite" {
+			fmt.Println("Opened SQLite database")
+			dbRepo = todos.NewSqliteRepository(conn)
+			history = todos.NewSqliteHistory(conn)
+			comments = todos.NewSqliteComments(conn)
+			attachments = todos.NewSqliteAttachments(conn)
+		} else if db.Driver(*dsn) == "postgres" {
+			fmt.Println("Connected to PostgreSQL")
+			dbRepo = todos.NewPostgresRepository(conn)
+			history = todos.NewPostgresHistory(conn)
+			comments = todos.NewPostgresComments(conn)
+			attachments = todos.NewPostgresAttachments(conn)
 		} else {
 			fmt.Println("Connected to database")
 			dbRepo = todos.NewDbRepository(conn)
+			history = todos.NewDbHistory(conn)
+			comments = todos.NewDbComments(conn)
+			attachments = todos.NewDbAttachments(conn)
 		}
 	}
 
-	svc := todos.NewService(todos.WithRepo(dbRepo))
+	var blobs todos.BlobStore
+	if blobPath == "" {
+		blobs = todos.NewInMemoryBlobStore()
+	} else if blobs, err = todos.NewFileBlobStore(blobPath); err != nil {
+		log.Fatalln("Opening the blob store", err)
+	}
+
+	svc := todos.NewService(todos.WithRepo(dbRepo), todos.WithHistory(history), todos.WithComments(comments),
+		todos.WithAttachments(attachments, blobs, *attachmentLimit), todos.WithUndo(*undoDepth))
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+
+	if *trashRetention > 0 {
+		go todos.PurgeTrash(ctx, svc, *trashRetention, *purgeInterval)
+	}
 
 	srvr := http.Server{
 		Addr:              *addr,
@@ -66,6 +135,7 @@ func Execute() {
 		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
 
 		<-ch
+		stop()
 
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
@@ -79,6 +149,10 @@ func Execute() {
 			fmt.Println("Closing DB...")
 			conn.Close()
 		}
+		if c, ok := dbRepo.(io.Closer); ok {
+			fmt.Println("Closing file storage...")
+			c.Close()
+		}
 	}()
 
 	fmt.Printf("Listening on %s\n", *addr)
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mehix/go-todos/internal/db"
+	"github.com/mehix/go-todos/internal/migrate"
+)
+
+var migrateOnStart = flag.Bool("migrate", true, "Apply the pending schema migrations at startup")
+
+const migrateUsage = `Usage: todos [flags] migrate up|down|status|baseline [-steps N] [-dry-run] [version]
+
+  up        applies the pending migrations
+  down      reverts the last migration
+  status    lists the migrations and when they were applied
+  baseline  records the migrations up to the version as applied, without running them
+`
+
+// runMigrate runs the migrate subcommand with its arguments
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	steps := fs.Int("steps", 0, "How many migrations to apply or revert. 0 is all for up and 1 for down")
+	dryRun := fs.Bool("dry-run", false, "Print the migrations instead of running them")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return fmt.Errorf("missing migrate command")
+	}
+	cmd := args[0]
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	m, closeDB, err := migrator(context.Background())
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+	m.DryRun = *dryRun
+	m.Log = os.Stdout
+
+	ctx := context.Background()
+
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx, *steps)
+		fmt.Printf("%d migrations %s\n", n, done(*dryRun, "applied"))
+		return err
+
+	case "down":
+		if *steps == 0 {
+			*steps = 1
+		}
+		n, err := m.Down(ctx, *steps)
+		fmt.Printf("%d migrations %s\n", n, done(*dryRun, "reverted"))
+		return err
+
+	case "status":
+		all, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		out := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(out, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range all {
+			at := "pending"
+			if st.AppliedAt != nil {
+				at = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d\t%s\t%s\n", st.Version, st.Name, at)
+		}
+		return out.Flush()
+
+	case "baseline":
+		version, err := strconv.Atoi(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("baseline needs the version of the schema: %w", err)
+		}
+		return m.Baseline(ctx, version)
+	}
+
+	fs.Usage()
+	return fmt.Errorf("unknown migrate command: %s", cmd)
+}
+
+func done(dryRun bool, verb string) string {
+	if dryRun {
+		return "would be " + verb
+	}
+	return verb
+}
+
+// migrator connects to the database of the dsn flag for the migrations
+func migrator(ctx context.Context) (*migrate.Migrator, func(), error) {
+	if *dsn == "" {
+		return nil, nil, fmt.Errorf("the in-memory storage has no migrations")
+	}
+	if strings.HasPrefix(*dsn, "file://") {
+		return nil, nil, fmt.Errorf("the file storage has no migrations")
+	}
+
+	migrations, err := migrate.Embedded(db.Driver(*dsn))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	multi, err := db.MultiStatements(*dsn)
+	if err != nil {
+		return nil, nil, err
+	}
+	conn, err := db.ConnWithRetry(db.Conn, 5, time.Second, time.Minute)(ctx, multi)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return migrate.New(conn, db.Driver(*dsn), migrations), func() { conn.Close() }, nil
+}
+
+// migrateIfEnabled applies the pending migrations before the application uses the database
+func migrateIfEnabled() error {
+	if !*migrateOnStart {
+		return nil
+	}
+
+	ctx := context.Background()
+	m, closeDB, err := migrator(ctx)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	n, err := m.Up(ctx, 0)
+	if n > 0 {
+		fmt.Printf("Applied %d schema migrations\n", n)
+	}
+
+	return err
+}
@@ -0,0 +1,10 @@
+// Package database keeps the schema migrations of the databases, which are built into the application
+package database
+
+import "embed"
+
+// Migrations has a directory of migrations for every driver: mysql, postgres and sqlite.
+// The files are named <version>_<name>.up.sql and <version>_<name>.down.sql.
+//
+//go:embed migrations
+var Migrations embed.FS
@@ -0,0 +1,3 @@
+drop view v_todos;
+
+drop table todos;
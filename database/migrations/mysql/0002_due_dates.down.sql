@@ -0,0 +1,13 @@
+create or replace view v_todos as
+    select id, title, tags, completed_at
+    from todos
+;
+
+drop index todos_due_at_idx on todos;
+drop index todos_remind_at_idx on todos;
+
+alter table todos
+    drop column due_at,
+    drop column due_tz,
+    drop column remind_at,
+    drop column remind_tz;
@@ -0,0 +1,13 @@
+alter table todos
+    add column due_at TIMESTAMP NULL DEFAULT NULL after completed_at,
+    add column due_tz varchar(6) not null default '' after due_at,
+    add column remind_at TIMESTAMP NULL DEFAULT NULL after due_tz,
+    add column remind_tz varchar(6) not null default '' after remind_at;
+
+create index todos_due_at_idx on todos (due_at);
+create index todos_remind_at_idx on todos (remind_at);
+
+create or replace view v_todos as
+    select id, title, tags, completed_at, due_at, due_tz, remind_at, remind_tz
+    from todos
+;
@@ -0,0 +1,8 @@
+create or replace view v_todos as
+    select id, title, tags, completed_at, due_at, due_tz, remind_at, remind_tz
+    from todos
+;
+
+drop index todos_priority_idx on todos;
+
+alter table todos drop column priority;
@@ -0,0 +1,9 @@
+alter table todos
+    add column priority tinyint not null default 0 after tags;
+
+create index todos_priority_idx on todos (priority);
+
+create or replace view v_todos as
+    select id, title, tags, priority, completed_at, due_at, due_tz, remind_at, remind_tz
+    from todos
+;
@@ -0,0 +1,17 @@
+alter table todos
+    add column tags varchar(1500) not null default '' after title;
+
+-- join the tags back into the comma separated column
+update todos t set tags = coalesce((
+    select group_concat(tg.name order by tg.name separator ',')
+    from todo_tags tt join tags tg on tg.id = tt.tag_id
+    where tt.todo_id = t.id
+), '');
+
+create or replace view v_todos as
+    select id, title, tags, priority, completed_at, due_at, due_tz, remind_at, remind_tz
+    from todos
+;
+
+drop table todo_tags;
+drop table tags;
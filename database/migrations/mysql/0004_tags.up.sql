@@ -0,0 +1,48 @@
+create table tags (
+    id int not null auto_increment,
+    name varchar(150) not null,
+    primary key tags_pk(id),
+    unique key tags_name_uq(name)
+);
+
+create table todo_tags (
+    todo_id varchar(36) not null,
+    tag_id int not null,
+    primary key todo_tags_pk(todo_id, tag_id),
+    key todo_tags_tag_idx(tag_id),
+    constraint todo_tags_todo_fk foreign key (todo_id) references todos(id) on delete cascade,
+    constraint todo_tags_tag_fk foreign key (tag_id) references tags(id) on delete cascade
+);
+
+-- split the comma separated tags of the existing todos
+create temporary table todo_tags_split (
+    todo_id varchar(36) not null,
+    name varchar(150) not null
+);
+
+insert into todo_tags_split (todo_id, name)
+with recursive split (todo_id, tag, rest) as (
+    select id, substring_index(tags, ',', 1), substring(tags, char_length(substring_index(tags, ',', 1)) + 2)
+    from todos
+    where tags <> ''
+    union all
+    select todo_id, substring_index(rest, ',', 1), substring(rest, char_length(substring_index(rest, ',', 1)) + 2)
+    from split
+    where rest <> ''
+)
+select todo_id, lower(trim(tag)) from split where trim(tag) <> '';
+
+insert ignore into tags (name)
+    select distinct name from todo_tags_split;
+
+insert ignore into todo_tags (todo_id, tag_id)
+    select s.todo_id, t.id from todo_tags_split s join tags t on t.name = s.name;
+
+drop temporary table todo_tags_split;
+
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz
+    from todos
+;
+
+alter table todos drop column tags;
@@ -0,0 +1,6 @@
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz
+    from todos
+;
+
+alter table todos drop column version;
@@ -0,0 +1,7 @@
+alter table todos
+    add column version int not null default 1 after remind_tz;
+
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version
+    from todos
+;
@@ -0,0 +1,10 @@
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version
+    from todos
+;
+
+alter table todos
+    drop foreign key todos_list_fk,
+    drop column list_id;
+
+drop table lists;
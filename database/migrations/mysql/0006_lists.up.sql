@@ -0,0 +1,15 @@
+create table lists (
+    id varchar(36) not null primary key,
+    name varchar(255) not null,
+    archived_at timestamp null default null,
+    inserted_at timestamp not null default current_timestamp
+);
+
+alter table todos
+    add column list_id varchar(36) null default null after title,
+    add constraint todos_list_fk foreign key (list_id) references lists (id) on delete cascade;
+
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id
+    from todos
+;
@@ -0,0 +1,8 @@
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id
+    from todos
+;
+
+alter table todos
+    drop foreign key todos_parent_fk,
+    drop column parent_id;
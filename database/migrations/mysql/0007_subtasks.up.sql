@@ -0,0 +1,8 @@
+alter table todos
+    add column parent_id varchar(36) null default null after list_id,
+    add constraint todos_parent_fk foreign key (parent_id) references todos (id) on delete cascade;
+
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id
+    from todos
+;
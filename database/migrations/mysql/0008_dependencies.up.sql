@@ -0,0 +1,8 @@
+create table todo_dependencies (
+    todo_id varchar(36) not null,
+    blocker_id varchar(36) not null,
+    primary key todo_dependencies_pk(todo_id, blocker_id),
+    key todo_dependencies_blocker_idx(blocker_id),
+    constraint todo_dependencies_todo_fk foreign key (todo_id) references todos(id) on delete cascade,
+    constraint todo_dependencies_blocker_fk foreign key (blocker_id) references todos(id) on delete cascade
+);
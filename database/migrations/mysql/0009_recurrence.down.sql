@@ -0,0 +1,10 @@
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id
+    from todos
+;
+
+alter table todos
+    drop key todos_series_idx,
+    drop column recurrence,
+    drop column series_id,
+    drop column occurrence;
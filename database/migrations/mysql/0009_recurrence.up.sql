@@ -0,0 +1,11 @@
+alter table todos
+    add column recurrence varchar(255) null default null after remind_tz,
+    add column series_id varchar(36) null default null after recurrence,
+    add column occurrence int not null default 0 after series_id,
+    add key todos_series_idx(series_id);
+
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence
+    from todos
+;
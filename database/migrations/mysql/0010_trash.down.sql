@@ -0,0 +1,14 @@
+-- the todos in the trash are removed for good
+delete from todos where deleted_at is not null;
+
+drop view v_trash;
+
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence
+    from todos
+;
+
+alter table todos
+    drop key todos_deleted_idx,
+    drop column deleted_at;
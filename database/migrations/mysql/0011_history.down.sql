@@ -0,0 +1 @@
+drop table todo_events;
@@ -0,0 +1,11 @@
+-- append-only: the events are kept when the todo is purged
+create table todo_events (
+    id bigint not null auto_increment,
+    todo_id varchar(36) not null,
+    action varchar(20) not null,
+    actor varchar(255) not null default '',
+    at timestamp(6) not null default current_timestamp(6),
+    changes longtext not null,
+    primary key todo_events_pk(id),
+    key todo_events_todo_idx(todo_id)
+);
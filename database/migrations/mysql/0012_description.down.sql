@@ -0,0 +1,17 @@
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence, deleted_at
+    from todos
+    where deleted_at is null
+;
+
+create or replace view v_trash as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence, deleted_at
+    from todos
+    where deleted_at is not null
+;
+
+alter table todos
+    drop column description,
+    drop column checklist;
@@ -0,0 +1,18 @@
+-- the checklist items are kept as a JSON array, in order
+alter table todos
+    add column description text not null default '' after title,
+    add column checklist longtext null default null after description;
+
+create or replace view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence, deleted_at, description, checklist
+    from todos
+    where deleted_at is null
+;
+
+create or replace view v_trash as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence, deleted_at, description, checklist
+    from todos
+    where deleted_at is not null
+;
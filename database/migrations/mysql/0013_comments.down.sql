@@ -0,0 +1,2 @@
+drop table comment_edits;
+drop table comments;
@@ -0,0 +1,23 @@
+create table comments (
+    id varchar(36) not null,
+    todo_id varchar(36) not null,
+    author varchar(255) not null default '',
+    body text not null,
+    created_at timestamp(6) not null default current_timestamp(6),
+    updated_at timestamp(6) null default null,
+    -- set when the comment is trashed with its todo
+    deleted_at timestamp(6) null default null,
+    primary key comments_pk(id),
+    key comments_todo_idx(todo_id),
+    constraint comments_todo_fk foreign key (todo_id) references todos(id) on delete cascade
+);
+
+create table comment_edits (
+    id bigint not null auto_increment,
+    comment_id varchar(36) not null,
+    body text not null,
+    edited_at timestamp(6) not null,
+    primary key comment_edits_pk(id),
+    key comment_edits_comment_idx(comment_id),
+    constraint comment_edits_comment_fk foreign key (comment_id) references comments(id) on delete cascade
+);
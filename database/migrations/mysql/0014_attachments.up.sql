@@ -0,0 +1,14 @@
+-- the content is kept in the blob store under its sha256. There is no foreign key to the todos:
+-- the attachments of the purged todos are removed by the service, with their content.
+create table attachments (
+    id varchar(36) not null,
+    todo_id varchar(36) not null,
+    name varchar(255) not null,
+    content_type varchar(255) not null,
+    size bigint not null,
+    sha256 char(64) not null,
+    created_at timestamp(6) not null default current_timestamp(6),
+    primary key attachments_pk(id),
+    key attachments_todo_idx(todo_id),
+    key attachments_sha256_idx(sha256)
+);
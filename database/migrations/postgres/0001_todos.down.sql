@@ -0,0 +1,12 @@
+drop table attachments;
+drop table comment_edits;
+drop table comments;
+drop table todo_events;
+drop table todo_dependencies;
+drop table todo_tags;
+drop table tags;
+drop view v_trash;
+drop view v_todos;
+drop table todos;
+drop function todos_row_update;
+drop table lists;
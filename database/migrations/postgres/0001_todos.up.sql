@@ -0,0 +1,135 @@
+-- The schema of the MariaDB migrations 0001 to 0014 for PostgreSQL.
+-- The times are timestamptz; the zones of the due and remind times are kept in due_tz and remind_tz.
+
+create table lists (
+    id varchar(36) not null primary key,
+    name varchar(255) not null,
+    archived_at timestamptz null default null,
+    inserted_at timestamptz not null default current_timestamp
+);
+
+create table todos (
+    id varchar(36) not null primary key,
+    title varchar(150) not null,
+    description text not null default '',
+    -- the checklist items are kept as a JSON array, in order
+    checklist text null default null,
+    list_id varchar(36) null default null references lists (id) on delete cascade,
+    parent_id varchar(36) null default null references todos (id) on delete cascade,
+    priority smallint not null default 0,
+    completed_at timestamptz null default null,
+    due_at timestamptz null default null,
+    due_tz varchar(6) not null default '',
+    remind_at timestamptz null default null,
+    remind_tz varchar(6) not null default '',
+    recurrence varchar(255) null default null,
+    series_id varchar(36) null default null,
+    occurrence int not null default 0,
+    version int not null default 1,
+    deleted_at timestamptz null default null,
+    inserted_at timestamptz not null default current_timestamp,
+    updated_at timestamptz null default null
+);
+
+create index todos_priority_idx on todos (priority);
+create index todos_due_at_idx on todos (due_at);
+create index todos_remind_at_idx on todos (remind_at);
+create index todos_list_idx on todos (list_id);
+create index todos_parent_idx on todos (parent_id);
+create index todos_series_idx on todos (series_id);
+create index todos_deleted_idx on todos (deleted_at);
+
+create function todos_row_update() returns trigger as $$
+begin
+    new.updated_at = current_timestamp;
+    return new;
+end;
+$$ language plpgsql;
+
+create trigger todos_row_update before update on todos
+    for each row execute function todos_row_update();
+
+create view v_todos as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence, deleted_at, description, checklist
+    from todos
+    where deleted_at is null
+;
+
+create view v_trash as
+    select id, title, priority, completed_at, due_at, due_tz, remind_at, remind_tz, version, list_id, parent_id,
+        recurrence, series_id, occurrence, deleted_at, description, checklist
+    from todos
+    where deleted_at is not null
+;
+
+create table tags (
+    id serial primary key,
+    name varchar(150) not null,
+    constraint tags_name_uq unique (name)
+);
+
+create table todo_tags (
+    todo_id varchar(36) not null references todos (id) on delete cascade,
+    tag_id int not null references tags (id) on delete cascade,
+    primary key (todo_id, tag_id)
+);
+
+create index todo_tags_tag_idx on todo_tags (tag_id);
+
+create table todo_dependencies (
+    todo_id varchar(36) not null references todos (id) on delete cascade,
+    blocker_id varchar(36) not null references todos (id) on delete cascade,
+    primary key (todo_id, blocker_id)
+);
+
+create index todo_dependencies_blocker_idx on todo_dependencies (blocker_id);
+
+-- append-only: the events are kept when the todo is purged
+create table todo_events (
+    id bigserial primary key,
+    todo_id varchar(36) not null,
+    action varchar(20) not null,
+    actor varchar(255) not null default '',
+    at timestamptz not null default current_timestamp,
+    changes text not null
+);
+
+create index todo_events_todo_idx on todo_events (todo_id);
+
+create table comments (
+    id varchar(36) not null primary key,
+    todo_id varchar(36) not null references todos (id) on delete cascade,
+    author varchar(255) not null default '',
+    body text not null,
+    created_at timestamptz not null default current_timestamp,
+    updated_at timestamptz null default null,
+    -- set when the comment is trashed with its todo
+    deleted_at timestamptz null default null
+);
+
+create index comments_todo_idx on comments (todo_id);
+
+create table comment_edits (
+    id bigserial primary key,
+    comment_id varchar(36) not null references comments (id) on delete cascade,
+    body text not null,
+    edited_at timestamptz not null
+);
+
+create index comment_edits_comment_idx on comment_edits (comment_id);
+
+-- the content is kept in the blob store under its sha256. There is no foreign key to the todos:
+-- the attachments of the purged todos are removed by the service, with their content.
+create table attachments (
+    id varchar(36) not null primary key,
+    todo_id varchar(36) not null,
+    name varchar(255) not null,
+    content_type varchar(255) not null,
+    size bigint not null,
+    sha256 char(64) not null,
+    created_at timestamptz not null default current_timestamp
+);
+
+create index attachments_todo_idx on attachments (todo_id);
+create index attachments_sha256_idx on attachments (sha256);
@@ -5,13 +5,63 @@ import (
 	"database/sql"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
+// Driver returns the driver of the connection string: postgres:// and postgresql:// URLs are PostgreSQL,
+// sqlite:// URLs are SQLite files, anything else is a MySQL/MariaDB DSN
+func Driver(dsn string) string {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return "postgres"
+	case strings.HasPrefix(dsn, "sqlite://"):
+		return "sqlite"
+	}
+
+	return "mysql"
+}
+
+// sqliteDSN turns sqlite:///path/todos.db into the name of the file for the driver.
+// The foreign keys are enforced and the transactions lock the database when they start,
+// so concurrent transactions wait for each other instead of failing.
+func sqliteDSN(dsn string) string {
+	path, params, _ := strings.Cut(strings.TrimPrefix(dsn, "sqlite://"), "?")
+	if params != "" {
+		params += "&"
+	}
+
+	return "file:" + path + "?" + params +
+		"_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(wal)&_txlock=immediate&_time_format=sqlite"
+}
+
+// MultiStatements allows a query to run several statements, like the migrations do.
+// Only MySQL/MariaDB needs to be told.
+func MultiStatements(dsn string) (string, error) {
+	if Driver(dsn) != "mysql" {
+		return dsn, nil
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	cfg.MultiStatements = true
+
+	return cfg.FormatDSN(), nil
+}
+
 func Conn(ctx context.Context, dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+	driver := Driver(dsn)
+	if driver == "sqlite" {
+		dsn = sqliteDSN(dsn)
+	}
+
+	db, err := sql.Open(driver, dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,387 @@
+// Package migrate applies the versioned schema migrations of the database and keeps track of them
+// in the schema_migrations table
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/mehix/go-todos/database"
+	"golang.org/x/exp/slices"
+)
+
+// Migration changes the schema from the previous version to its version, and back
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	// Down is empty when the migration cannot be reverted
+	Down string
+}
+
+// Status is a migration with the time it was applied. AppliedAt is nil for a pending migration.
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+var fileName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the migrations of the directory, sorted by version
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, f := range files {
+		m := fileName.FindStringSubmatch(f.Name())
+		if m == nil {
+			continue
+		}
+
+		version, err := strconv.Atoi(m[1])
+		if err != nil || version == 0 {
+			return nil, fmt.Errorf("invalid migration version: %s", f.Name())
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mg
+		}
+		if mg.Name != m[2] {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", mg.Name, m[2])
+		}
+
+		if m[3] == "up" {
+			mg.Up = string(b)
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	all := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up file", mg.Version, mg.Name)
+		}
+		all = append(all, *mg)
+	}
+	slices.SortFunc(all, func(a, b Migration) bool { return a.Version < b.Version })
+
+	return all, nil
+}
+
+// Embedded returns the migrations of the driver that are built into the application
+func Embedded(driver string) ([]Migration, error) {
+	return Load(database.Migrations, "migrations/"+driver)
+}
+
+// Migrator applies the migrations on a database. The queries of a migration can have several statements;
+// for MySQL/MariaDB the connection must allow it (see db.MultiStatements).
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+
+	// DryRun prints the migrations that would run, without running or recording them
+	DryRun bool
+	// Log receives a line for every migration that is applied or reverted, and the SQL in a dry run
+	Log io.Writer
+}
+
+func New(db *sql.DB, driver string, migrations []Migration) *Migrator {
+	return &Migrator{db: db, driver: driver, migrations: migrations, Log: io.Discard}
+}
+
+// Up applies the pending migrations, at most `steps` of them. 0 applies all of them.
+// It returns the number of migrations that were (or in a dry run would be) applied.
+func (m *Migrator) Up(ctx context.Context, steps int) (int, error) {
+	n := 0
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int]time.Time) error {
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok {
+				continue
+			}
+			if steps > 0 && n == steps {
+				break
+			}
+
+			if err := m.run(ctx, conn, mg, mg.Up, "up"); err != nil {
+				return err
+			}
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Down reverts the last applied migrations, at most `steps` of them. 0 reverts all of them.
+// It returns the number of migrations that were (or in a dry run would be) reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	n := 0
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int]time.Time) error {
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mg := m.migrations[i]
+			if _, ok := applied[mg.Version]; !ok {
+				continue
+			}
+			if steps > 0 && n == steps {
+				break
+			}
+			if mg.Down == "" {
+				return fmt.Errorf("migration %04d_%s cannot be reverted", mg.Version, mg.Name)
+			}
+
+			if err := m.run(ctx, conn, mg, mg.Down, "down"); err != nil {
+				return err
+			}
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Baseline records the migrations up to the version as applied, without running them.
+// It is meant for the databases that got their schema before the migrations were tracked.
+func (m *Migrator) Baseline(ctx context.Context, version int) error {
+	return m.locked(ctx, func(conn *sql.Conn, applied map[int]time.Time) error {
+		for _, mg := range m.migrations {
+			if _, ok := applied[mg.Version]; ok || mg.Version > version {
+				continue
+			}
+
+			fmt.Fprintf(m.Log, "baseline %04d_%s\n", mg.Version, mg.Name)
+			if m.DryRun {
+				continue
+			}
+			if err := m.record(ctx, conn, mg, "up"); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Status returns all the migrations, with the applied ones that are not known anymore
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var all []Status
+	err := m.locked(ctx, func(conn *sql.Conn, applied map[int]time.Time) error {
+		for _, mg := range m.migrations {
+			st := Status{Migration: mg}
+			if at, ok := applied[mg.Version]; ok {
+				st.AppliedAt = &at
+				delete(applied, mg.Version)
+			}
+			all = append(all, st)
+		}
+
+		for version, at := range applied {
+			at := at
+			all = append(all, Status{Migration: Migration{Version: version, Name: "unknown"}, AppliedAt: &at})
+		}
+		slices.SortFunc(all, func(a, b Status) bool { return a.Version < b.Version })
+
+		return nil
+	})
+
+	return all, err
+}
+
+// run applies the SQL of the migration and records it. PostgreSQL runs both in a transaction,
+// SQLite runs all the migrations in the transaction that locks the database. MySQL/MariaDB
+// cannot roll back the changes of the schema: a failed migration must be fixed by hand.
+func (m *Migrator) run(ctx context.Context, conn *sql.Conn, mg Migration, query, direction string) error {
+	fmt.Fprintf(m.Log, "%s %04d_%s\n", direction, mg.Version, mg.Name)
+	if m.DryRun {
+		fmt.Fprintln(m.Log, query)
+		return nil
+	}
+
+	if m.driver != "postgres" {
+		if _, err := conn.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("migration %04d_%s %s: %w", mg.Version, mg.Name, direction, err)
+		}
+		return m.record(ctx, conn, mg, direction)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, query); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migration %04d_%s %s: %w", mg.Version, mg.Name, direction, err)
+	}
+	if err := m.record(ctx, tx, mg, direction); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func (m *Migrator) record(ctx context.Context, db execer, mg Migration, direction string) error {
+	var err error
+	if direction == "up" {
+		qry := "insert into schema_migrations (version, name, applied_at) values (" + m.placeholders(3) + ")"
+		_, err = db.ExecContext(ctx, qry, mg.Version, mg.Name, time.Now().UTC())
+	} else {
+		_, err = db.ExecContext(ctx, "delete from schema_migrations where version = "+m.placeholders(1), mg.Version)
+	}
+
+	return err
+}
+
+func (m *Migrator) placeholders(n int) string {
+	s := ""
+	for i := 1; i <= n; i++ {
+		if i > 1 {
+			s += ", "
+		}
+		if m.driver == "postgres" {
+			s += "$" + strconv.Itoa(i)
+		} else {
+			s += "?"
+		}
+	}
+
+	return s
+}
+
+// locked runs f while it holds the lock of the migrations, so concurrent instances apply them once.
+// The schema_migrations table is created when it does not exist.
+func (m *Migrator) locked(ctx context.Context, f func(*sql.Conn, map[int]time.Time) error) (err error) {
+	// the locks belong to the connection, so everything runs on the same one
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	unlock, err := m.lock(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if uerr := unlock(err == nil); err == nil {
+			err = uerr
+		}
+	}()
+
+	qry := `create table if not exists schema_migrations (
+		version bigint not null primary key,
+		name varchar(255) not null,
+		applied_at timestamp not null default current_timestamp
+	)`
+	if _, err := conn.ExecContext(ctx, qry); err != nil {
+		return err
+	}
+
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	return f(conn, applied)
+}
+
+// lockName is the name of the MySQL lock; lockKey is the key of the PostgreSQL advisory lock
+const (
+	lockName = "go-todos.schema_migrations"
+	lockKey  = 7_034_123_416_901_812_345
+)
+
+// lockTimeout is how long an instance waits for another one to finish its migrations
+const lockTimeout = 10 * time.Minute
+
+// lock takes the lock of the migrations. unlock releases it; for SQLite it commits or rolls back the migrations.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (unlock func(commit bool) error, err error) {
+	switch m.driver {
+	case "mysql":
+		var ok sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "select get_lock(?, ?)", lockName, int(lockTimeout.Seconds())).Scan(&ok); err != nil {
+			return nil, err
+		}
+		if ok.Int64 != 1 {
+			return nil, errors.New("timeout waiting for the lock of the migrations")
+		}
+		return func(bool) error {
+			_, err := conn.ExecContext(context.Background(), "do release_lock(?)", lockName)
+			return err
+		}, nil
+
+	case "postgres":
+		lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+		defer cancel()
+		if _, err := conn.ExecContext(lockCtx, "select pg_advisory_lock($1)", int64(lockKey)); err != nil {
+			return nil, err
+		}
+		return func(bool) error {
+			_, err := conn.ExecContext(context.Background(), "select pg_advisory_unlock($1)", int64(lockKey))
+			return err
+		}, nil
+
+	case "sqlite":
+		// a write transaction locks the whole database, and SQLite can roll back the changes of the schema
+		if _, err := conn.ExecContext(ctx, "begin immediate"); err != nil {
+			return nil, err
+		}
+		return func(commit bool) error {
+			qry := "rollback"
+			if commit {
+				qry = "commit"
+			}
+			_, err := conn.ExecContext(context.Background(), qry)
+			return err
+		}, nil
+	}
+
+	return nil, fmt.Errorf("migrations are not supported for %s", m.driver)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at.UTC()
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,185 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mehix/go-todos/internal/db"
+	"golang.org/x/sync/errgroup"
+)
+
+var testMigrations = fstest.MapFS{
+	"m/0001_notes.up.sql":     {Data: []byte("create table notes (id int primary key);\ncreate index notes_idx on notes (id);")},
+	"m/0001_notes.down.sql":   {Data: []byte("drop table notes;")},
+	"m/0002_authors.up.sql":   {Data: []byte("create table authors (id int primary key);")},
+	"m/0002_authors.down.sql": {Data: []byte("drop table authors;")},
+	"m/0003_seed.up.sql":      {Data: []byte("insert into notes (id) values (1);")},
+	"m/README.md":             {Data: []byte("not a migration")},
+}
+
+func sqliteDB(t *testing.T) *sql.DB {
+	conn, err := db.Conn(context.Background(), "sqlite://"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+func tables(t *testing.T, conn *sql.DB) string {
+	rows, err := conn.Query("select name from sqlite_master where type = 'table' and name <> 'schema_migrations' order by name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var all []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, name)
+	}
+
+	return strings.Join(all, ",")
+}
+
+func TestLoad(t *testing.T) {
+	all, err := Load(testMigrations, "m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].Name != "notes" || all[2].Version != 3 || all[2].Down != "" {
+		t.Fatalf("wrong migrations: %+v", all)
+	}
+
+	dup := fstest.MapFS{
+		"m/0001_a.up.sql": {Data: []byte("select 1;")},
+		"m/0001_b.up.sql": {Data: []byte("select 1;")},
+	}
+	if _, err := Load(dup, "m"); err == nil {
+		t.Fatal("migrations with the same version were loaded")
+	}
+
+	for _, driver := range []string{"mysql", "postgres", "sqlite"} {
+		if all, err := Embedded(driver); err != nil || len(all) == 0 {
+			t.Fatalf("no embedded migrations for %s: %v", driver, err)
+		}
+	}
+}
+
+func TestUpDown(t *testing.T) {
+	ctx := context.Background()
+	conn := sqliteDB(t)
+	all, _ := Load(testMigrations, "m")
+	m := New(conn, "sqlite", all)
+
+	var log bytes.Buffer
+	m.Log = &log
+	m.DryRun = true
+	if n, err := m.Up(ctx, 0); err != nil || n != 3 {
+		t.Fatalf("wrong dry run: %d, %v", n, err)
+	}
+	if got := tables(t, conn); got != "" {
+		t.Fatalf("the dry run changed the schema: %s", got)
+	}
+	if !strings.Contains(log.String(), "create table authors") {
+		t.Fatalf("the dry run did not print the SQL: %s", log.String())
+	}
+
+	m.DryRun = false
+	if n, err := m.Up(ctx, 2); err != nil || n != 2 {
+		t.Fatalf("wrong up: %d, %v", n, err)
+	}
+	if got := tables(t, conn); got != "authors,notes" {
+		t.Fatalf("wrong tables: %s", got)
+	}
+
+	st, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st) != 3 || st[1].AppliedAt == nil || st[2].AppliedAt != nil {
+		t.Fatalf("wrong status: %+v", st)
+	}
+
+	if n, err := m.Up(ctx, 0); err != nil || n != 1 {
+		t.Fatalf("wrong up of the rest: %d, %v", n, err)
+	}
+	if _, err := m.Down(ctx, 1); err == nil {
+		t.Fatal("a migration without down was reverted")
+	}
+
+	// the failed down is rolled back as a whole
+	if got := tables(t, conn); got != "authors,notes" {
+		t.Fatalf("wrong tables after a failed down: %s", got)
+	}
+}
+
+func TestDownAndBaseline(t *testing.T) {
+	ctx := context.Background()
+	conn := sqliteDB(t)
+	all, _ := Load(testMigrations, "m")
+	m := New(conn, "sqlite", all[:2])
+
+	if _, err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.Down(ctx, 0); err != nil || n != 2 {
+		t.Fatalf("wrong down: %d, %v", n, err)
+	}
+	if got := tables(t, conn); got != "" {
+		t.Fatalf("tables left after down: %s", got)
+	}
+
+	if err := m.Baseline(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.Up(ctx, 0); err != nil || n != 1 {
+		t.Fatalf("wrong up after the baseline: %d, %v", n, err)
+	}
+	if got := tables(t, conn); got != "authors" {
+		t.Fatalf("the baseline migration was applied: %s", got)
+	}
+}
+
+func TestConcurrentUp(t *testing.T) {
+	ctx := context.Background()
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+	all, _ := Load(testMigrations, "m")
+
+	// every instance has its own connections, like separate processes
+	var g errgroup.Group
+	applied := make([]int, 4)
+	for i := range applied {
+		i := i
+		g.Go(func() error {
+			conn, err := db.Conn(ctx, dsn)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			applied[i], err = New(conn, "sqlite", all).Up(ctx, 0)
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	total := 0
+	for _, n := range applied {
+		total += n
+	}
+	if total != 3 {
+		t.Fatalf("the migrations were applied %d times: %v", total, applied)
+	}
+}
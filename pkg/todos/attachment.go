@@ -0,0 +1,70 @@
+package todos
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Attachment is a file attached to a todo. The content is kept in a BlobStore under its SHA-256,
+// so the same file attached twice is stored once.
+type Attachment struct {
+	ID          string    `json:"id"`
+	TodoID      string    `json:"todo_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentRepository stores the attachments of the todo's, without their content
+type AttachmentRepository interface {
+	AddAttachment(context.Context, Attachment) error
+	FindAttachment(context.Context, string) (Attachment, error)
+	// FindAttachments returns the attachments of the todo, the oldest first
+	FindAttachments(ctx context.Context, todoID string) ([]Attachment, error)
+	DeleteAttachment(context.Context, string) error
+	// DeleteAttachments removes the attachments of the todo's and returns them
+	DeleteAttachments(ctx context.Context, todoIDs []string) ([]Attachment, error)
+	// CountBySHA256 returns how many attachments have the content
+	CountBySHA256(ctx context.Context, sum string) (int, error)
+}
+
+// BlobStore keeps the content of the attachments by key
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get returns ErrBlobNotFound for a missing key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the blob. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// sniffContentType recognizes the content. The declared type is used for content that is not recognized.
+func sniffContentType(data []byte, declared string) string {
+	sniffed := http.DetectContentType(data)
+	if sniffed != "application/octet-stream" || declared == "" {
+		return sniffed
+	}
+
+	if _, _, err := mime.ParseMediaType(declared); err != nil {
+		return sniffed
+	}
+
+	return declared
+}
+
+// attachmentName keeps the file name of an uploaded file without its path
+func attachmentName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return "attachment"
+	}
+
+	return name
+}
@@ -0,0 +1,109 @@
+package todos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileBlobStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Put(ctx, "abc123", strings.NewReader("content")); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.Exists(ctx, "abc123"); err != nil || !ok {
+		t.Fatalf("stored blob does not exist: %v", err)
+	}
+
+	rc, err := store.Get(ctx, "abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "content" {
+		t.Fatalf("wrong content: %q", data)
+	}
+
+	if err := store.Delete(ctx, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "abc123"); !errors.As(err, new(ErrBlobNotFound)) {
+		t.Fatalf("wrong error for a deleted blob: %v", err)
+	}
+	if err := store.Delete(ctx, "abc123"); err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+
+	if err := store.Put(ctx, "../../etc", strings.NewReader("x")); err == nil {
+		t.Fatal("a key outside the store was accepted")
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	ctx := context.Background()
+	blobs := NewInMemoryBlobStore()
+	svc := NewService(WithRepo(NewInMemoryRepository()), WithAttachments(NewInMemoryAttachments(), blobs, 64))
+
+	first, err := svc.Add(ctx, Todo{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := svc.Add(ctx, Todo{Title: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := svc.AddAttachment(ctx, first.ID, `C:\shots\screen.txt`, "application/pdf", strings.NewReader("plain text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Name != "screen.txt" || a.ContentType != "text/plain; charset=utf-8" || a.Size != 10 {
+		t.Fatalf("wrong attachment: %+v", a)
+	}
+	b, err := svc.AddAttachment(ctx, second.ID, "copy.txt", "", strings.NewReader("plain text"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.SHA256 != b.SHA256 {
+		t.Fatalf("same content with different hashes: %s, %s", a.SHA256, b.SHA256)
+	}
+
+	if _, err := svc.AddAttachment(ctx, first.ID, "big", "", strings.NewReader(strings.Repeat("x", 65))); !errors.As(err, new(ErrAttachmentTooLarge)) {
+		t.Fatalf("wrong error for a large attachment: %v", err)
+	}
+	if _, _, err := svc.OpenAttachment(ctx, second.ID, a.ID); !errors.As(err, new(ErrAttachmentNotFound)) {
+		t.Fatalf("an attachment was opened through another todo: %v", err)
+	}
+
+	if err := svc.Delete(ctx, first.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Purge(ctx, -time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := svc.ListAttachments(ctx, first.ID); len(all) != 0 {
+		t.Fatalf("attachments of a purged todo are listed: %+v", all)
+	}
+
+	_, rc, err := svc.OpenAttachment(ctx, second.ID, b.ID)
+	if err != nil {
+		t.Fatalf("shared content removed with the purged todo: %v", err)
+	}
+	rc.Close()
+
+	if err := svc.DeleteAttachment(ctx, second.ID, b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := blobs.Exists(ctx, b.SHA256); ok {
+		t.Fatal("unused content was kept")
+	}
+}
@@ -0,0 +1,61 @@
+package todos
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+type blobsMem struct {
+	data map[string][]byte
+	m    sync.RWMutex
+}
+
+func NewInMemoryBlobStore() BlobStore {
+	return &blobsMem{data: make(map[string][]byte)}
+}
+
+func (b *blobsMem) Put(_ context.Context, key string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	b.data[key] = data
+
+	return nil
+}
+
+func (b *blobsMem) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	data, ok := b.data[key]
+	if !ok {
+		return nil, ErrBlobNotFound{key}
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (b *blobsMem) Exists(_ context.Context, key string) (bool, error) {
+	b.m.RLock()
+	defer b.m.RUnlock()
+
+	_, ok := b.data[key]
+
+	return ok, nil
+}
+
+func (b *blobsMem) Delete(_ context.Context, key string) error {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	delete(b.data, key)
+
+	return nil
+}
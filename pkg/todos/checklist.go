@@ -0,0 +1,79 @@
+package todos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
+)
+
+// ChecklistItem is a step of a todo. Items without ID get one when the todo is saved.
+type ChecklistItem struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+// cleanChecklist trims the text of the items and gives an ID to the new ones
+func cleanChecklist(items []ChecklistItem) ([]ChecklistItem, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	cleaned := make([]ChecklistItem, len(items))
+	seen := make(map[string]bool)
+	for i, it := range items {
+		it.Text = strings.TrimSpace(it.Text)
+		if it.Text == "" {
+			return nil, ErrInvalidChecklist{fmt.Sprintf("item %d has no text", i+1)}
+		}
+		if it.ID == "" {
+			it.ID = uuid.NewString()
+		}
+		if seen[it.ID] {
+			return nil, ErrInvalidChecklist{fmt.Sprintf("item %s is repeated", it.ID)}
+		}
+		seen[it.ID] = true
+		cleaned[i] = it
+	}
+
+	return cleaned, nil
+}
+
+// toggleItem returns the checklist with the item done or not done anymore
+func toggleItem(items []ChecklistItem, id string) ([]ChecklistItem, error) {
+	idx := slices.IndexFunc(items, func(it ChecklistItem) bool { return it.ID == id })
+	if idx < 0 {
+		return nil, ErrChecklistItemNotFound{id}
+	}
+
+	toggled := slices.Clone(items)
+	toggled[idx].Done = !toggled[idx].Done
+
+	return toggled, nil
+}
+
+// reorderItems returns the checklist in the order of the IDs, which must name every item once
+func reorderItems(items []ChecklistItem, ids []string) ([]ChecklistItem, error) {
+	if len(ids) != len(items) {
+		return nil, ErrInvalidChecklist{fmt.Sprintf("the order names %d items instead of %d", len(ids), len(items))}
+	}
+
+	byID := make(map[string]ChecklistItem, len(items))
+	for _, it := range items {
+		byID[it.ID] = it
+	}
+
+	reordered := make([]ChecklistItem, 0, len(ids))
+	for _, id := range ids {
+		it, ok := byID[id]
+		if !ok {
+			return nil, ErrInvalidChecklist{fmt.Sprintf("unknown or repeated item %s", id)}
+		}
+		delete(byID, id)
+		reordered = append(reordered, it)
+	}
+
+	return reordered, nil
+}
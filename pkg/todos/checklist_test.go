@@ -0,0 +1,24 @@
+package todos
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReorderItems(t *testing.T) {
+	items := []ChecklistItem{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	reordered, err := reorderItems(items, []string{"c", "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reordered[0].ID != "c" || reordered[1].ID != "a" || reordered[2].ID != "b" {
+		t.Fatalf("wrong order: %+v", reordered)
+	}
+
+	for _, ids := range [][]string{{"a", "b"}, {"a", "a", "b"}, {"a", "b", "d"}} {
+		if _, err := reorderItems(items, ids); !errors.As(err, new(ErrInvalidChecklist)) {
+			t.Errorf("expected an invalid order for %v, got: %v", ids, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package todos
+
+import (
+	"context"
+	"time"
+)
+
+// Comment is a message in the discussion of a todo
+type Comment struct {
+	ID        string     `json:"id"`
+	TodoID    string     `json:"todo_id"`
+	Author    string     `json:"author,omitempty"`
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Edits holds the previous bodies of the comment, the oldest first
+	Edits []CommentEdit `json:"edits,omitempty"`
+}
+
+// CommentEdit is a previous body of a comment and the time it was replaced
+type CommentEdit struct {
+	Body     string    `json:"body"`
+	EditedAt time.Time `json:"edited_at"`
+}
+
+// CommentRepository stores the comments of the todo's. The comments of a todo in the trash are
+// trashed with it: they are not found until they are restored, and purged with the todo.
+type CommentRepository interface {
+	AddComment(context.Context, Comment) error
+	FindComment(context.Context, string) (Comment, error)
+	// FindComments returns the comments of the todo, the oldest first
+	FindComments(ctx context.Context, todoID string) ([]Comment, error)
+	// UpdateComment replaces the body of the comment and keeps the previous one in its edits
+	UpdateComment(ctx context.Context, id, body string, at time.Time) error
+	DeleteComment(context.Context, string) error
+	TrashComments(ctx context.Context, todoIDs []string, at time.Time) error
+	RestoreComments(ctx context.Context, todoIDs []string) error
+	// PurgeComments removes the comments trashed before the time for good
+	PurgeComments(ctx context.Context, before time.Time) error
+}
@@ -0,0 +1,63 @@
+package todos
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCommentsFollowTheirTodo(t *testing.T) {
+	ctx := WithActor(context.Background(), "alice")
+	svc := NewService(WithRepo(NewInMemoryRepository()), WithComments(NewInMemoryComments()))
+
+	parent, err := svc.Add(ctx, Todo{Title: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := svc.Add(ctx, Todo{Title: "child", ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cm, err := svc.AddComment(ctx, child.ID, "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cm, err = svc.EditComment(ctx, child.ID, cm.ID, "second"); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Author != "alice" || cm.Body != "second" || len(cm.Edits) != 1 || cm.Edits[0].Body != "first" {
+		t.Fatalf("wrong edited comment: %+v", cm)
+	}
+
+	if _, err := svc.EditComment(ctx, parent.ID, cm.ID, "third"); !errors.As(err, new(ErrCommentNotFound)) {
+		t.Fatalf("a comment was edited through another todo: %v", err)
+	}
+
+	if err := svc.Delete(ctx, parent.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := svc.ListComments(ctx, child.ID); len(all) != 0 {
+		t.Fatalf("comments of a deleted subtask are listed: %+v", all)
+	}
+
+	if _, err := svc.Restore(ctx, parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if all, _ := svc.ListComments(ctx, child.ID); len(all) != 1 {
+		t.Fatalf("comments not restored with their todo: %+v", all)
+	}
+
+	if err := svc.Delete(ctx, child.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Purge(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Restore(ctx, child.ID); err == nil {
+		t.Fatal("purged todo restored")
+	}
+	if _, err := svc.EditComment(ctx, child.ID, cm.ID, "third"); !errors.As(err, new(ErrCommentNotFound)) {
+		t.Fatalf("comment not purged with its todo: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package todos
+
+import "golang.org/x/exp/slices"
+
+// Dependency means that the todo cannot be completed before the blocker
+type Dependency struct {
+	TodoID    string `json:"todo_id"`
+	BlockerID string `json:"blocker_id"`
+}
+
+// Graph is the part of the dependency graph that is connected to a todo:
+// the todo's it depends on and the todo's that depend on it, at any distance.
+type Graph struct {
+	Todos        []Todo       `json:"todos"`
+	Dependencies []Dependency `json:"dependencies"`
+	// Order lists the IDs of the todo's in the order they can be completed, blockers first
+	Order []string `json:"order"`
+}
+
+// sortDependencies orders the dependencies by todo and blocker
+func sortDependencies(deps []Dependency) {
+	slices.SortFunc(deps, func(a, b Dependency) bool {
+		if a.TodoID != b.TodoID {
+			return a.TodoID < b.TodoID
+		}
+		return a.BlockerID < b.BlockerID
+	})
+}
+
+// completionOrder sorts the todo's topologically, blockers first. Todo's that can be completed
+// at the same time are sorted by ID. It fails when the dependencies contain a cycle.
+func completionOrder(ids []string, deps []Dependency) ([]string, error) {
+	blockers := make(map[string]int, len(ids))
+	dependents := make(map[string][]string)
+	for _, d := range deps {
+		blockers[d.TodoID]++
+		dependents[d.BlockerID] = append(dependents[d.BlockerID], d.TodoID)
+	}
+
+	var ready []string
+	for _, id := range ids {
+		if blockers[id] == 0 {
+			ready = append(ready, id)
+		}
+	}
+
+	order := make([]string, 0, len(ids))
+	for len(ready) > 0 {
+		slices.Sort(ready)
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+
+		for _, d := range dependents[id] {
+			if blockers[d]--; blockers[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+
+	if len(order) < len(ids) {
+		for _, d := range deps {
+			if blockers[d.TodoID] > 0 {
+				return nil, ErrDependencyCycle{d.TodoID, d.BlockerID}
+			}
+		}
+	}
+
+	return order, nil
+}
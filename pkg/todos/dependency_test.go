@@ -0,0 +1,32 @@
+package todos
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestCompletionOrder(t *testing.T) {
+	deps := []Dependency{
+		{TodoID: "c", BlockerID: "a"},
+		{TodoID: "c", BlockerID: "b"},
+		{TodoID: "d", BlockerID: "c"},
+	}
+
+	order, err := completionOrder([]string{"d", "c", "b", "a", "e"}, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"a", "b", "c", "d", "e"}
+	if !slices.Equal(order, expected) {
+		t.Fatalf("wrong order. expected: %v, got: %v", expected, order)
+	}
+
+	deps = append(deps, Dependency{TodoID: "a", BlockerID: "d"})
+	var cycle ErrDependencyCycle
+	if _, err := completionOrder([]string{"a", "b", "c", "d"}, deps); !errors.As(err, &cycle) {
+		t.Fatalf("the cycle was not detected. got: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package todos
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dialect is the SQL that differs between the databases. The queries are written for MySQL/MariaDB,
+// with `?` placeholders, and are translated when they run on another database.
+type dialect int
+
+const (
+	mysqlDialect dialect = iota
+	postgresDialect
+	sqliteDialect
+)
+
+// translate adapts a query to the database. PostgreSQL uses numbered placeholders. SQLite has no row locks,
+// its transactions lock the whole database, so `for update` is left out.
+func (d dialect) translate(qry string) string {
+	switch d {
+	case postgresDialect:
+		return rebind(qry)
+	case sqliteDialect:
+		return strings.ReplaceAll(qry, " for update", "")
+	}
+
+	return qry
+}
+
+// rebind replaces the `?` placeholders outside of the string literals with $1, $2, ...
+func rebind(qry string) string {
+	if !strings.Contains(qry, "?") {
+		return qry
+	}
+
+	var b strings.Builder
+	n := 0
+	quoted := false
+	for _, c := range qry {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == '?' && !quoted:
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
+
+// bind adapts the values of a query to the database. SQLite keeps the times as text, which is compared
+// as text, so the times are all stored in UTC.
+func (d dialect) bind(args []any) []any {
+	if d != sqliteDialect {
+		return args
+	}
+
+	bound := make([]any, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case time.Time:
+			a = v.UTC()
+		case sql.NullTime:
+			a = sql.NullTime{Time: v.Time.UTC(), Valid: v.Valid}
+		}
+		bound[i] = a
+	}
+
+	return bound
+}
+
+// binary makes the text expression compare by its bytes, like strings.Compare, whatever the collation
+// of the database. It is the default of SQLite.
+func (d dialect) binary(expr string) string {
+	switch d {
+	case postgresDialect:
+		return expr + ` collate "C"`
+	case sqliteDialect:
+		return expr
+	}
+
+	return "convert(" + expr + " using utf8mb4) collate utf8mb4_bin"
+}
+
+// insertIgnore returns an insert that skips the rows that would break a unique key
+func (d dialect) insertIgnore(into string) string {
+	switch d {
+	case postgresDialect:
+		return "insert into " + into + " on conflict do nothing"
+	case sqliteDialect:
+		return "insert or ignore into " + into
+	}
+
+	return "insert ignore into " + into
+}
+
+// upsert returns an insert of the columns that updates the row when the key already exists
+func (d dialect) upsert(table, key string, cols []string) string {
+	qry := "insert into " + table + " (" + strings.Join(cols, ", ") + ") values (" + placeholders(len(cols)) + ")"
+
+	var set []string
+	for _, c := range cols {
+		if c == key {
+			continue
+		}
+		if d == mysqlDialect {
+			set = append(set, c+" = values("+c+")")
+		} else {
+			set = append(set, c+" = excluded."+c)
+		}
+	}
+
+	if d != mysqlDialect {
+		return qry + " on conflict (" + key + ") do update set " + strings.Join(set, ", ")
+	}
+
+	return qry + " on duplicate key update " + strings.Join(set, ", ")
+}
+
+// dbConn runs the queries on the connection in the dialect of the database
+type dbConn struct {
+	*sql.DB
+	dialect
+}
+
+func (c dbConn) ExecContext(ctx context.Context, qry string, args ...any) (sql.Result, error) {
+	return c.DB.ExecContext(ctx, c.translate(qry), c.bind(args)...)
+}
+
+func (c dbConn) QueryContext(ctx context.Context, qry string, args ...any) (*sql.Rows, error) {
+	return c.DB.QueryContext(ctx, c.translate(qry), c.bind(args)...)
+}
+
+func (c dbConn) QueryRowContext(ctx context.Context, qry string, args ...any) *sql.Row {
+	return c.DB.QueryRowContext(ctx, c.translate(qry), c.bind(args)...)
+}
+
+func (c dbConn) BeginTx(ctx context.Context, opts *sql.TxOptions) (dbTx, error) {
+	tx, err := c.DB.BeginTx(ctx, opts)
+
+	return dbTx{tx, c.dialect}, err
+}
+
+// dbTx runs the queries of a transaction in the dialect of the database
+type dbTx struct {
+	*sql.Tx
+	dialect
+}
+
+func (tx dbTx) ExecContext(ctx context.Context, qry string, args ...any) (sql.Result, error) {
+	return tx.Tx.ExecContext(ctx, tx.translate(qry), tx.bind(args)...)
+}
+
+func (tx dbTx) QueryContext(ctx context.Context, qry string, args ...any) (*sql.Rows, error) {
+	return tx.Tx.QueryContext(ctx, tx.translate(qry), tx.bind(args)...)
+}
+
+func (tx dbTx) QueryRowContext(ctx context.Context, qry string, args ...any) *sql.Row {
+	return tx.Tx.QueryRowContext(ctx, tx.translate(qry), tx.bind(args)...)
+}
@@ -0,0 +1,59 @@
+package todos
+
+import "testing"
+
+func TestTranslate(t *testing.T) {
+	qry := "select * from todos where id = ? and title like ? escape '?' and version = ? for update"
+
+	if got := mysqlDialect.translate(qry); got != qry {
+		t.Fatalf("MySQL query changed: %s", got)
+	}
+
+	want := "select * from todos where id = $1 and title like $2 escape '?' and version = $3 for update"
+	if got := postgresDialect.translate(qry); got != want {
+		t.Fatalf("wrong PostgreSQL query: %s", got)
+	}
+
+	want = "select * from todos where id = ? and title like ? escape '?' and version = ?"
+	if got := sqliteDialect.translate(qry); got != want {
+		t.Fatalf("wrong SQLite query: %s", got)
+	}
+}
+
+func TestUpsert(t *testing.T) {
+	cols := []string{"id", "title", "version"}
+
+	want := "insert into todos (id, title, version) values (?, ?, ?) on duplicate key update title = values(title), version = values(version)"
+	if got := mysqlDialect.upsert("todos", "id", cols); got != want {
+		t.Fatalf("wrong MySQL upsert: %s", got)
+	}
+
+	want = "insert into todos (id, title, version) values (?, ?, ?) on conflict (id) do update set title = excluded.title, version = excluded.version"
+	if got := postgresDialect.upsert("todos", "id", cols); got != want {
+		t.Fatalf("wrong PostgreSQL upsert: %s", got)
+	}
+
+	want = "insert into tags (name) values (?) on conflict do nothing"
+	if got := postgresDialect.insertIgnore("tags (name) values (?)"); got != want {
+		t.Fatalf("wrong PostgreSQL insert: %s", got)
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	srt := Sort{{Field: SortByDueAt, Desc: true}, {Field: SortByTitle}}
+
+	want := "order by (due_at is null), due_at desc, convert(lower(title) using utf8mb4) collate utf8mb4_bin, id"
+	if got := mysqlDialect.orderBy(srt); got != want {
+		t.Fatalf("wrong MySQL order: %s", got)
+	}
+
+	want = `order by (due_at is null), due_at desc, lower(title) collate "C", id`
+	if got := postgresDialect.orderBy(srt); got != want {
+		t.Fatalf("wrong PostgreSQL order: %s", got)
+	}
+
+	want = "order by (due_at is null), due_at desc, lower(title), id"
+	if got := sqliteDialect.orderBy(srt); got != want {
+		t.Fatalf("wrong SQLite order: %s", got)
+	}
+}
@@ -1,6 +1,9 @@
 package todos
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type ErrNotFound struct {
 	id string
@@ -9,3 +12,172 @@ type ErrNotFound struct {
 func (e ErrNotFound) Error() string {
 	return fmt.Sprintf("not found todo with id: %s", e.id)
 }
+
+type ErrListNotFound struct {
+	id string
+}
+
+func (e ErrListNotFound) Error() string {
+	return fmt.Sprintf("not found list with id: %s", e.id)
+}
+
+type ErrParentNotFound struct {
+	id string
+}
+
+func (e ErrParentNotFound) Error() string {
+	return fmt.Sprintf("not found parent todo with id: %s", e.id)
+}
+
+// ErrCycle is returned when a todo would become a subtask of itself
+type ErrCycle struct {
+	id string
+}
+
+func (e ErrCycle) Error() string {
+	return fmt.Sprintf("todo with id %s cannot be a subtask of itself", e.id)
+}
+
+// ErrDependencyCycle is returned when a todo would depend on itself, directly or through other todo's
+type ErrDependencyCycle struct {
+	id, blocker string
+}
+
+func (e ErrDependencyCycle) Error() string {
+	return fmt.Sprintf("todo with id %s cannot depend on %s: it would depend on itself", e.id, e.blocker)
+}
+
+// ErrBlocked is returned when a todo is completed before the todo's it depends on
+type ErrBlocked struct {
+	id       string
+	blockers []string
+}
+
+func (e ErrBlocked) Error() string {
+	return fmt.Sprintf("todo with id %s is blocked by: %s", e.id, strings.Join(e.blockers, ", "))
+}
+
+// ErrNotRecurring is returned when a series operation is used on a todo without recurrence
+type ErrNotRecurring struct {
+	id string
+}
+
+func (e ErrNotRecurring) Error() string {
+	return fmt.Sprintf("todo with id %s does not repeat", e.id)
+}
+
+// ErrSeriesEnded is returned when a recurring todo has no next occurrence
+type ErrSeriesEnded struct {
+	id string
+}
+
+func (e ErrSeriesEnded) Error() string {
+	return fmt.Sprintf("the series of todo with id %s has no more occurrences", e.id)
+}
+
+type ErrTagNotFound struct {
+	name string
+}
+
+func (e ErrTagNotFound) Error() string {
+	return fmt.Sprintf("not found tag: %s", e.name)
+}
+
+type ErrTagExists struct {
+	name string
+}
+
+func (e ErrTagExists) Error() string {
+	return fmt.Sprintf("tag already exists: %s", e.name)
+}
+
+// ErrVersionMismatch is returned when a todo was changed by someone else since it was read
+type ErrVersionMismatch struct {
+	id string
+}
+
+func (e ErrVersionMismatch) Error() string {
+	return fmt.Sprintf("todo with id %s was changed in the meantime", e.id)
+}
+
+// ErrInvalidPatch is returned when a patch cannot be applied to a todo
+type ErrInvalidPatch struct {
+	err error
+}
+
+func (e ErrInvalidPatch) Error() string {
+	return fmt.Sprintf("invalid patch: %v", e.err)
+}
+
+func (e ErrInvalidPatch) Unwrap() error {
+	return e.err
+}
+
+// ErrNothingToUndo is returned when the client has no operation left to undo or redo
+type ErrNothingToUndo struct {
+	redo bool
+}
+
+func (e ErrNothingToUndo) Error() string {
+	if e.redo {
+		return "nothing to redo"
+	}
+	return "nothing to undo"
+}
+
+type ErrChecklistItemNotFound struct {
+	id string
+}
+
+func (e ErrChecklistItemNotFound) Error() string {
+	return fmt.Sprintf("not found checklist item with id: %s", e.id)
+}
+
+// ErrInvalidChecklist is returned for items without text or repeated, also in a new order of the items
+type ErrInvalidChecklist struct {
+	reason string
+}
+
+func (e ErrInvalidChecklist) Error() string {
+	return fmt.Sprintf("invalid checklist: %s", e.reason)
+}
+
+type ErrCommentNotFound struct {
+	id string
+}
+
+func (e ErrCommentNotFound) Error() string {
+	return fmt.Sprintf("not found comment with id: %s", e.id)
+}
+
+// ErrEmptyComment is returned for a comment without text
+type ErrEmptyComment struct{}
+
+func (e ErrEmptyComment) Error() string {
+	return "a comment needs a body"
+}
+
+type ErrAttachmentNotFound struct {
+	id string
+}
+
+func (e ErrAttachmentNotFound) Error() string {
+	return fmt.Sprintf("not found attachment with id: %s", e.id)
+}
+
+// ErrAttachmentTooLarge is returned for a file over the size limit, in bytes
+type ErrAttachmentTooLarge struct {
+	limit int64
+}
+
+func (e ErrAttachmentTooLarge) Error() string {
+	return fmt.Sprintf("attachments cannot be larger than %d bytes", e.limit)
+}
+
+type ErrBlobNotFound struct {
+	key string
+}
+
+func (e ErrBlobNotFound) Error() string {
+	return fmt.Sprintf("not found blob: %s", e.key)
+}
@@ -0,0 +1,510 @@
+package todos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Expr is a parsed filter expression. Match evaluates it against one todo,
+// the SQL repository translates it into a where clause.
+//
+// The syntax is a list of conditions combined with AND, OR, NOT and parentheses:
+//
+//	tag:work AND NOT tag:blocked AND completed:false AND title~"invoice"
+//
+// AND binds stronger than OR and can be left out: `tag:a tag:b` is `tag:a AND tag:b`.
+// The supported conditions are:
+//
+//	tag:<tag>                       the todo has this tag
+//	title:<text>, title~<text>      the title is, or contains, the text (case insensitive)
+//	completed:true|false            the todo is completed or still open
+//	list:<id>                       the todo is in this list, list:"" matches the todo's without a list
+//	blocked:true|false              the todo depends on open todo's
+//	series:<id>                     the todo is an occurrence of this recurring series
+//	parent:<id>                     the todo is a direct subtask of this todo, parent:"" matches the top level todo's
+//	priority:<name>                 compared with :, <, <=, > or >=, like priority>=high
+//	due_at<time>, completed_at<time> compared with <, <=, > or >=. Times are RFC 3339 or 2006-01-02 dates
+type Expr interface {
+	Match(Todo) bool
+	String() string
+}
+
+type AndExpr struct{ Left, Right Expr }
+
+type OrExpr struct{ Left, Right Expr }
+
+type NotExpr struct{ X Expr }
+
+type TagCond struct{ Tag string }
+
+type TitleCond struct {
+	// Contains matches a part of the title instead of the whole title
+	Contains bool
+	Text     string
+}
+
+type CompletedCond struct{ Completed bool }
+
+type ListCond struct{ ListID string }
+
+type ParentCond struct{ ParentID string }
+
+type SeriesCond struct{ SeriesID string }
+
+// BlockedCond matches the todo's with open blockers
+type BlockedCond struct{ Blocked bool }
+
+type PriorityCond struct {
+	Op       string
+	Priority Priority
+}
+
+// TimeCond compares a date field. Todo's without that date never match.
+type TimeCond struct {
+	Field SortField
+	Op    string
+	Time  time.Time
+}
+
+func (e AndExpr) Match(t Todo) bool { return e.Left.Match(t) && e.Right.Match(t) }
+func (e OrExpr) Match(t Todo) bool  { return e.Left.Match(t) || e.Right.Match(t) }
+func (e NotExpr) Match(t Todo) bool { return !e.X.Match(t) }
+
+func (c TagCond) Match(t Todo) bool {
+	for _, tg := range t.Tags {
+		if tg == c.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+func (c TitleCond) Match(t Todo) bool {
+	title := strings.ToLower(t.Title)
+	if c.Contains {
+		return strings.Contains(title, c.Text)
+	}
+	return title == c.Text
+}
+
+func (c CompletedCond) Match(t Todo) bool { return (t.CompletedAt != nil) == c.Completed }
+
+func (c ListCond) Match(t Todo) bool { return t.ListID == c.ListID }
+
+func (c ParentCond) Match(t Todo) bool { return t.ParentID == c.ParentID }
+
+func (c SeriesCond) Match(t Todo) bool { return t.SeriesID == c.SeriesID }
+
+func (c BlockedCond) Match(t Todo) bool { return (len(t.BlockedBy) > 0) == c.Blocked }
+
+func (c PriorityCond) Match(t Todo) bool { return compareOp(c.Op, int(t.Priority)-int(c.Priority)) }
+
+func (c TimeCond) Match(t Todo) bool {
+	var v *time.Time
+	switch c.Field {
+	case SortByDueAt:
+		v = t.DueAt
+	case SortByCompletedAt:
+		v = t.CompletedAt
+	}
+
+	return v != nil && compareOp(c.Op, v.Compare(c.Time))
+}
+
+// compareOp applies the operator to the result of a comparison (-1, 0, 1)
+func compareOp(op string, c int) bool {
+	switch op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return c == 0
+}
+
+func (e AndExpr) String() string { return "(" + e.Left.String() + " AND " + e.Right.String() + ")" }
+func (e OrExpr) String() string  { return "(" + e.Left.String() + " OR " + e.Right.String() + ")" }
+func (e NotExpr) String() string { return "NOT " + e.X.String() }
+func (c TagCond) String() string { return "tag:" + strconv.Quote(c.Tag) }
+func (c TitleCond) String() string {
+	if c.Contains {
+		return "title~" + strconv.Quote(c.Text)
+	}
+	return "title:" + strconv.Quote(c.Text)
+}
+func (c CompletedCond) String() string { return "completed:" + strconv.FormatBool(c.Completed) }
+func (c ListCond) String() string      { return "list:" + strconv.Quote(c.ListID) }
+func (c ParentCond) String() string    { return "parent:" + strconv.Quote(c.ParentID) }
+func (c SeriesCond) String() string    { return "series:" + strconv.Quote(c.SeriesID) }
+func (c BlockedCond) String() string   { return "blocked:" + strconv.FormatBool(c.Blocked) }
+func (c PriorityCond) String() string  { return "priority" + c.Op + c.Priority.String() }
+func (c TimeCond) String() string      { return string(c.Field) + c.Op + c.Time.Format(time.RFC3339) }
+
+// TagFilter matches the todo's that have at least one of anyOf, all of allOf and none of noneOf.
+// Empty lists are left out, a nil Expr is returned when all lists are empty.
+func TagFilter(anyOf, allOf, noneOf []string) Expr {
+	var e Expr
+	if anyOf := tagConds(anyOf); len(anyOf) > 0 {
+		e = joinExpr(e, anyExpr(anyOf))
+	}
+	for _, c := range tagConds(allOf) {
+		e = joinExpr(e, c)
+	}
+	if noneOf := tagConds(noneOf); len(noneOf) > 0 {
+		e = joinExpr(e, NotExpr{anyExpr(noneOf)})
+	}
+	return e
+}
+
+func tagConds(tags []string) []Expr {
+	var conds []Expr
+	for _, t := range tags {
+		if t = cleanTag(t); t != "" {
+			conds = append(conds, TagCond{t})
+		}
+	}
+	return conds
+}
+
+func anyExpr(conds []Expr) Expr {
+	e := conds[0]
+	for _, c := range conds[1:] {
+		e = OrExpr{e, c}
+	}
+	return e
+}
+
+// joinExpr combines two expressions with AND. Either of them can be nil.
+func joinExpr(l, r Expr) Expr {
+	switch {
+	case l == nil:
+		return r
+	case r == nil:
+		return l
+	}
+	return AndExpr{l, r}
+}
+
+// ParseFilter parses a filter expression. An empty string is a nil Expr, which matches everything.
+func ParseFilter(s string) (Expr, error) {
+	p := &filterParser{lex: &filterLexer{src: s}}
+	p.next()
+
+	if p.tok.kind == tokEOF {
+		return nil, p.err
+	}
+
+	e := p.parseOr()
+	if p.err == nil && p.tok.kind != tokEOF {
+		p.fail("unexpected %s", p.tok)
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return e, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokAnd
+	tokOr
+	tokNot
+	tokOpen
+	tokClose
+	tokCond
+)
+
+type token struct {
+	kind  tokenKind
+	pos   int
+	field string
+	op    string
+	value string
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokCond:
+		return fmt.Sprintf("%q at %d", t.field+t.op+t.value, t.pos)
+	}
+	return fmt.Sprintf("%q at %d", []string{"", "AND", "OR", "NOT", "(", ")"}[t.kind], t.pos)
+}
+
+type filterLexer struct {
+	src string
+	pos int
+}
+
+func (l *filterLexer) next() (token, error) {
+	for l.pos < len(l.src) && unicode.IsSpace(rune(l.src[l.pos])) {
+		l.pos++
+	}
+
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+
+	switch l.src[l.pos] {
+	case '(':
+		l.pos++
+		return token{kind: tokOpen, pos: start}, nil
+	case ')':
+		l.pos++
+		return token{kind: tokClose, pos: start}, nil
+	}
+
+	for l.pos < len(l.src) && (isFieldChar(l.src[l.pos])) {
+		l.pos++
+	}
+	word := l.src[start:l.pos]
+
+	if l.pos >= len(l.src) || !strings.ContainsRune(":=~<>", rune(l.src[l.pos])) {
+		switch strings.ToUpper(word) {
+		case "AND":
+			return token{kind: tokAnd, pos: start}, nil
+		case "OR":
+			return token{kind: tokOr, pos: start}, nil
+		case "NOT":
+			return token{kind: tokNot, pos: start}, nil
+		}
+		return token{}, fmt.Errorf("expected a condition like field:value at %d", start)
+	}
+	if word == "" {
+		return token{}, fmt.Errorf("missing field name at %d", start)
+	}
+
+	op := l.src[l.pos : l.pos+1]
+	l.pos++
+	if (op == "<" || op == ">") && l.pos < len(l.src) && l.src[l.pos] == '=' {
+		op += "="
+		l.pos++
+	}
+
+	value, err := l.value()
+	if err != nil {
+		return token{}, err
+	}
+
+	return token{kind: tokCond, pos: start, field: strings.ToLower(word), op: op, value: value}, nil
+}
+
+// value reads a quoted string or everything up to the next space or parenthesis
+func (l *filterLexer) value() (string, error) {
+	start := l.pos
+
+	if l.pos < len(l.src) && l.src[l.pos] == '"' {
+		l.pos++
+		for l.pos < len(l.src) && l.src[l.pos] != '"' {
+			if l.src[l.pos] == '\\' {
+				l.pos++
+			}
+			l.pos++
+		}
+		if l.pos >= len(l.src) {
+			return "", fmt.Errorf("unterminated string at %d", start)
+		}
+		l.pos++
+
+		return strconv.Unquote(l.src[start:l.pos])
+	}
+
+	for l.pos < len(l.src) && !unicode.IsSpace(rune(l.src[l.pos])) && l.src[l.pos] != '(' && l.src[l.pos] != ')' {
+		l.pos++
+	}
+	if start == l.pos {
+		return "", fmt.Errorf("missing value at %d", start)
+	}
+
+	return l.src[start:l.pos], nil
+}
+
+func isFieldChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// filterParser is a recursive descent parser. The first error stops the parsing.
+type filterParser struct {
+	lex *filterLexer
+	tok token
+	err error
+}
+
+func (p *filterParser) next() {
+	if p.err != nil {
+		return
+	}
+
+	tok, err := p.lex.next()
+	if err != nil {
+		p.err = err
+		tok = token{kind: tokEOF}
+	}
+	p.tok = tok
+}
+
+func (p *filterParser) fail(format string, args ...any) {
+	if p.err == nil {
+		p.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (p *filterParser) parseOr() Expr {
+	e := p.parseAnd()
+	for p.err == nil && p.tok.kind == tokOr {
+		p.next()
+		e = OrExpr{e, p.parseAnd()}
+	}
+	return e
+}
+
+func (p *filterParser) parseAnd() Expr {
+	e := p.parseUnary()
+	for p.err == nil {
+		switch p.tok.kind {
+		case tokAnd:
+			p.next()
+		case tokNot, tokOpen, tokCond:
+			// implicit AND
+		default:
+			return e
+		}
+		e = AndExpr{e, p.parseUnary()}
+	}
+	return e
+}
+
+func (p *filterParser) parseUnary() Expr {
+	switch p.tok.kind {
+	case tokNot:
+		p.next()
+		return NotExpr{p.parseUnary()}
+	case tokOpen:
+		p.next()
+		e := p.parseOr()
+		if p.err == nil && p.tok.kind != tokClose {
+			p.fail("expected ) instead of %s", p.tok)
+		}
+		p.next()
+		return e
+	case tokCond:
+		tok := p.tok
+		c, err := condition(tok)
+		if err != nil {
+			p.fail("%v at %d", err, tok.pos)
+		}
+		p.next()
+		return c
+	}
+
+	p.fail("unexpected %s", p.tok)
+	return nil
+}
+
+func condition(tok token) (Expr, error) {
+	switch tok.field {
+	case "tag":
+		if tok.op != ":" && tok.op != "=" {
+			return nil, fmt.Errorf("tag only supports :")
+		}
+		return TagCond{cleanTag(tok.value)}, nil
+
+	case "title":
+		switch tok.op {
+		case ":", "=":
+			return TitleCond{Text: strings.ToLower(tok.value)}, nil
+		case "~":
+			return TitleCond{Contains: true, Text: strings.ToLower(tok.value)}, nil
+		}
+		return nil, fmt.Errorf("title only supports : and ~")
+
+	case "completed":
+		if tok.op != ":" && tok.op != "=" {
+			return nil, fmt.Errorf("completed only supports :")
+		}
+		b, err := strconv.ParseBool(tok.value)
+		if err != nil {
+			return nil, fmt.Errorf("completed must be true or false")
+		}
+		return CompletedCond{b}, nil
+
+	case "list":
+		if tok.op != ":" && tok.op != "=" {
+			return nil, fmt.Errorf("list only supports :")
+		}
+		return ListCond{tok.value}, nil
+
+	case "blocked":
+		if tok.op != ":" && tok.op != "=" {
+			return nil, fmt.Errorf("blocked only supports :")
+		}
+		b, err := strconv.ParseBool(tok.value)
+		if err != nil {
+			return nil, fmt.Errorf("blocked must be true or false")
+		}
+		return BlockedCond{b}, nil
+
+	case "series":
+		if tok.op != ":" && tok.op != "=" {
+			return nil, fmt.Errorf("series only supports :")
+		}
+		return SeriesCond{tok.value}, nil
+
+	case "parent":
+		if tok.op != ":" && tok.op != "=" {
+			return nil, fmt.Errorf("parent only supports :")
+		}
+		return ParentCond{tok.value}, nil
+
+	case "priority":
+		if tok.op == "~" {
+			return nil, fmt.Errorf("priority does not support ~")
+		}
+		pr, err := ParsePriority(tok.value)
+		if err != nil {
+			return nil, err
+		}
+		return PriorityCond{Op: normalizeOp(tok.op), Priority: pr}, nil
+
+	case string(SortByDueAt), string(SortByCompletedAt):
+		if tok.op != "<" && tok.op != "<=" && tok.op != ">" && tok.op != ">=" {
+			return nil, fmt.Errorf("%s only supports <, <=, > and >=", tok.field)
+		}
+		t, err := parseFilterTime(tok.value)
+		if err != nil {
+			return nil, err
+		}
+		return TimeCond{Field: SortField(tok.field), Op: tok.op, Time: t}, nil
+	}
+
+	return nil, fmt.Errorf("unknown field: %q", tok.field)
+}
+
+func normalizeOp(op string) string {
+	if op == ":" {
+		return "="
+	}
+	return op
+}
+
+func parseFilterTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("not a RFC 3339 time or date: %q", s)
+}
@@ -0,0 +1,94 @@
+package todos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	due := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	done := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	invoice := Todo{ID: "1", Title: "Send the Invoice", Tags: []string{"work"}, Priority: PriorityHigh, DueAt: &due}
+	blocked := Todo{ID: "2", Title: "Pay invoice", Tags: []string{"work", "blocked"}}
+	shopping := Todo{ID: "3", Title: "Shopping", Tags: []string{"home"}, CompletedAt: &done}
+
+	tests := []struct {
+		filter string
+		match  string
+	}{
+		{`tag:work`, "12"},
+		{`tag:work AND NOT tag:blocked AND completed:false AND title~"invoice"`, "1"},
+		{`tag:work not tag:blocked`, "1"},
+		{`tag:home OR tag:blocked`, "23"},
+		{`tag:home OR tag:work AND priority>=high`, "13"},
+		{`(tag:home OR tag:work) AND priority:none`, "23"},
+		{`title:shopping`, "3"},
+		{`completed:true`, "3"},
+		{`due_at<2023-06-02`, "1"},
+		{`NOT due_at<2023-06-02`, "23"},
+		{`completed_at>=2023-05-01T00:00:00Z`, "3"},
+	}
+
+	for _, tt := range tests {
+		e, err := ParseFilter(tt.filter)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", tt.filter, err)
+		}
+
+		var match string
+		for _, td := range []Todo{invoice, blocked, shopping} {
+			if e.Match(td) {
+				match += td.ID
+			}
+		}
+		if match != tt.match {
+			t.Errorf("wrong match for %q (%s). expected: %s, got: %s", tt.filter, e, tt.match, match)
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, f := range []string{
+		`tag`,
+		`tag:`,
+		`colour:red`,
+		`tag:work AND`,
+		`(tag:work`,
+		`tag:work)`,
+		`title~"invoice`,
+		`completed:maybe`,
+		`priority>=extreme`,
+		`due_at:2023-06-01`,
+		`tag<work`,
+	} {
+		if _, err := ParseFilter(f); err == nil {
+			t.Errorf("expected an error for %q", f)
+		}
+	}
+
+	e, err := ParseFilter("  ")
+	if err != nil || e != nil {
+		t.Fatalf("an empty filter should match everything. got: %v, %v", e, err)
+	}
+}
+
+func TestFilterSQL(t *testing.T) {
+	e, err := ParseFilter(`tag:work AND NOT (completed:true OR title~"50%")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cond, args, err := filterSQL(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "(id in (" + withTagSQL + ") and not (completed_at is not null or lower(title) like ? escape '!'))"
+	if cond != expected {
+		t.Fatalf("wrong condition.\nexpected: %s\ngot:      %s", expected, cond)
+	}
+	if len(args) != 2 || args[0] != "work" || args[1] != "%50!%%" {
+		t.Fatalf("wrong arguments: %v", args)
+	}
+}
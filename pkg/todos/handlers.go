@@ -2,10 +2,18 @@ package todos
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func handleError(w http.ResponseWriter, err error, status int) {
@@ -16,23 +24,149 @@ func handleError(w http.ResponseWriter, err error, status int) {
 	}{err.Error()})
 }
 
+// handleChangeError reports why a todo was not changed. A version mismatch is a failed
+// precondition when the client sent If-Match, otherwise a conflict with another change.
+func handleChangeError(w http.ResponseWriter, r *http.Request, err error, fallback error) {
+	var mismatch ErrVersionMismatch
+	var notFound ErrNotFound
+
+	switch {
+	case errors.As(err, &mismatch) && r.Header.Get("If-Match") != "":
+		handleError(w, err, http.StatusPreconditionFailed)
+	case errors.As(err, &mismatch):
+		handleError(w, err, http.StatusConflict)
+	case errors.As(err, &notFound):
+		handleError(w, err, http.StatusNotFound)
+	case invalidReference(err), errors.As(err, new(ErrInvalidChecklist)):
+		handleError(w, err, http.StatusUnprocessableEntity)
+	case errors.As(err, new(ErrChecklistItemNotFound)):
+		handleError(w, err, http.StatusNotFound)
+	case errors.As(err, new(ErrNotRecurring)), errors.As(err, new(ErrSeriesEnded)), errors.As(err, new(ErrNothingToUndo)),
+		errors.As(err, new(ErrBlocked)):
+		handleError(w, err, http.StatusConflict)
+	default:
+		handleError(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+// invalidReference reports whether the todo points to a list or parent that cannot be used
+func invalidReference(err error) bool {
+	return errors.As(err, new(ErrListNotFound)) || errors.As(err, new(ErrParentNotFound)) || errors.As(err, new(ErrCycle))
+}
+
+func etag(t Todo) string {
+	return fmt.Sprintf(`"%d"`, t.Version)
+}
+
+// ifMatch checks the If-Match header against the current todo. It returns the version that a
+// change must be applied to, which is 0 without the header, and false if the header does not match.
+func ifMatch(r *http.Request, current Todo) (int64, bool) {
+	h := r.Header.Get("If-Match")
+	if h == "" {
+		return 0, true
+	}
+
+	if strings.TrimSpace(h) == "*" || etagListContains(h, etag(current), false) {
+		return current.Version, true
+	}
+
+	return 0, false
+}
+
+// ifNoneMatch reports whether the If-None-Match header matches the todo
+func ifNoneMatch(r *http.Request, t Todo) bool {
+	h := r.Header.Get("If-None-Match")
+	if h == "" {
+		return false
+	}
+
+	return strings.TrimSpace(h) == "*" || etagListContains(h, etag(t), true)
+}
+
+// etagListContains looks for the ETag in a comma separated list.
+// Weak ETags only match with the weak comparison used by If-None-Match.
+func etagListContains(list, tag string, weak bool) bool {
+	for _, t := range strings.Split(list, ",") {
+		t = strings.TrimSpace(t)
+		if weak {
+			t = strings.TrimPrefix(t, "W/")
+		}
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
 func listTodos(svc Service) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-type", "application/json")
 
-		all, err := svc.ListAll(r.Context())
+		q, err := pageQuery(r)
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		page, err := svc.FindPage(r.Context(), q)
 		if err != nil {
 			log.Printf("Serving all: %v\n", err)
 			handleError(w, err, http.StatusInternalServerError)
 			return
 		}
 
-		if err := json.NewEncoder(w).Encode(all); err != nil {
-			log.Printf("Encoding all: %v\n", err)
-			handleError(w, err, http.StatusInternalServerError)
+		writePage(w, r, page)
+	}
+}
+
+// pageQuery reads the filter, sort order, page size and cursor of a list request
+func pageQuery(r *http.Request) (Query, error) {
+	var q Query
+	var err error
+
+	params := r.URL.Query()
+
+	if q.Sort, err = ParseSort(params.Get("sort")); err != nil {
+		return q, err
+	}
+
+	if q.Filter, err = ParseFilter(params.Get("filter")); err != nil {
+		return q, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	if l := params.Get("limit"); l != "" {
+		if q.Limit, err = strconv.Atoi(l); err != nil || q.Limit < 1 {
+			return q, fmt.Errorf("limit must be a positive number")
 		}
 	}
+
+	if c := params.Get("cursor"); c != "" {
+		if q.After, err = ParseCursor(c); err != nil {
+			return q, err
+		}
+		if !q.After.Matches(q.Sort) {
+			return q, fmt.Errorf("cursor does not match the sort order")
+		}
+	}
+
+	return q, nil
+}
+
+// writePage encodes the todo's of the page. The link to the next page is sent in the Link header.
+func writePage(w http.ResponseWriter, r *http.Request, page Page) {
+	if page.Next != nil {
+		next := *r.URL
+		q := next.Query()
+		q.Set("cursor", page.Next.String())
+		next.RawQuery = q.Encode()
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+	}
+
+	if err := json.NewEncoder(w).Encode(page.Todos); err != nil {
+		log.Printf("Encoding page: %v\n", err)
+		handleError(w, err, http.StatusInternalServerError)
+	}
 }
 
 func createTodo(svc Service) http.HandlerFunc {
@@ -50,10 +184,15 @@ func createTodo(svc Service) http.HandlerFunc {
 		newTd, err := svc.Add(r.Context(), td)
 		if err != nil {
 			log.Printf("Creating todo: %v\n", err)
-			handleError(w, fmt.Errorf("todo not saved"), http.StatusInternalServerError)
+			if invalidReference(err) || errors.As(err, new(ErrInvalidChecklist)) {
+				handleError(w, err, http.StatusUnprocessableEntity)
+			} else {
+				handleError(w, fmt.Errorf("todo not saved"), http.StatusInternalServerError)
+			}
 			return
 		}
 
+		w.Header().Set("ETag", etag(newTd))
 		w.WriteHeader(http.StatusCreated)
 
 		if err := json.NewEncoder(w).Encode(newTd); err != nil {
@@ -76,6 +215,22 @@ func getTodo(svc Service) http.HandlerFunc {
 			return
 		}
 
+		if tree, _ := strconv.ParseBool(r.URL.Query().Get("tree")); tree {
+			writeTree(w, r, svc, *t)
+			return
+		}
+
+		w.Header().Set("ETag", etag(*t))
+		if ifNoneMatch(r, *t) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if r.URL.Query().Get("render") == "html" {
+			writeHTML(w, *t)
+			return
+		}
+
 		if err := json.NewEncoder(w).Encode(t); err != nil {
 			log.Printf("Encoding todo: %v\n", err)
 			handleError(w, err, http.StatusInternalServerError)
@@ -95,9 +250,15 @@ func deleteTodo(svc Service) http.HandlerFunc {
 			return
 		}
 
-		if err := svc.Delete(r.Context(), t.ID); err != nil {
+		version, ok := ifMatch(r, *t)
+		if !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		if err := svc.Delete(r.Context(), t.ID, version); err != nil {
 			log.Printf("Deleting todo: %v\n", err)
-			handleError(w, fmt.Errorf("todo not deleted"), http.StatusInternalServerError)
+			handleChangeError(w, r, err, fmt.Errorf("todo not deleted"))
 			return
 		}
 
@@ -118,20 +279,28 @@ func updateTodo(svc Service) http.HandlerFunc {
 			return
 		}
 
+		version, ok := ifMatch(r, *old)
+		if !ok {
+			handleError(w, ErrVersionMismatch{old.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
 		var newTodo Todo
 		if err := json.NewDecoder(r.Body).Decode(&newTodo); err != nil {
 			log.Printf("decoding body for update: %v\n", err)
 			handleError(w, err, http.StatusBadRequest)
 			return
 		}
+		newTodo.Version = version
 
 		updated, err := svc.Update(r.Context(), old.ID, newTodo)
 		if err != nil {
 			log.Printf("updating todo: %v\n", err)
-			handleError(w, fmt.Errorf("todo not updated"), http.StatusInternalServerError)
+			handleChangeError(w, r, err, fmt.Errorf("todo not updated"))
 			return
 		}
 
+		w.Header().Set("ETag", etag(updated))
 		if err := json.NewEncoder(w).Encode(updated); err != nil {
 			log.Printf("Encoding new todo: %v\n", err)
 			handleError(w, err, http.StatusInternalServerError)
@@ -139,27 +308,98 @@ func updateTodo(svc Service) http.HandlerFunc {
 	}
 }
 
+// patchTodo accepts a JSON Merge Patch (application/merge-patch+json, also the default for
+// application/json) or a JSON Patch (application/json-patch+json) document
+func patchTodo(svc Service) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		old, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || old == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		version, ok := ifMatch(r, *old)
+		if !ok {
+			handleError(w, ErrVersionMismatch{old.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			log.Printf("reading body for patch: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		var p Patch = MergePatch(body)
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-type")); ct == "application/json-patch+json" {
+			p = JSONPatch(body)
+		}
+
+		patched, err := svc.Patch(r.Context(), old.ID, version, p)
+		if err != nil {
+			log.Printf("patching todo: %v\n", err)
+			var invalid ErrInvalidPatch
+			if errors.As(err, &invalid) {
+				handleError(w, err, http.StatusUnprocessableEntity)
+			} else {
+				handleChangeError(w, r, err, fmt.Errorf("todo not updated"))
+			}
+			return
+		}
+
+		w.Header().Set("ETag", etag(patched))
+		if err := json.NewEncoder(w).Encode(patched); err != nil {
+			log.Printf("Encoding patched todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
 func searchByTag(svc Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-type", "application/json")
 
-		tags := strings.Split(r.URL.Query().Get("q"), ",")
-		if len(tags) == 0 {
-			handleError(w, fmt.Errorf("provide a list of tags in the q query parameter"), http.StatusBadRequest)
+		q, err := pageQuery(r)
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		params := r.URL.Query()
+		anyOf := strings.Split(params.Get("q"), ",")
+		allOf := strings.Split(params.Get("all"), ",")
+		noneOf := strings.Split(params.Get("none"), ",")
+
+		switch params.Get("mode") {
+		case "", "any":
+		case "all":
+			anyOf, allOf = nil, append(allOf, anyOf...)
+		default:
+			handleError(w, fmt.Errorf("mode must be any or all"), http.StatusBadRequest)
+			return
+		}
+
+		tags := TagFilter(anyOf, allOf, nil)
+		if tags == nil {
+			handleError(w, fmt.Errorf("provide a list of tags in the q or all query parameters"), http.StatusBadRequest)
 			return
 		}
 
-		withTag, err := svc.FindByTags(r.Context(), tags)
+		q.Filter = joinExpr(q.Filter, joinExpr(tags, TagFilter(nil, nil, noneOf)))
+
+		page, err := svc.FindPage(r.Context(), q)
 		if err != nil {
 			log.Printf("searching by tags: %v\n", err)
 			handleError(w, fmt.Errorf("error searching by tags"), http.StatusInternalServerError)
 			return
 		}
 
-		if err := json.NewEncoder(w).Encode(withTag); err != nil {
-			log.Printf("Encoding all: %v\n", err)
-			handleError(w, err, http.StatusInternalServerError)
-		}
+		writePage(w, r, page)
 	}
 }
 
@@ -174,15 +414,1215 @@ func completeTodo(svc Service) http.HandlerFunc {
 			return
 		}
 
-		completed, err := svc.MarkCompleted(r.Context(), *t)
+		if _, ok := ifMatch(r, *t); !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		force, _ := strconv.ParseBool(r.URL.Query().Get("force"))
+		complete := svc.MarkCompleted
+		if descendants, _ := strconv.ParseBool(r.URL.Query().Get("descendants")); descendants {
+			complete = svc.CompleteTree
+		}
+
+		// the todo is only completed if it was not changed since it was read
+		completed, err := complete(r.Context(), *t, force)
 		if err != nil {
 			log.Printf("Marking a todo completed: %v\n", err)
-			handleError(w, fmt.Errorf("could not complete todo"), http.StatusInternalServerError)
+			handleChangeError(w, r, err, fmt.Errorf("could not complete todo"))
+			return
 		}
 
+		w.Header().Set("ETag", etag(completed))
 		if err := json.NewEncoder(w).Encode(completed); err != nil {
 			log.Printf("Encoding completed todo: %v\n", err)
 			handleError(w, err, http.StatusInternalServerError)
 		}
 	}
 }
+
+// writeTree encodes the todo with its subtasks at any depth
+func writeTree(w http.ResponseWriter, r *http.Request, svc Service, t Todo) {
+	tree, err := svc.Tree(r.Context(), t.ID)
+	if err != nil {
+		log.Printf("Building todo tree: %v\n", err)
+		handleChangeError(w, r, err, fmt.Errorf("error finding subtasks"))
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(tree); err != nil {
+		log.Printf("Encoding todo tree: %v\n", err)
+		handleError(w, err, http.StatusInternalServerError)
+	}
+}
+
+func listChildren(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		q, err := pageQuery(r)
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		page, err := svc.FindChildren(r.Context(), t.ID, q)
+		if err != nil {
+			log.Printf("Finding subtasks: %v\n", err)
+			handleError(w, fmt.Errorf("error finding subtasks"), http.StatusInternalServerError)
+			return
+		}
+
+		writePage(w, r, page)
+	}
+}
+
+func addDependency(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		var body struct {
+			ID string `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			log.Printf("Decoding body to add dependency: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if body.ID == "" {
+			handleError(w, fmt.Errorf("provide the id of the blocking todo"), http.StatusBadRequest)
+			return
+		}
+
+		updated, err := svc.AddDependency(r.Context(), t.ID, body.ID)
+		if err != nil {
+			log.Printf("Adding dependency: %v\n", err)
+			handleDependencyError(w, err, fmt.Errorf("dependency not added"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(updated))
+		if err := json.NewEncoder(w).Encode(updated); err != nil {
+			log.Printf("Encoding todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func removeDependency(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if _, err := svc.RemoveDependency(r.Context(), t.ID, chi.URLParam(r, "dep")); err != nil {
+			log.Printf("Removing dependency: %v\n", err)
+			handleDependencyError(w, err, fmt.Errorf("dependency not removed"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		fmt.Fprint(w, "{}")
+	}
+}
+
+func dependencyGraph(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		g, err := svc.Graph(r.Context(), t.ID)
+		if err != nil {
+			log.Printf("Building dependency graph: %v\n", err)
+			handleDependencyError(w, err, fmt.Errorf("error building the dependency graph"))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(g); err != nil {
+			log.Printf("Encoding dependency graph: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// handleDependencyError reports a missing blocker as an invalid request, the todo itself was found by TodoCtx
+func handleDependencyError(w http.ResponseWriter, err error, fallback error) {
+	switch {
+	case errors.As(err, new(ErrDependencyCycle)):
+		handleError(w, err, http.StatusConflict)
+	case errors.As(err, new(ErrNotFound)):
+		handleError(w, err, http.StatusUnprocessableEntity)
+	default:
+		handleError(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+func listActionable(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		q, err := pageQuery(r)
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		page, err := svc.FindActionable(r.Context(), q)
+		if err != nil {
+			log.Printf("Finding actionable todos: %v\n", err)
+			handleError(w, fmt.Errorf("error finding actionable todos"), http.StatusInternalServerError)
+			return
+		}
+
+		writePage(w, r, page)
+	}
+}
+
+func skipTodo(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if _, ok := ifMatch(r, *t); !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		skipped, err := svc.Skip(r.Context(), *t)
+		if err != nil {
+			log.Printf("Skipping an occurrence: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("could not skip the occurrence"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(skipped))
+		if err := json.NewEncoder(w).Encode(skipped); err != nil {
+			log.Printf("Encoding skipped todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func endSeries(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if _, ok := ifMatch(r, *t); !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		ended, err := svc.EndSeries(r.Context(), *t)
+		if err != nil {
+			log.Printf("Ending a series: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("could not end the series"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(ended))
+		if err := json.NewEncoder(w).Encode(ended); err != nil {
+			log.Printf("Encoding todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func reopenTodo(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if _, ok := ifMatch(r, *t); !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		reopened, err := svc.Reopen(r.Context(), *t)
+		if err != nil {
+			log.Printf("Reopening a todo: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("could not reopen todo"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(reopened))
+		if err := json.NewEncoder(w).Encode(reopened); err != nil {
+			log.Printf("Encoding reopened todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func listCompletedTodos(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		q, err := pageQuery(r)
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		after, err := timeParam(r, "after")
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+		before, err := timeParam(r, "before")
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+		if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+			handleError(w, fmt.Errorf("after must be earlier than before"), http.StatusBadRequest)
+			return
+		}
+
+		page, err := svc.FindCompleted(r.Context(), after, before, q)
+		if err != nil {
+			log.Printf("Finding completed todos: %v\n", err)
+			handleError(w, fmt.Errorf("error finding completed todos"), http.StatusInternalServerError)
+			return
+		}
+
+		writePage(w, r, page)
+	}
+}
+
+func listOpenTodos(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		q, err := pageQuery(r)
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		page, err := svc.FindOpen(r.Context(), q)
+		if err != nil {
+			log.Printf("Finding open todos: %v\n", err)
+			handleError(w, fmt.Errorf("error finding open todos"), http.StatusInternalServerError)
+			return
+		}
+
+		writePage(w, r, page)
+	}
+}
+
+func listDue(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		after, err := timeParam(r, "after")
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+		before, err := timeParam(r, "before")
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+		if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+			handleError(w, fmt.Errorf("after must be earlier than before"), http.StatusBadRequest)
+			return
+		}
+
+		due, err := svc.FindDue(r.Context(), after, before)
+		if err != nil {
+			log.Printf("Finding due todos: %v\n", err)
+			handleError(w, fmt.Errorf("error finding due todos"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(due); err != nil {
+			log.Printf("Encoding due todos: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func listOverdue(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		overdue, err := svc.FindOverdue(r.Context())
+		if err != nil {
+			log.Printf("Finding overdue todos: %v\n", err)
+			handleError(w, fmt.Errorf("error finding overdue todos"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(overdue); err != nil {
+			log.Printf("Encoding overdue todos: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func listReminders(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		remind, err := svc.FindReminders(r.Context())
+		if err != nil {
+			log.Printf("Finding reminders: %v\n", err)
+			handleError(w, fmt.Errorf("error finding reminders"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(remind); err != nil {
+			log.Printf("Encoding reminders: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// timeParam parses an optional RFC 3339 query parameter. A missing parameter is the zero time.
+func timeParam(r *http.Request, name string) (time.Time, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not an RFC 3339 time: %q", name, v)
+	}
+
+	return t, nil
+}
+
+func listTags(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		all, err := svc.ListTags(r.Context())
+		if err != nil {
+			log.Printf("Listing tags: %v\n", err)
+			handleError(w, fmt.Errorf("error listing tags"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(all); err != nil {
+			log.Printf("Encoding tags: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func renameTag(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			log.Printf("Decoding body to rename tag: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if cleanTag(body.Name) == "" {
+			handleError(w, fmt.Errorf("provide the new name of the tag"), http.StatusBadRequest)
+			return
+		}
+
+		renamed, err := svc.RenameTag(r.Context(), tagParam(r), body.Name)
+		if err != nil {
+			log.Printf("Renaming tag: %v\n", err)
+			handleTagError(w, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(renamed); err != nil {
+			log.Printf("Encoding renamed tag: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func mergeTag(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		var body struct {
+			Into string `json:"into"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			log.Printf("Decoding body to merge tag: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if cleanTag(body.Into) == "" {
+			handleError(w, fmt.Errorf("provide the tag to merge into"), http.StatusBadRequest)
+			return
+		}
+
+		merged, err := svc.MergeTag(r.Context(), tagParam(r), body.Into)
+		if err != nil {
+			log.Printf("Merging tag: %v\n", err)
+			handleTagError(w, err)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(merged); err != nil {
+			log.Printf("Encoding merged tag: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// tagParam returns the unescaped tag name from the URL path
+func tagParam(r *http.Request) string {
+	name := chi.URLParam(r, "name")
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		return unescaped
+	}
+	return name
+}
+
+func handleTagError(w http.ResponseWriter, err error) {
+	var notFound ErrTagNotFound
+	var exists ErrTagExists
+
+	switch {
+	case errors.As(err, &notFound):
+		handleError(w, err, http.StatusNotFound)
+	case errors.As(err, &exists):
+		handleError(w, err, http.StatusConflict)
+	default:
+		handleError(w, fmt.Errorf("tag not changed"), http.StatusInternalServerError)
+	}
+}
+
+func moveTodo(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if _, ok := ifMatch(r, *t); !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		var body struct {
+			ListID string `json:"list_id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			log.Printf("Decoding body to move todo: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		moved, err := svc.MoveTodo(r.Context(), *t, body.ListID)
+		if err != nil {
+			log.Printf("Moving todo: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("todo not moved"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(moved))
+		if err := json.NewEncoder(w).Encode(moved); err != nil {
+			log.Printf("Encoding moved todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func listLists(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		archived, _ := strconv.ParseBool(r.URL.Query().Get("archived"))
+
+		all, err := svc.ListLists(r.Context(), archived)
+		if err != nil {
+			log.Printf("Listing lists: %v\n", err)
+			handleError(w, fmt.Errorf("error listing lists"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(all); err != nil {
+			log.Printf("Encoding lists: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func createList(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		var l List
+		if err := json.NewDecoder(r.Body).Decode(&l); err != nil {
+			log.Printf("Decoding body to create list: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if strings.TrimSpace(l.Name) == "" {
+			handleError(w, fmt.Errorf("provide the name of the list"), http.StatusBadRequest)
+			return
+		}
+
+		newList, err := svc.AddList(r.Context(), l)
+		if err != nil {
+			log.Printf("Creating list: %v\n", err)
+			handleError(w, fmt.Errorf("list not saved"), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(newList); err != nil {
+			log.Printf("Encoding new list: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func getList(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		l, ok := r.Context().Value(ListCtxKey).(*List)
+		if !ok || l == nil {
+			log.Println("no list from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(l); err != nil {
+			log.Printf("Encoding list: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func renameList(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		l, ok := r.Context().Value(ListCtxKey).(*List)
+		if !ok || l == nil {
+			log.Println("no list from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			log.Printf("Decoding body to rename list: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		if strings.TrimSpace(body.Name) == "" {
+			handleError(w, fmt.Errorf("provide the new name of the list"), http.StatusBadRequest)
+			return
+		}
+
+		renamed, err := svc.RenameList(r.Context(), l.ID, body.Name)
+		if err != nil {
+			log.Printf("Renaming list: %v\n", err)
+			handleListError(w, err, fmt.Errorf("list not renamed"))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(renamed); err != nil {
+			log.Printf("Encoding renamed list: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func archiveList(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		l, ok := r.Context().Value(ListCtxKey).(*List)
+		if !ok || l == nil {
+			log.Println("no list from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		archived, err := svc.ArchiveList(r.Context(), l.ID)
+		if err != nil {
+			log.Printf("Archiving list: %v\n", err)
+			handleListError(w, err, fmt.Errorf("list not archived"))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(archived); err != nil {
+			log.Printf("Encoding archived list: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// deleteList archives the list, or removes it with all its todo's when ?cascade=true
+func deleteList(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		l, ok := r.Context().Value(ListCtxKey).(*List)
+		if !ok || l == nil {
+			log.Println("no list from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		cascade, _ := strconv.ParseBool(r.URL.Query().Get("cascade"))
+
+		if err := svc.DeleteList(r.Context(), l.ID, cascade); err != nil {
+			log.Printf("Deleting list: %v\n", err)
+			handleListError(w, err, fmt.Errorf("list not deleted"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+		fmt.Fprint(w, "{}")
+	}
+}
+
+func listListTodos(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		l, ok := r.Context().Value(ListCtxKey).(*List)
+		if !ok || l == nil {
+			log.Println("no list from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		q, err := pageQuery(r)
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+		q.Filter = joinExpr(ListCond{l.ID}, q.Filter)
+
+		page, err := svc.FindPage(r.Context(), q)
+		if err != nil {
+			log.Printf("Finding todos of list: %v\n", err)
+			handleError(w, fmt.Errorf("error finding the todos of the list"), http.StatusInternalServerError)
+			return
+		}
+
+		writePage(w, r, page)
+	}
+}
+
+func handleListError(w http.ResponseWriter, err error, fallback error) {
+	if errors.As(err, new(ErrListNotFound)) {
+		handleError(w, err, http.StatusNotFound)
+		return
+	}
+	handleError(w, fallback, http.StatusInternalServerError)
+}
+
+func listTrash(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		all, err := svc.ListTrash(r.Context())
+		if err != nil {
+			log.Printf("Listing the trash: %v\n", err)
+			handleError(w, fmt.Errorf("error listing the trash"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(all); err != nil {
+			log.Printf("Encoding the trash: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func restoreTodo(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		restored, err := svc.Restore(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			log.Printf("Restoring todo: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("todo not restored"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(restored))
+		if err := json.NewEncoder(w).Encode(restored); err != nil {
+			log.Printf("Encoding restored todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func listHistory(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		id := chi.URLParam(r, "id")
+		events, err := svc.History(r.Context(), id)
+		if errors.As(err, new(ErrNotFound)) {
+			handleError(w, err, http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Listing history of %s: %v\n", id, err)
+			handleError(w, fmt.Errorf("error listing the history"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(events); err != nil {
+			log.Printf("Encoding history: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func undo(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		op, err := svc.Undo(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			log.Printf("Undoing: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("operation not undone"))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(op); err != nil {
+			log.Printf("Encoding undone operation: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func redo(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		op, err := svc.Redo(r.Context(), chi.URLParam(r, "id"))
+		if err != nil {
+			log.Printf("Redoing: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("operation not redone"))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(op); err != nil {
+			log.Printf("Encoding redone operation: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// writeHTML writes the todo rendered as sanitized HTML
+func writeHTML(w http.ResponseWriter, t Todo) {
+	out, err := RenderHTML(t)
+	if err != nil {
+		log.Printf("Rendering todo: %v\n", err)
+		handleError(w, fmt.Errorf("todo not rendered"), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-type", "text/html; charset=utf-8")
+	w.Write(out)
+}
+
+func toggleChecklistItem(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if _, ok := ifMatch(r, *t); !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		toggled, err := svc.ToggleChecklistItem(r.Context(), *t, chi.URLParam(r, "item"))
+		if err != nil {
+			log.Printf("Toggling checklist item: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("checklist item not toggled"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(toggled))
+		if err := json.NewEncoder(w).Encode(toggled); err != nil {
+			log.Printf("Encoding toggled todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func reorderChecklist(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if _, ok := ifMatch(r, *t); !ok {
+			handleError(w, ErrVersionMismatch{t.ID}, http.StatusPreconditionFailed)
+			return
+		}
+
+		var body struct {
+			IDs []string `json:"ids"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			log.Printf("Decoding body to reorder checklist: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		reordered, err := svc.ReorderChecklist(r.Context(), *t, body.IDs)
+		if err != nil {
+			log.Printf("Reordering checklist: %v\n", err)
+			handleChangeError(w, r, err, fmt.Errorf("checklist not reordered"))
+			return
+		}
+
+		w.Header().Set("ETag", etag(reordered))
+		if err := json.NewEncoder(w).Encode(reordered); err != nil {
+			log.Printf("Encoding reordered todo: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func handleCommentError(w http.ResponseWriter, err error, fallback error) {
+	switch {
+	case errors.As(err, new(ErrCommentNotFound)):
+		handleError(w, err, http.StatusNotFound)
+	case errors.As(err, new(ErrEmptyComment)):
+		handleError(w, err, http.StatusUnprocessableEntity)
+	default:
+		handleError(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+func listComments(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		all, err := svc.ListComments(r.Context(), t.ID)
+		if err != nil {
+			log.Printf("Listing comments: %v\n", err)
+			handleError(w, fmt.Errorf("error listing the comments"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(all); err != nil {
+			log.Printf("Encoding comments: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// commentBody reads the body of a comment from the request
+func commentBody(r *http.Request) (string, error) {
+	var body struct {
+		Body string `json:"body"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&body)
+
+	return body.Body, err
+}
+
+func createComment(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		body, err := commentBody(r)
+		if err != nil {
+			log.Printf("Decoding body to comment: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		cm, err := svc.AddComment(r.Context(), t.ID, body)
+		if err != nil {
+			log.Printf("Adding comment: %v\n", err)
+			handleCommentError(w, err, fmt.Errorf("comment not saved"))
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(cm); err != nil {
+			log.Printf("Encoding new comment: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func editComment(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		body, err := commentBody(r)
+		if err != nil {
+			log.Printf("Decoding body to edit comment: %v\n", err)
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		cm, err := svc.EditComment(r.Context(), t.ID, chi.URLParam(r, "cid"), body)
+		if err != nil {
+			log.Printf("Editing comment: %v\n", err)
+			handleCommentError(w, err, fmt.Errorf("comment not saved"))
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(cm); err != nil {
+			log.Printf("Encoding edited comment: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+func deleteComment(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if err := svc.DeleteComment(r.Context(), t.ID, chi.URLParam(r, "cid")); err != nil {
+			log.Printf("Deleting comment: %v\n", err)
+			handleCommentError(w, err, fmt.Errorf("comment not deleted"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleAttachmentError(w http.ResponseWriter, err error, fallback error) {
+	switch {
+	case errors.As(err, new(ErrAttachmentNotFound)), errors.As(err, new(ErrBlobNotFound)):
+		handleError(w, err, http.StatusNotFound)
+	case errors.As(err, new(ErrAttachmentTooLarge)):
+		handleError(w, err, http.StatusRequestEntityTooLarge)
+	default:
+		handleError(w, fallback, http.StatusInternalServerError)
+	}
+}
+
+func listAttachments(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		all, err := svc.ListAttachments(r.Context(), t.ID)
+		if err != nil {
+			log.Printf("Listing attachments: %v\n", err)
+			handleError(w, fmt.Errorf("error listing the attachments"), http.StatusInternalServerError)
+			return
+		}
+
+		if err := json.NewEncoder(w).Encode(all); err != nil {
+			log.Printf("Encoding attachments: %v\n", err)
+			handleError(w, err, http.StatusInternalServerError)
+		}
+	}
+}
+
+// uploadAttachment streams the `file` part of a multipart/form-data request to the service
+func uploadAttachment(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		mr, err := r.MultipartReader()
+		if err != nil {
+			handleError(w, err, http.StatusBadRequest)
+			return
+		}
+
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				handleError(w, fmt.Errorf("missing the file part"), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Printf("Reading attachment: %v\n", err)
+				handleError(w, err, http.StatusBadRequest)
+				return
+			}
+			if part.FormName() != "file" {
+				continue
+			}
+
+			at, err := svc.AddAttachment(r.Context(), t.ID, part.FileName(), part.Header.Get("Content-Type"), part)
+			if err != nil {
+				log.Printf("Adding attachment: %v\n", err)
+				handleAttachmentError(w, err, fmt.Errorf("attachment not saved"))
+				return
+			}
+
+			w.WriteHeader(http.StatusCreated)
+			if err := json.NewEncoder(w).Encode(at); err != nil {
+				log.Printf("Encoding new attachment: %v\n", err)
+				handleError(w, err, http.StatusInternalServerError)
+			}
+			return
+		}
+	}
+}
+
+func downloadAttachment(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		at, content, err := svc.OpenAttachment(r.Context(), t.ID, chi.URLParam(r, "aid"))
+		if err != nil {
+			log.Printf("Opening attachment: %v\n", err)
+			w.Header().Set("Content-type", "application/json")
+			handleAttachmentError(w, err, fmt.Errorf("attachment not found"))
+			return
+		}
+		defer content.Close()
+
+		w.Header().Set("Content-type", at.ContentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(at.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": at.Name}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("ETag", `"`+at.SHA256+`"`)
+
+		if _, err := io.Copy(w, content); err != nil {
+			log.Printf("Sending attachment: %v\n", err)
+		}
+	}
+}
+
+func deleteAttachment(svc Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-type", "application/json")
+
+		t, ok := r.Context().Value(TodoCtxKey).(*Todo)
+		if !ok || t == nil {
+			log.Println("no todo from request context")
+			handleError(w, fmt.Errorf("not found"), http.StatusNotFound)
+			return
+		}
+
+		if err := svc.DeleteAttachment(r.Context(), t.ID, chi.URLParam(r, "aid")); err != nil {
+			log.Printf("Deleting attachment: %v\n", err)
+			handleAttachmentError(w, err, fmt.Errorf("attachment not deleted"))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,121 @@
+package todos
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Action is the kind of change recorded in the history of a todo
+type Action string
+
+const (
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionComplete Action = "complete"
+	ActionReopen   Action = "reopen"
+	ActionDelete   Action = "delete"
+	ActionRestore  Action = "restore"
+)
+
+// Event is an entry of the history of a todo. Events are never changed once they are added.
+type Event struct {
+	ID      int64     `json:"id"`
+	TodoID  string    `json:"todo_id"`
+	Action  Action    `json:"action"`
+	Actor   string    `json:"actor,omitempty"`
+	At      time.Time `json:"at"`
+	Changes []Change  `json:"changes"`
+}
+
+// Change is the value of a field before and after a change, as JSON. A missing value is null.
+type Change struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// HistoryRepository stores the history of the todo's. It is append-only.
+type HistoryRepository interface {
+	// AddEvent stores the event with the next ID
+	AddEvent(context.Context, Event) error
+	// FindEvents returns the events of the todo, the oldest first
+	FindEvents(context.Context, string) ([]Event, error)
+}
+
+// historyIgnored are the fields that change without a change of the todo itself
+var historyIgnored = []string{"id", "version", "blocked_by"}
+
+// diff compares the JSON fields of the todo's. A nil todo has no fields.
+func diff(before, after *Todo) ([]Change, error) {
+	b, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []string
+	for f := range b {
+		fields = append(fields, f)
+	}
+	for f := range a {
+		if _, ok := b[f]; !ok {
+			fields = append(fields, f)
+		}
+	}
+	slices.Sort(fields)
+
+	changes := make([]Change, 0)
+	for _, f := range fields {
+		if slices.Contains(historyIgnored, f) {
+			continue
+		}
+
+		bv, av := b[f], a[f]
+		if bv == nil {
+			bv = json.RawMessage("null")
+		}
+		if av == nil {
+			av = json.RawMessage("null")
+		}
+		if string(bv) != string(av) {
+			changes = append(changes, Change{Field: f, Before: bv, After: av})
+		}
+	}
+
+	return changes, nil
+}
+
+func jsonFields(t *Todo) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+	if t == nil {
+		return fields, nil
+	}
+
+	b, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &fields)
+
+	return fields, err
+}
+
+type actorCtxKey struct{}
+
+// WithActor returns a context that records the changes in the name of the actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorCtxKey{}, actor)
+}
+
+// ActorFrom returns the actor of the context, empty if it has none
+func ActorFrom(ctx context.Context) string {
+	actor, _ := ctx.Value(actorCtxKey{}).(string)
+	return actor
+}
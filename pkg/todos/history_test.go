@@ -0,0 +1,141 @@
+package todos
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func TestDiffIgnoresVersion(t *testing.T) {
+	before := Todo{ID: "1", Title: "old", Tags: []string{"a"}, Version: 1}
+	after := Todo{ID: "1", Title: "new", Tags: []string{"a"}, ListID: "l", Version: 2}
+
+	changes, err := diff(&before, &after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes, got: %+v", changes)
+	}
+	if c := changes[0]; c.Field != "list_id" || string(c.Before) != "null" || string(c.After) != `"l"` {
+		t.Fatalf("wrong change of list_id: %+v", c)
+	}
+	if c := changes[1]; c.Field != "title" || string(c.Before) != `"old"` || string(c.After) != `"new"` {
+		t.Fatalf("wrong change of title: %+v", c)
+	}
+}
+
+func TestServiceRecordsHistory(t *testing.T) {
+	ctx := WithActor(context.Background(), "alice")
+	svc := NewService(WithRepo(NewInMemoryRepository()), WithHistory(NewInMemoryHistory()))
+
+	td, err := svc.Add(ctx, Todo{Title: "write history"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td, err = svc.MarkCompleted(ctx, td, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, td.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := svc.History(ctx, td.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Action{ActionCreate, ActionComplete, ActionDelete}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got: %+v", len(expected), events)
+	}
+	for i, e := range events {
+		if e.Action != expected[i] || e.Actor != "alice" {
+			t.Fatalf("wrong event %d: %+v", i, e)
+		}
+	}
+
+	if c := events[1].Changes; len(c) != 1 || c[0].Field != "completed_at" || string(c[0].Before) != "null" {
+		t.Fatalf("wrong changes of complete: %+v", c)
+	}
+}
+
+func TestServiceHistoryWithoutEvents(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(WithRepo(NewInMemoryRepository()))
+
+	live, err := svc.Add(ctx, Todo{Title: "live"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trashed, err := svc.Add(ctx, Todo{Title: "trashed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, trashed.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{live.ID, trashed.ID} {
+		events, err := svc.History(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if events == nil || len(events) != 0 {
+			t.Fatalf("expected an empty history for %s, got: %+v", id, events)
+		}
+	}
+
+	if _, err := svc.History(ctx, "missing"); !errors.As(err, new(ErrNotFound)) {
+		t.Fatalf("expected not found, got: %v", err)
+	}
+}
+
+func TestServiceRecordsTagChanges(t *testing.T) {
+	ctx := WithClient(context.Background(), "test")
+	svc := NewService(WithRepo(NewInMemoryRepository()), WithHistory(NewInMemoryHistory()), WithUndo(10))
+
+	a, err := svc.Add(ctx, Todo{Title: "a", Tags: []string{"golang"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := svc.Add(ctx, Todo{Title: "b", Tags: []string{"go", "golang"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.RenameTag(ctx, "golang", "gopher"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.MergeTag(ctx, "gopher", "go"); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := svc.History(ctx, b.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 || events[1].Action != ActionUpdate || events[2].Action != ActionUpdate {
+		t.Fatalf("wrong events of the tag changes: %+v", events)
+	}
+	if c := events[2].Changes; len(c) != 1 || c[0].Field != "tags" || string(c[0].Before) != `["go","gopher"]` || string(c[0].After) != `["go"]` {
+		t.Fatalf("wrong changes of the merge: %+v", c)
+	}
+
+	// the merge is undone on all the todo's at once
+	if _, err := svc.Undo(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, td := range []Todo{a, b} {
+		got, err := svc.FindByID(ctx, td.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Contains(got.Tags, "gopher") {
+			t.Fatalf("merge not undone: %+v", got)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package todos
+
+import "time"
+
+// List groups todo's, like a project. A todo belongs to at most one list.
+type List struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// ArchivedAt is set when the list is archived. Its todo's are kept.
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+}
@@ -1,29 +1,73 @@
 package todos
 
 import (
-	"strings"
+	"sort"
 	"time"
 )
 
 type Todo struct {
-	ID          string     `json:"id"`
-	Title       string     `json:"title"`
+	ID    string `json:"id"`
+	Title string `json:"title"`
+	// Description is free text in Markdown
+	Description string `json:"description,omitempty"`
+	// Checklist holds the steps of the todo, in order
+	Checklist []ChecklistItem `json:"checklist,omitempty"`
+	ListID    string          `json:"list_id,omitempty"`
+	// ParentID is set on subtasks. Subtasks can be nested at any depth.
+	ParentID    string     `json:"parent_id,omitempty"`
 	Tags        []string   `json:"tags"`
+	Priority    Priority   `json:"priority"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	DueAt       *time.Time `json:"due_at,omitempty"`
+	RemindAt    *time.Time `json:"remind_at,omitempty"`
+	// Recurrence repeats the todo: completing it adds the next occurrence of the series
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+	// SeriesID is the ID of the first todo of a recurring series, Occurrence its number in the series starting at 1
+	SeriesID   string `json:"series_id,omitempty"`
+	Occurrence int    `json:"occurrence,omitempty"`
+	// DeletedAt is set on the todo's in the trash
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// BlockedBy lists the open todo's this todo depends on. It is computed on every read.
+	BlockedBy []string `json:"blocked_by,omitempty"`
+	// Version is incremented on every change. It is sent as the ETag of the todo.
+	Version int64 `json:"version"`
 }
 
-// CleanTags returns a comma separated list of deduplicated tags (small caps)
-func (t Todo) CleanTags() string {
+// UniqueTags returns the sorted list of deduplicated tags (small caps), without empty tags
+func (t Todo) UniqueTags() []string {
 	tags := make(map[string]bool)
 	for _, tag := range t.Tags {
-		cleaned := strings.ToLower(strings.TrimSpace(tag))
-		tags[cleaned] = true
+		if cleaned := cleanTag(tag); cleaned != "" {
+			tags[cleaned] = true
+		}
 	}
 
-	var unique []string
+	unique := make([]string, 0, len(tags))
 	for k := range tags {
 		unique = append(unique, k)
 	}
+	sort.Strings(unique)
 
-	return strings.Join(unique, ",")
+	return unique
+}
+
+// IsOverdue reports whether the todo is still open and its due date is before `at`
+func (t Todo) IsOverdue(at time.Time) bool {
+	return t.CompletedAt == nil && t.DueAt != nil && t.DueAt.Before(at)
+}
+
+// zoneOf returns the UTC offset of a time formatted as `+02:00` or `Z`.
+// Timestamp columns only keep the instant, so the offset is stored next to it.
+func zoneOf(t time.Time) string {
+	return t.Format("Z07:00")
+}
+
+// inZone moves the time to the zone saved by zoneOf. An invalid zone leaves the time unchanged.
+func inZone(t time.Time, zone string) time.Time {
+	z, err := time.Parse("Z07:00", zone)
+	if err != nil {
+		return t
+	}
+
+	return t.In(z.Location())
 }
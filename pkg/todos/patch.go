@@ -0,0 +1,332 @@
+package todos
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Patch changes the JSON representation of a todo
+type Patch interface {
+	Apply(doc []byte) ([]byte, error)
+}
+
+// MergePatch is a JSON Merge Patch document (RFC 7396). The fields it contains replace the
+// fields of the todo, a null removes the field. Fields that are left out are not changed.
+type MergePatch []byte
+
+func (p MergePatch) Apply(doc []byte) ([]byte, error) {
+	var target, patch any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(p, &patch); err != nil {
+		return nil, fmt.Errorf("invalid merge patch: %w", err)
+	}
+
+	return json.Marshal(mergePatch(target, patch))
+}
+
+func mergePatch(target, patch any) any {
+	p, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]any)
+	if !ok {
+		t = make(map[string]any)
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+
+	return t
+}
+
+// JSONPatch is a JSON Patch document (RFC 6902): a list of add, remove, replace, move, copy
+// and test operations. The operations are applied in order and all of them fail if one fails.
+type JSONPatch []byte
+
+type patchOp struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+func (p JSONPatch) Apply(doc []byte) ([]byte, error) {
+	var target any
+	if err := json.Unmarshal(doc, &target); err != nil {
+		return nil, err
+	}
+
+	var ops []patchOp
+	if err := json.Unmarshal(p, &ops); err != nil {
+		return nil, fmt.Errorf("invalid json patch: %w", err)
+	}
+
+	for i, op := range ops {
+		var err error
+		if target, err = op.apply(target); err != nil {
+			return nil, fmt.Errorf("json patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+
+	return json.Marshal(target)
+}
+
+func (op patchOp) value() (any, error) {
+	if op.Value == nil {
+		return nil, fmt.Errorf("missing value")
+	}
+
+	var v any
+	err := json.Unmarshal(*op.Value, &v)
+
+	return v, err
+}
+
+func (op patchOp) apply(doc any) (any, error) {
+	switch op.Op {
+	case "add":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+
+	case "remove":
+		doc, _, err := pointerRemove(doc, op.Path)
+		return doc, err
+
+	case "replace":
+		v, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		if doc, _, err = pointerRemove(doc, op.Path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+
+	case "move":
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move a value into itself")
+		}
+		doc, v, err := pointerRemove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, v)
+
+	case "copy":
+		v, err := pointerGet(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		// the value must not be shared between the two locations
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		var cp any
+		if err := json.Unmarshal(b, &cp); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, op.Path, cp)
+
+	case "test":
+		expected, err := op.value()
+		if err != nil {
+			return nil, err
+		}
+		v, err := pointerGet(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(v, expected) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation")
+}
+
+// parsePointer splits a JSON pointer (RFC 6901) into its unescaped tokens
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(ptr, "/") {
+		return nil, fmt.Errorf("invalid pointer: %q", ptr)
+	}
+
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+func arrayIndex(tok string, length int, allowEnd bool) (int, error) {
+	if allowEnd && tok == "-" {
+		return length, nil
+	}
+
+	idx, err := strconv.Atoi(tok)
+	if err != nil || idx < 0 || (tok != "0" && strings.HasPrefix(tok, "0")) {
+		return 0, fmt.Errorf("invalid array index: %q", tok)
+	}
+
+	max := length - 1
+	if allowEnd {
+		max = length
+	}
+	if idx > max {
+		return 0, fmt.Errorf("array index out of range: %d", idx)
+	}
+
+	return idx, nil
+}
+
+func pointerGet(doc any, ptr string) (any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tok := range tokens {
+		switch d := doc.(type) {
+		case map[string]any:
+			v, ok := d[tok]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %q", ptr)
+			}
+			doc = v
+		case []any:
+			idx, err := arrayIndex(tok, len(d), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[idx]
+		default:
+			return nil, fmt.Errorf("path not found: %q", ptr)
+		}
+	}
+
+	return doc, nil
+}
+
+// pointerAdd returns the document with the value added at the pointer.
+// Arrays are changed by value, so the changed array is set in its parent again.
+func pointerAdd(doc any, ptr string, v any) (any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return v, nil
+	}
+
+	parentPtr := ptr[:strings.LastIndex(ptr, "/")]
+	parent, err := pointerGet(doc, parentPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = v
+		return doc, nil
+	case []any:
+		idx, err := arrayIndex(last, len(p), true)
+		if err != nil {
+			return nil, err
+		}
+		p = append(p[:idx], append([]any{v}, p[idx:]...)...)
+		return pointerSet(doc, parentPtr, p)
+	}
+
+	return nil, fmt.Errorf("path not found: %q", ptr)
+}
+
+// pointerRemove returns the document without the value at the pointer, and the removed value
+func pointerRemove(doc any, ptr string) (any, any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, doc, nil
+	}
+
+	parentPtr := ptr[:strings.LastIndex(ptr, "/")]
+	parent, err := pointerGet(doc, parentPtr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		v, ok := p[last]
+		if !ok {
+			return nil, nil, fmt.Errorf("path not found: %q", ptr)
+		}
+		delete(p, last)
+		return doc, v, nil
+	case []any:
+		idx, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		v := p[idx]
+		p = append(p[:idx:idx], p[idx+1:]...)
+		doc, err = pointerSet(doc, parentPtr, p)
+		return doc, v, err
+	}
+
+	return nil, nil, fmt.Errorf("path not found: %q", ptr)
+}
+
+// pointerSet replaces the existing value at the pointer
+func pointerSet(doc any, ptr string, v any) (any, error) {
+	tokens, err := parsePointer(ptr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return v, nil
+	}
+
+	parentPtr := ptr[:strings.LastIndex(ptr, "/")]
+	parent, err := pointerGet(doc, parentPtr)
+	if err != nil {
+		return nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]any:
+		p[last] = v
+	case []any:
+		idx, err := arrayIndex(last, len(p), false)
+		if err != nil {
+			return nil, err
+		}
+		p[idx] = v
+	}
+
+	return doc, nil
+}
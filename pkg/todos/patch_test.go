@@ -0,0 +1,72 @@
+package todos
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func jsonEqual(t *testing.T, expected string, got []byte) {
+	t.Helper()
+
+	var e, g any
+	if err := json.Unmarshal([]byte(expected), &e); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(got, &g); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e, g) {
+		t.Fatalf("wrong document.\nexpected: %s\ngot:      %s", expected, got)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// example from RFC 7396
+	doc := `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"}, "tags": ["example", "sample"], "content": "This will be unchanged"}`
+	patch := `{"title": "Hello!", "phoneNumber": "+01-123-456-7890", "author": {"familyName": null}, "tags": ["example"]}`
+
+	got, err := MergePatch(patch).Apply([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jsonEqual(t, `{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"], "content": "This will be unchanged", "phoneNumber": "+01-123-456-7890"}`, got)
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		doc, patch, expected string
+	}{
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"baz": "qux", "foo": "bar"}`},
+		{`{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`},
+		{`{"foo": ["bar"]}`, `[{"op": "add", "path": "/foo/-", "value": "qux"}]`, `{"foo": ["bar", "qux"]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`},
+		{`{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "replace", "path": "/baz", "value": "boo"}]`, `{"baz": "boo", "foo": "bar"}`},
+		{`{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`, `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`, `{"foo": {"bar": "baz"}, "qux": {"corge": "grault", "thud": "fred"}}`},
+		{`{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`},
+		{`{"a/b": 1}`, `[{"op": "copy", "from": "/a~1b", "path": "/c"}]`, `{"a/b": 1, "c": 1}`},
+		{`{"baz": "qux"}`, `[{"op": "test", "path": "/baz", "value": "qux"}]`, `{"baz": "qux"}`},
+	}
+
+	for _, tt := range tests {
+		got, err := JSONPatch(tt.patch).Apply([]byte(tt.doc))
+		if err != nil {
+			t.Fatalf("applying %s: %v", tt.patch, err)
+		}
+		jsonEqual(t, tt.expected, got)
+	}
+
+	for _, patch := range []string{
+		`[{"op": "test", "path": "/baz", "value": "bar"}]`,
+		`[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+		`[{"op": "remove", "path": "/missing"}]`,
+		`[{"op": "replace", "path": "/baz"}]`,
+		`[{"op": "unknown", "path": "/baz"}]`,
+	} {
+		if _, err := JSONPatch(patch).Apply([]byte(`{"baz": "qux"}`)); err == nil {
+			t.Errorf("expected an error for %s", patch)
+		}
+	}
+}
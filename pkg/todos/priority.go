@@ -0,0 +1,59 @@
+package todos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Priority of a todo. Higher values are more important.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityUrgent {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+func (p Priority) MarshalText() ([]byte, error) {
+	if p < PriorityNone || p > PriorityUrgent {
+		return nil, fmt.Errorf("invalid priority: %d", int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText accepts the name of a priority. An empty string is PriorityNone.
+func (p *Priority) UnmarshalText(b []byte) error {
+	pr, err := ParsePriority(string(b))
+	if err != nil {
+		return err
+	}
+
+	*p = pr
+	return nil
+}
+
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return PriorityNone, nil
+	}
+
+	for i, n := range priorityNames {
+		if n == s {
+			return Priority(i), nil
+		}
+	}
+
+	return PriorityNone, fmt.Errorf("unknown priority: %q", s)
+}
@@ -0,0 +1,132 @@
+package todos
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxPageSize is the largest number of todo's returned in one page
+const MaxPageSize = 1000
+
+// Query selects a page of todo's
+type Query struct {
+	// Filter keeps only the todo's that match the expression. nil means all todo's.
+	Filter Expr
+	Sort   Sort
+	// Limit is the maximum number of todo's in the page. 0 means no limit.
+	Limit int
+	// After continues the listing after the last todo of a previous page
+	After *Cursor
+}
+
+type Page struct {
+	Todos []Todo
+	// Next is nil on the last page
+	Next *Cursor
+}
+
+// Cursor marks the position of a todo in a sorted list. It only keeps the fields needed
+// to compare other todo's against it, so a page continues in the right place even if
+// that todo was changed or deleted in the meantime.
+type Cursor struct {
+	sort Sort
+	last Todo
+}
+
+func cursorAfter(srt Sort, td Todo) *Cursor {
+	return &Cursor{
+		sort: srt,
+		last: Todo{
+			ID:          td.ID,
+			Title:       td.Title,
+			Priority:    td.Priority,
+			DueAt:       td.DueAt,
+			CompletedAt: td.CompletedAt,
+		},
+	}
+}
+
+// Matches reports whether the cursor was created for a listing with this sort order
+func (c *Cursor) Matches(srt Sort) bool {
+	return c.sort.String() == srt.String()
+}
+
+// after reports whether td comes after the cursor position
+func (c *Cursor) after(td Todo) bool {
+	return c.sort.Compare(td, c.last) > 0
+}
+
+type cursorJSON struct {
+	Sort        string     `json:"s"`
+	ID          string     `json:"i"`
+	Title       string     `json:"t,omitempty"`
+	Priority    Priority   `json:"p,omitempty"`
+	DueAt       *time.Time `json:"d,omitempty"`
+	CompletedAt *time.Time `json:"c,omitempty"`
+}
+
+// String returns the opaque representation of the cursor that clients pass back
+func (c *Cursor) String() string {
+	b, _ := json.Marshal(cursorJSON{
+		Sort:        c.sort.String(),
+		ID:          c.last.ID,
+		Title:       c.last.Title,
+		Priority:    c.last.Priority,
+		DueAt:       c.last.DueAt,
+		CompletedAt: c.last.CompletedAt,
+	})
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseCursor is the reverse of Cursor.String
+func ParseCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var cj cursorJSON
+	if err := json.Unmarshal(b, &cj); err != nil || cj.ID == "" {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	srt, err := ParseSort(cj.Sort)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &Cursor{
+		sort: srt,
+		last: Todo{
+			ID:          cj.ID,
+			Title:       cj.Title,
+			Priority:    cj.Priority,
+			DueAt:       cj.DueAt,
+			CompletedAt: cj.CompletedAt,
+		},
+	}, nil
+}
+
+// paginate cuts a sorted list of todo's to the page selected by the query
+func paginate(sorted []Todo, q Query) Page {
+	if q.After != nil {
+		idx := 0
+		for idx < len(sorted) && !q.After.after(sorted[idx]) {
+			idx++
+		}
+		sorted = sorted[idx:]
+	}
+
+	if q.Limit <= 0 || len(sorted) <= q.Limit {
+		return Page{Todos: sorted}
+	}
+
+	return Page{
+		Todos: sorted[:q.Limit],
+		Next:  cursorAfter(q.Sort, sorted[q.Limit-1]),
+	}
+}
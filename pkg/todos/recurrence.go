@@ -0,0 +1,256 @@
+package todos
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Frequency of a recurrence
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Recurrence is a subset of the iCalendar RRULE (RFC 5545), written like
+//
+//	FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=10
+//
+// FREQ is DAILY, WEEKLY or MONTHLY. INTERVAL repeats every n days, weeks or months.
+// BYDAY picks weekdays of WEEKLY schedules, BYMONTHDAY days of MONTHLY schedules (-1 is the last day).
+// UNTIL (20060102 or 20060102T150405Z) and COUNT end the series. Weeks start on Monday.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Until      *time.Time
+	Count      int
+}
+
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+func ParseRecurrence(s string) (Recurrence, error) {
+	r := Recurrence{Interval: 1}
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Recurrence{}, fmt.Errorf("invalid recurrence part: %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			if r.Freq != Daily && r.Freq != Weekly && r.Freq != Monthly {
+				return Recurrence{}, fmt.Errorf("unsupported frequency: %q", value)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(value); err != nil || r.Interval < 1 {
+				return Recurrence{}, fmt.Errorf("interval must be a positive number")
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(value); err != nil || r.Count < 1 {
+				return Recurrence{}, fmt.Errorf("count must be a positive number")
+			}
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return Recurrence{}, err
+			}
+			r.Until = &until
+		case "BYDAY":
+			for _, d := range strings.Split(strings.ToUpper(value), ",") {
+				idx := slices.Index(weekdayNames, d)
+				if idx < 0 {
+					return Recurrence{}, fmt.Errorf("invalid weekday: %q", d)
+				}
+				r.ByDay = append(r.ByDay, time.Weekday(idx))
+			}
+		case "BYMONTHDAY":
+			for _, d := range strings.Split(value, ",") {
+				day, err := strconv.Atoi(d)
+				if err != nil || day == 0 || day < -31 || day > 31 {
+					return Recurrence{}, fmt.Errorf("invalid day of the month: %q", d)
+				}
+				r.ByMonthDay = append(r.ByMonthDay, day)
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("unsupported recurrence part: %q", name)
+		}
+	}
+
+	switch {
+	case r.Freq == "":
+		return Recurrence{}, fmt.Errorf("recurrence needs a FREQ")
+	case len(r.ByDay) > 0 && r.Freq != Weekly:
+		return Recurrence{}, fmt.Errorf("BYDAY is only supported with FREQ=WEEKLY")
+	case len(r.ByMonthDay) > 0 && r.Freq != Monthly:
+		return Recurrence{}, fmt.Errorf("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	case r.Until != nil && r.Count > 0:
+		return Recurrence{}, fmt.Errorf("UNTIL and COUNT cannot be used together")
+	}
+
+	slices.Sort(r.ByDay)
+	slices.Sort(r.ByMonthDay)
+
+	return r, nil
+}
+
+func parseUntil(s string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			if layout == "20060102" {
+				// the whole day is included
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid UNTIL: %q", s)
+}
+
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = weekdayNames[d]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+func (r Recurrence) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+func (r *Recurrence) UnmarshalText(b []byte) error {
+	parsed, err := ParseRecurrence(string(b))
+	if err != nil {
+		return err
+	}
+
+	*r = parsed
+	return nil
+}
+
+// Next returns the occurrence that follows the occurrence number n, due at `due`.
+// It returns false when the series ends before the next occurrence.
+func (r Recurrence) Next(due time.Time, n int) (time.Time, bool) {
+	if r.Count > 0 && n >= r.Count {
+		return time.Time{}, false
+	}
+
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	var next time.Time
+	var ok bool
+	switch r.Freq {
+	case Daily:
+		next, ok = due.AddDate(0, 0, interval), true
+	case Weekly:
+		next, ok = r.nextWeekly(due, interval)
+	case Monthly:
+		next, ok = r.nextMonthly(due, interval)
+	}
+
+	if !ok || (r.Until != nil && next.After(*r.Until)) {
+		return time.Time{}, false
+	}
+
+	return next, true
+}
+
+func (r Recurrence) nextWeekly(due time.Time, interval int) (time.Time, bool) {
+	if len(r.ByDay) == 0 {
+		return due.AddDate(0, 0, 7*interval), true
+	}
+
+	start := weekStart(due)
+	for i := 1; i <= 7*interval+7; i++ {
+		day := due.AddDate(0, 0, i)
+		weeks := daysBetween(start, weekStart(day)) / 7
+		if weeks%interval == 0 && slices.Contains(r.ByDay, day.Weekday()) {
+			return day, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (r Recurrence) nextMonthly(due time.Time, interval int) (time.Time, bool) {
+	days := r.ByMonthDay
+	if len(days) == 0 {
+		days = []int{due.Day()}
+	}
+
+	// some days do not exist in every month, like the 31st. Give up after 10 years.
+	for k := 0; k <= 120; k += interval {
+		first := time.Date(due.Year(), due.Month()+time.Month(k), 1, due.Hour(), due.Minute(), due.Second(), due.Nanosecond(), due.Location())
+		last := first.AddDate(0, 1, -1).Day()
+
+		var found []time.Time
+		for _, d := range days {
+			if d < 0 {
+				d = last + 1 + d
+			}
+			if d < 1 || d > last {
+				continue
+			}
+			if t := first.AddDate(0, 0, d-1); t.After(due) {
+				found = append(found, t)
+			}
+		}
+
+		if len(found) > 0 {
+			slices.SortFunc(found, func(a, b time.Time) bool { return a.Before(b) })
+			return found[0], true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// weekStart returns the Monday of the week of t
+func weekStart(t time.Time) time.Time {
+	return t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+}
+
+// daysBetween counts the calendar days from a to b
+func daysBetween(a, b time.Time) int {
+	da := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	db := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+
+	return int(db.Sub(da).Hours() / 24)
+}
@@ -0,0 +1,65 @@
+package todos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	// a Wednesday
+	due := time.Date(2023, 5, 31, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule string
+		n    int
+		next string
+	}{
+		{"FREQ=DAILY", 1, "2023-06-01"},
+		{"FREQ=DAILY;INTERVAL=3", 1, "2023-06-03"},
+		{"FREQ=WEEKLY", 1, "2023-06-07"},
+		{"FREQ=WEEKLY;BYDAY=MO,TH", 1, "2023-06-01"},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE", 1, "2023-06-12"},
+		{"FREQ=MONTHLY", 1, "2023-07-31"},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,15", 1, "2023-06-01"},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", 1, "2023-06-30"},
+		{"FREQ=DAILY;COUNT=2", 1, "2023-06-01"},
+		{"FREQ=DAILY;COUNT=2", 2, ""},
+		{"FREQ=WEEKLY;UNTIL=20230606", 1, ""},
+		{"FREQ=WEEKLY;UNTIL=20230607", 1, "2023-06-07"},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRecurrence(tt.rule)
+		if err != nil {
+			t.Fatalf("parsing %q: %v", tt.rule, err)
+		}
+
+		next, ok := r.Next(due, tt.n)
+		var got string
+		if ok {
+			got = next.Format("2006-01-02")
+			if next.Hour() != 9 || next.Minute() != 30 {
+				t.Fatalf("%s: the time of day changed: %v", tt.rule, next)
+			}
+		}
+		if got != tt.next {
+			t.Fatalf("%s: wrong next occurrence after %d. expected: %q, got: %q", tt.rule, tt.n, tt.next, got)
+		}
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	r, err := ParseRecurrence("RRULE:freq=weekly;byday=fr,mo;interval=2;count=5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := r.String(); s != "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=5" {
+		t.Fatalf("wrong recurrence: %s", s)
+	}
+
+	for _, invalid := range []string{"", "FREQ=YEARLY", "FREQ=DAILY;BYDAY=MO", "FREQ=DAILY;INTERVAL=0", "FREQ=DAILY;COUNT=2;UNTIL=20230101"} {
+		if _, err := ParseRecurrence(invalid); err == nil {
+			t.Fatalf("%q should not parse", invalid)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package todos
+
+import (
+	"bytes"
+	"html"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+var markdown = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// htmlPolicy removes scripts, styles and event handlers from the rendered Markdown.
+// The checkboxes of the checklist are kept.
+var htmlPolicy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("type").Matching(bluemonday.SpaceSeparatedTokens).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}()
+
+// RenderHTML renders the title, the description and the checklist of the todo as sanitized HTML
+func RenderHTML(t Todo) ([]byte, error) {
+	var md strings.Builder
+	md.WriteString(t.Description)
+	if len(t.Checklist) > 0 {
+		md.WriteString("\n\n")
+		for _, it := range t.Checklist {
+			check := " "
+			if it.Done {
+				check = "x"
+			}
+			md.WriteString("- [" + check + "] " + strings.Join(strings.Fields(it.Text), " ") + "\n")
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<h1>" + html.EscapeString(t.Title) + "</h1>\n")
+	if err := markdown.Convert([]byte(md.String()), &out); err != nil {
+		return nil, err
+	}
+
+	return htmlPolicy.SanitizeBytes(out.Bytes()), nil
+}
@@ -0,0 +1,29 @@
+package todos
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderHTML(t *testing.T) {
+	out, err := RenderHTML(Todo{
+		Title:       "<b>release</b>",
+		Description: "Ship **it**\n\n<script>alert(1)</script>\n\n[x](javascript:alert(1))",
+		Checklist:   []ChecklistItem{{ID: "1", Text: "tag", Done: true}, {ID: "2", Text: "announce"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := string(out)
+	for _, expected := range []string{"<h1>&lt;b&gt;release&lt;/b&gt;</h1>", "<strong>it</strong>", `<input checked="" disabled="" type="checkbox"> tag`} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("missing %q in %s", expected, html)
+		}
+	}
+	for _, unexpected := range []string{"<script", "javascript:"} {
+		if strings.Contains(html, unexpected) {
+			t.Errorf("unsafe %q in %s", unexpected, html)
+		}
+	}
+}
@@ -1,12 +1,51 @@
 package todos
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
+// Repository stores the todo's. Update and Delete compare-and-swap: when the version of the todo
+// is not 0, they fail with ErrVersionMismatch unless it is the version that is stored.
+// Add stores version 1 and Update increments it.
+// The list of a todo must exist when it is added or updated.
+// The parent must exist and cannot be the todo itself or one of its subtasks.
+// Delete moves the todo and its subtasks to the trash, where they are not found by the other methods
+// until they are restored. Purge removes them for good, with their dependencies.
+// Dependencies must exist on both ends and cannot form a cycle.
 type Repository interface {
 	FindByID(context.Context, string) (Todo, error)
-	FindByTag(context.Context, string) ([]Todo, error)
-	ListAll(context.Context) ([]Todo, error)
+	FindByTag(context.Context, string, Sort) ([]Todo, error)
+	FindDue(ctx context.Context, after, before time.Time) ([]Todo, error)
+	FindOverdue(context.Context, time.Time) ([]Todo, error)
+	FindReminders(context.Context, time.Time) ([]Todo, error)
+	ListAll(context.Context, Sort) ([]Todo, error)
+	FindPage(context.Context, Query) (Page, error)
 	Add(context.Context, Todo) error
-	Delete(context.Context, string) error
+	Delete(ctx context.Context, id string, version int64) error
 	Update(context.Context, string, Todo) error
+	ListTrash(context.Context) ([]Todo, error)
+	// Restore takes the todo out of the trash, with the subtasks that were deleted together with it
+	Restore(context.Context, string) error
+	// Purge removes the todo's deleted before the time and returns how many were removed
+	Purge(ctx context.Context, before time.Time) (int, error)
+	ListTags(context.Context) ([]TagCount, error)
+	RenameTag(ctx context.Context, from, to string) error
+	MergeTag(ctx context.Context, from, into string) error
+	// FindDescendants returns the subtasks of the todo at any depth
+	FindDescendants(context.Context, string) ([]Todo, error)
+	// AddDependency records that the blocker must be completed before the todo
+	AddDependency(context.Context, Dependency) error
+	RemoveDependency(context.Context, Dependency) error
+	// FindDependencies returns the dependencies from and to the todo's
+	FindDependencies(ctx context.Context, ids []string) ([]Dependency, error)
+	FindList(context.Context, string) (List, error)
+	ListLists(ctx context.Context, archived bool) ([]List, error)
+	AddList(context.Context, List) error
+	UpdateList(context.Context, List) error
+	// DeleteList removes the list and all its todo's
+	DeleteList(context.Context, string) error
+	// Apply stores the todo's as they are after the revisions, with their version, all or none.
+	// Every todo must still be as it was before, otherwise it fails with ErrVersionMismatch.
+	Apply(context.Context, []Revision) error
 }
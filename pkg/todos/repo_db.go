@@ -3,28 +3,44 @@ package todos
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
+	"time"
 )
 
 type repositoryDB struct {
-	conn *sql.DB
+	conn dbConn
 }
 
 func NewDbRepository(c *sql.DB) Repository {
-	return &repositoryDB{conn: c}
+	return &repositoryDB{conn: dbConn{c, mysqlDialect}}
 }
 
 func (r *repositoryDB) FindByID(ctx context.Context, id string) (Todo, error) {
 	qry := "select * from v_todos where id = ?"
 	row := r.conn.QueryRowContext(ctx, qry, id)
 
-	return scan(row)
+	td, err := scan(row)
+	if err == sql.ErrNoRows {
+		return Todo{}, ErrNotFound{id}
+	}
+	if err != nil {
+		return Todo{}, err
+	}
+
+	all := []Todo{td}
+	if err := r.load(ctx, all); err != nil {
+		return Todo{}, err
+	}
+
+	return all[0], nil
 }
 
-func (r *repositoryDB) ListAll(ctx context.Context) ([]Todo, error) {
-	qry := "select * from v_todos"
+func (r *repositoryDB) ListAll(ctx context.Context, srt Sort) ([]Todo, error) {
+	qry := "select * from v_todos " + r.conn.orderBy(srt)
 	rows, err := r.conn.QueryContext(ctx, qry)
 	if err != nil {
 		return nil, err
@@ -41,61 +57,492 @@ func (r *repositoryDB) ListAll(ctx context.Context) ([]Todo, error) {
 		}
 	}
 
-	return all, nil
+	return all, r.load(ctx, all)
+}
+
+// FindPage returns the todo's selected by the query. Pages are continued with a keyset condition
+// on the sort columns, so rows inserted or deleted between requests do not shift the pages.
+func (r *repositoryDB) FindPage(ctx context.Context, q Query) (Page, error) {
+	qry := "select * from v_todos"
+	var where []string
+	var args []any
+
+	if q.Filter != nil {
+		cond, condArgs, err := filterSQL(q.Filter)
+		if err != nil {
+			return Page{}, err
+		}
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	if q.After != nil {
+		cond, condArgs := r.conn.keyset(q.After)
+		where = append(where, cond)
+		args = append(args, condArgs...)
+	}
+
+	if len(where) > 0 {
+		qry += " where " + strings.Join(where, " and ")
+	}
+
+	qry += " " + r.conn.orderBy(q.Sort)
+
+	if q.Limit > 0 {
+		// one more to know if there is a next page
+		qry += " limit ?"
+		args = append(args, q.Limit+1)
+	}
+
+	all, err := r.list(ctx, qry, args...)
+	if err != nil {
+		return Page{}, err
+	}
+
+	return paginate(all, Query{Sort: q.Sort, Limit: q.Limit}), nil
 }
 
 func (r *repositoryDB) Add(ctx context.Context, t Todo) error {
-	qry := "insert into todos (id, title, tags) values (?, ?, ?)"
+	return r.inTx(ctx, func(tx dbTx) error {
+		if err := checkList(ctx, tx, t.ListID); err != nil {
+			return err
+		}
+		if err := checkParent(ctx, tx, t.ID, t.ParentID); err != nil {
+			return err
+		}
 
-	_, err := r.conn.ExecContext(ctx, qry, t.ID, t.Title, t.CleanTags())
+		checklist, err := nullChecklist(t.Checklist)
+		if err != nil {
+			return err
+		}
 
-	return err
+		qry := `insert into todos (id, title, description, checklist, list_id, parent_id, priority, completed_at, due_at, due_tz, remind_at, remind_tz,
+			recurrence, series_id, occurrence) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+		_, err = tx.ExecContext(ctx, qry, t.ID, t.Title, t.Description, checklist, nullString(t.ListID), nullString(t.ParentID), t.Priority,
+			nullTime(t.CompletedAt), nullTime(t.DueAt), nullZone(t.DueAt), nullTime(t.RemindAt), nullZone(t.RemindAt),
+			nullRecurrence(t.Recurrence), nullString(t.SeriesID), t.Occurrence)
+		if err != nil {
+			return err
+		}
+
+		return setTags(ctx, tx, t.ID, t.UniqueTags())
+	})
+}
+
+// Delete moves the todo and its subtasks to the trash
+func (r *repositoryDB) Delete(ctx context.Context, id string, version int64) error {
+	return r.inTx(ctx, func(tx dbTx) error {
+		now := time.Now().UTC().Truncate(time.Second)
+
+		qry := "update todos set deleted_at = ? where id = ? and deleted_at is null and (? = 0 or version = ?)"
+		res, err := tx.ExecContext(ctx, qry, now, id, version, version)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			// deleting a missing todo is not an error
+			if err := checkVersion(ctx, tx, id); !errors.As(err, new(ErrNotFound)) {
+				return err
+			}
+			return nil
+		}
+
+		ids, err := subtree(ctx, tx, id, "deleted_at is null")
+		if err != nil {
+			return err
+		}
+
+		return inBatches(ids, func(ids []any) error {
+			qry := "update todos set deleted_at = ? where id in (" + placeholders(len(ids)) + ")"
+			_, err := tx.ExecContext(ctx, qry, append([]any{now}, ids...)...)
+			return err
+		})
+	})
 }
 
-func (r *repositoryDB) Delete(ctx context.Context, id string) error {
-	qry := "delete from todos where id = ?"
+// subtree returns the subtasks at any depth that match the condition, without going through
+// the subtasks that do not match it
+func subtree(ctx context.Context, tx dbTx, id string, cond string, args ...any) ([]Todo, error) {
+	var all []Todo
+	for next := []any{id}; len(next) > 0; {
+		qry := "select id from todos where parent_id in (" + placeholders(len(next)) + ") and " + cond
+		rows, err := tx.QueryContext(ctx, qry, append(next, args...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		next = nil
+		for rows.Next() {
+			var child string
+			if err := rows.Scan(&child); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			next = append(next, child)
+			all = append(all, Todo{ID: child})
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
 
-	_, err := r.conn.ExecContext(ctx, qry, id)
+	return all, nil
+}
 
-	return err
+// ListTrash returns the deleted todo's, the last deleted first
+func (r *repositoryDB) ListTrash(ctx context.Context) ([]Todo, error) {
+	return r.list(ctx, "select * from v_trash order by deleted_at desc, id")
+}
+
+// Restore takes the todo out of the trash, together with the subtasks that were deleted with it
+func (r *repositoryDB) Restore(ctx context.Context, id string) error {
+	return r.inTx(ctx, func(tx dbTx) error {
+		var parentID sql.NullString
+		var deletedAt time.Time
+		qry := "select parent_id, deleted_at from todos where id = ? and deleted_at is not null for update"
+		err := tx.QueryRowContext(ctx, qry, id).Scan(&parentID, &deletedAt)
+		if err == sql.ErrNoRows {
+			return ErrNotFound{id}
+		}
+		if err != nil {
+			return err
+		}
+
+		if parentID.Valid {
+			var found string
+			err := tx.QueryRowContext(ctx, "select id from todos where id = ? and deleted_at is null", parentID.String).Scan(&found)
+			if err == sql.ErrNoRows {
+				return ErrParentNotFound{parentID.String}
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// subtasks deleted before their parent stay in the trash
+		ids, err := subtree(ctx, tx, id, "deleted_at = ?", deletedAt)
+		if err != nil {
+			return err
+		}
+
+		return inBatches(append(ids, Todo{ID: id}), func(ids []any) error {
+			qry := "update todos set deleted_at = null, version = version + 1 where id in (" + placeholders(len(ids)) + ")"
+			_, err := tx.ExecContext(ctx, qry, ids...)
+			return err
+		})
+	})
+}
+
+// Purge removes the todo's that were deleted before the time for good.
+// Their tags, dependencies and subtasks are removed by the foreign keys. The rows removed by the
+// foreign keys are not in the rows affected, so the todo's are counted with their subtasks first.
+func (r *repositoryDB) Purge(ctx context.Context, before time.Time) (int, error) {
+	var n int
+	err := r.inTx(ctx, func(tx dbTx) error {
+		qry := `with recursive tree (id) as (
+				select id from todos where deleted_at < ?
+				union
+				select t.id from todos t join tree on t.parent_id = tree.id
+			)
+			select count(*) from tree`
+		if err := tx.QueryRowContext(ctx, qry, before).Scan(&n); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, "delete from todos where deleted_at < ?", before)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return n, nil
 }
 
 func (r *repositoryDB) Update(ctx context.Context, id string, t Todo) error {
-	fmt.Printf("Updated todo: %#v\n", t)
-	qry := "update todos set title = ?, tags = ?, completed_at = ? where id = ?"
+	return r.inTx(ctx, func(tx dbTx) error {
+		if err := checkList(ctx, tx, t.ListID); err != nil {
+			return err
+		}
+		if err := checkParent(ctx, tx, id, t.ParentID); err != nil {
+			return err
+		}
 
-	_, err := r.conn.ExecContext(ctx, qry, t.Title, t.CleanTags(), sql.NullTime{Time: *t.CompletedAt, Valid: t.CompletedAt != nil}, t.ID)
+		checklist, err := nullChecklist(t.Checklist)
+		if err != nil {
+			return err
+		}
+
+		qry := `update todos set title = ?, description = ?, checklist = ?, list_id = ?, parent_id = ?, priority = ?, completed_at = ?,
+			due_at = ?, due_tz = ?, remind_at = ?, remind_tz = ?,
+			recurrence = ?, series_id = ?, occurrence = ?, version = version + 1
+			where id = ? and deleted_at is null and (? = 0 or version = ?)`
+
+		res, err := tx.ExecContext(ctx, qry, t.Title, t.Description, checklist, nullString(t.ListID), nullString(t.ParentID), t.Priority, nullTime(t.CompletedAt),
+			nullTime(t.DueAt), nullZone(t.DueAt), nullTime(t.RemindAt), nullZone(t.RemindAt),
+			nullRecurrence(t.Recurrence), nullString(t.SeriesID), t.Occurrence,
+			id, t.Version, t.Version)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return checkVersion(ctx, tx, id)
+		}
+
+		return setTags(ctx, tx, id, t.UniqueTags())
+	})
+}
+
+// checkVersion explains why a todo was not changed: it does not exist or it has another version
+func checkVersion(ctx context.Context, tx dbTx, id string) error {
+	var version int64
+	err := tx.QueryRowContext(ctx, "select version from todos where id = ? and deleted_at is null", id).Scan(&version)
+	if err == sql.ErrNoRows {
+		return ErrNotFound{id}
+	}
 	if err != nil {
-		fmt.Printf("Update error: %v", err)
+		return err
 	}
 
-	return err
+	return ErrVersionMismatch{id}
 }
 
-// FindByTag returns all Todo's that contain this tag
-// Logic should be improved, maybe refactoring the whole tags logic. At the moment it will return
-// entries containing `golang` when `go` is passed in as parameter
-func (r *repositoryDB) FindByTag(ctx context.Context, tg string) ([]Todo, error) {
-	qry := "select * from v_todos where tags like ?"
+// checkParent walks up from the parent to make sure the todo is not one of its ancestors.
+// The ancestors are locked, so two concurrent moves cannot create a cycle.
+func checkParent(ctx context.Context, tx dbTx, id, parentID string) error {
+	for p := parentID; p != ""; {
+		if p == id {
+			return ErrCycle{id}
+		}
+
+		var next sql.NullString
+		err := tx.QueryRowContext(ctx, "select parent_id from todos where id = ? and deleted_at is null for update", p).Scan(&next)
+		if err == sql.ErrNoRows {
+			return ErrParentNotFound{p}
+		}
+		if err != nil {
+			return err
+		}
+		p = next.String
+	}
+
+	return nil
+}
+
+// FindDescendants returns the subtasks of the todo at any depth
+func (r *repositoryDB) FindDescendants(ctx context.Context, id string) ([]Todo, error) {
+	qry := `select * from v_todos where id in (
+			with recursive tree (id) as (
+				select id from todos where parent_id = ?
+				union all
+				select t.id from todos t join tree on t.parent_id = tree.id
+			)
+			select id from tree
+		) order by id`
+
+	return r.list(ctx, qry, id)
+}
+
+// FindByTag returns all Todo's that have exactly this tag
+func (r *repositoryDB) FindByTag(ctx context.Context, tg string, srt Sort) ([]Todo, error) {
+	qry := "select * from v_todos where id in (" + withTagSQL + ") " + r.conn.orderBy(srt)
+
+	return r.list(ctx, qry, tg)
+}
+
+// FindDue returns the todo's with a due date in [after, before). A zero time leaves that end open.
+func (r *repositoryDB) FindDue(ctx context.Context, after, before time.Time) ([]Todo, error) {
+	qry := "select * from v_todos where due_at is not null"
+	var args []any
+
+	if !after.IsZero() {
+		qry += " and due_at >= ?"
+		args = append(args, after)
+	}
+	if !before.IsZero() {
+		qry += " and due_at < ?"
+		args = append(args, before)
+	}
+
+	return r.list(ctx, qry, args...)
+}
+
+func (r *repositoryDB) FindOverdue(ctx context.Context, at time.Time) ([]Todo, error) {
+	qry := "select * from v_todos where completed_at is null and due_at < ?"
+
+	return r.list(ctx, qry, at)
+}
+
+func (r *repositoryDB) FindReminders(ctx context.Context, at time.Time) ([]Todo, error) {
+	qry := "select * from v_todos where completed_at is null and remind_at <= ?"
+
+	return r.list(ctx, qry, at)
+}
 
-	rows, err := r.conn.QueryContext(ctx, qry, "%"+tg+"%")
+func (r *repositoryDB) list(ctx context.Context, qry string, args ...any) ([]Todo, error) {
+	rows, err := r.conn.QueryContext(ctx, qry, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var all []Todo
+	all := make([]Todo, 0)
 	for rows.Next() {
 		td, err := scan(rows)
 		if err != nil {
 			return all, err
-		} else {
-			all = append(all, td)
 		}
+		all = append(all, td)
+	}
+	if err := rows.Err(); err != nil {
+		return all, err
 	}
 
-	return all, nil
+	return all, r.load(ctx, all)
+}
+
+// withTagSQL selects the IDs of the todo's with a tag
+const withTagSQL = "select tt.todo_id from todo_tags tt join tags tg on tg.id = tt.tag_id where tg.name = ?"
+
+// loadBatch limits the number of placeholders in the queries that load the tags and blockers
+const loadBatch = 500
+
+// inBatches calls f with the IDs of the todo's, at most loadBatch at a time
+func inBatches(todos []Todo, f func(ids []any) error) error {
+	for start := 0; start < len(todos); start += loadBatch {
+		end := start + loadBatch
+		if end > len(todos) {
+			end = len(todos)
+		}
 
+		var ids []any
+		for _, td := range todos[start:end] {
+			ids = append(ids, td.ID)
+		}
+
+		if err := f(ids); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// placeholders returns "?, ?, ..." for n values
+func placeholders(n int) string {
+	return "?" + strings.Repeat(", ?", n-1)
+}
+
+// load sets the tags and the open blockers of the todo's
+func (r *repositoryDB) load(ctx context.Context, todos []Todo) error {
+	if err := r.loadTags(ctx, todos); err != nil {
+		return err
+	}
+
+	return r.loadBlockers(ctx, todos)
+}
+
+// loadTags sets the tags of the todo's, sorted by name
+func (r *repositoryDB) loadTags(ctx context.Context, todos []Todo) error {
+	idx := make(map[string]int, len(todos))
+	for i := range todos {
+		todos[i].Tags = []string{}
+		idx[todos[i].ID] = i
+	}
+
+	return inBatches(todos, func(ids []any) error {
+		qry := `select tt.todo_id, tg.name from todo_tags tt join tags tg on tg.id = tt.tag_id
+			where tt.todo_id in (` + placeholders(len(ids)) + `) order by tg.name`
+
+		rows, err := r.conn.QueryContext(ctx, qry, ids...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id, name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+			todos[idx[id]].Tags = append(todos[idx[id]].Tags, name)
+		}
+
+		return rows.Err()
+	})
+}
+
+// loadBlockers sets the IDs of the open todo's that block the todo's
+func (r *repositoryDB) loadBlockers(ctx context.Context, todos []Todo) error {
+	idx := make(map[string]int, len(todos))
+	for i := range todos {
+		todos[i].BlockedBy = nil
+		idx[todos[i].ID] = i
+	}
+
+	return inBatches(todos, func(ids []any) error {
+		qry := `select d.todo_id, d.blocker_id from todo_dependencies d join todos b on b.id = d.blocker_id
+			where b.completed_at is null and b.deleted_at is null and d.todo_id in (` + placeholders(len(ids)) + `) order by d.blocker_id`
+
+		rows, err := r.conn.QueryContext(ctx, qry, ids...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var id, blocker string
+			if err := rows.Scan(&id, &blocker); err != nil {
+				return err
+			}
+			todos[idx[id]].BlockedBy = append(todos[idx[id]].BlockedBy, blocker)
+		}
+
+		return rows.Err()
+	})
+}
+
+// setTags replaces the tags of a todo, creating the tags that do not exist yet
+func setTags(ctx context.Context, tx dbTx, id string, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "delete from todo_tags where todo_id = ?", id); err != nil {
+		return err
+	}
+
+	for _, tg := range tags {
+		if _, err := tx.ExecContext(ctx, tx.insertIgnore("tags (name) values (?)"), tg); err != nil {
+			return err
+		}
+
+		qry := "insert into todo_tags (todo_id, tag_id) select t.id, tg.id from todos t, tags tg where t.id = ? and tg.name = ?"
+		if _, err := tx.ExecContext(ctx, qry, id, tg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inTx runs f in a transaction, which is committed if f does not return an error
+func (r *repositoryDB) inTx(ctx context.Context, f func(dbTx) error) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := f(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Scanner is a constraint that matches sql.Row and sql.Rows
@@ -104,24 +551,408 @@ type Scanner interface {
 }
 
 func scan[T Scanner](r T) (Todo, error) {
-	var id, title, tags string
-	var completedAt sql.NullTime
-	vals := []any{&id, &title, &tags, &completedAt}
+	var id, title, dueTz, remindTz, description string
+	var listID, parentID, recurrence, seriesID, checklist sql.NullString
+	var priority Priority
+	var version int64
+	var occurrence int
+	var completedAt, dueAt, remindAt, deletedAt sql.NullTime
+	vals := []any{&id, &title, &priority, &completedAt, &dueAt, &dueTz, &remindAt, &remindTz, &version, &listID, &parentID,
+		&recurrence, &seriesID, &occurrence, &deletedAt, &description, &checklist}
 
 	if err := r.Scan(vals...); err != nil {
 		log.Printf("Scan error: %v\n", err)
 		return Todo{}, err
 	}
 
-	completedWhen := &completedAt.Time
-	if !completedAt.Valid {
-		completedWhen = nil
+	var rec *Recurrence
+	if recurrence.Valid {
+		parsed, err := ParseRecurrence(recurrence.String)
+		if err != nil {
+			return Todo{}, err
+		}
+		rec = &parsed
+	}
+
+	var items []ChecklistItem
+	if checklist.Valid {
+		if err := json.Unmarshal([]byte(checklist.String), &items); err != nil {
+			return Todo{}, err
+		}
 	}
 
 	return Todo{
 		ID:          id,
 		Title:       title,
-		Tags:        strings.Split(tags, ","),
-		CompletedAt: completedWhen,
+		Description: description,
+		Checklist:   items,
+		ListID:      listID.String,
+		ParentID:    parentID.String,
+		Recurrence:  rec,
+		SeriesID:    seriesID.String,
+		Occurrence:  occurrence,
+		DeletedAt:   zonedTime(deletedAt, "Z"),
+		Priority:    priority,
+		CompletedAt: zonedTime(completedAt, "Z"),
+		DueAt:       zonedTime(dueAt, dueTz),
+		RemindAt:    zonedTime(remindAt, remindTz),
+		Version:     version,
 	}, nil
 }
+
+type sortColumn struct {
+	expr string
+	// value returns the value of expr for a todo. nil is NULL.
+	value func(Todo) any
+	// text is compared by its bytes, like strings.Compare, whatever the collation of the database
+	text bool
+	// ascending keeps the order of the column in a descending sort, so the missing dates stay last
+	ascending bool
+}
+
+// sortColumns maps the sort fields to the expressions that order them in the same way as Sort.Compare
+var sortColumns = map[SortField][]sortColumn{
+	SortByID:       {{expr: "id", value: func(t Todo) any { return t.ID }}},
+	SortByTitle:    {{expr: "lower(title)", value: func(t Todo) any { return strings.ToLower(t.Title) }, text: true}},
+	SortByPriority: {{expr: "priority", value: func(t Todo) any { return int(t.Priority) }}},
+	SortByDueAt: {
+		{expr: "(due_at is null)", value: func(t Todo) any { return t.DueAt == nil }, ascending: true},
+		{expr: "due_at", value: func(t Todo) any { return timeValue(t.DueAt) }},
+	},
+	SortByCompletedAt: {
+		{expr: "(completed_at is null)", value: func(t Todo) any { return t.CompletedAt == nil }, ascending: true},
+		{expr: "completed_at", value: func(t Todo) any { return timeValue(t.CompletedAt) }},
+	},
+}
+
+// sortColumns returns the columns of the sort, with the ID last, and whether each is in descending order
+func (d dialect) sortColumns(srt Sort) ([]sortColumn, []bool) {
+	var cols []sortColumn
+	var desc []bool
+	for _, k := range append(srt, SortKey{Field: SortByID}) {
+		for _, c := range sortColumns[k.Field] {
+			if c.text {
+				c.expr = d.binary(c.expr)
+			}
+			cols = append(cols, c)
+			desc = append(desc, k.Desc && !c.ascending)
+		}
+	}
+
+	return cols, desc
+}
+
+func (d dialect) orderBy(srt Sort) string {
+	sortCols, desc := d.sortColumns(srt)
+
+	var cols []string
+	for i, c := range sortCols {
+		if desc[i] {
+			cols = append(cols, c.expr+" desc")
+		} else {
+			cols = append(cols, c.expr)
+		}
+	}
+
+	return "order by " + strings.Join(cols, ", ")
+}
+
+// keyset returns the condition that keeps the rows ordered after the cursor:
+// (a > ?) or (a = ? and b > ?) or (a = ? and b = ? and c > ?) ...
+func (d dialect) keyset(c *Cursor) (string, []any) {
+	type col struct {
+		sortColumn
+		desc bool
+	}
+
+	sortCols, desc := d.sortColumns(c.sort)
+	var cols []col
+	for i, sc := range sortCols {
+		cols = append(cols, col{sc, desc[i]})
+	}
+
+	var anyOf []string
+	var args []any
+	for i, cl := range cols {
+		v := cl.value(c.last)
+		if v == nil {
+			// nothing comes after NULL on this column
+			continue
+		}
+
+		var allOf []string
+		for _, prev := range cols[:i] {
+			if pv := prev.value(c.last); pv == nil {
+				allOf = append(allOf, prev.expr+" is null")
+			} else {
+				allOf = append(allOf, prev.expr+" = ?")
+				args = append(args, pv)
+			}
+		}
+
+		if cl.desc {
+			allOf = append(allOf, cl.expr+" < ?")
+		} else {
+			allOf = append(allOf, cl.expr+" > ?")
+		}
+		args = append(args, v)
+
+		anyOf = append(anyOf, "("+strings.Join(allOf, " and ")+")")
+	}
+
+	return "(" + strings.Join(anyOf, " or ") + ")", args
+}
+
+// filterSQL translates a filter expression into a where condition.
+// Conditions on nullable columns are false instead of NULL, so NOT works like in Expr.Match.
+func filterSQL(e Expr) (string, []any, error) {
+	switch e := e.(type) {
+	case AndExpr:
+		return joinSQL(" and ", e.Left, e.Right)
+
+	case OrExpr:
+		return joinSQL(" or ", e.Left, e.Right)
+
+	case NotExpr:
+		cond, args, err := filterSQL(e.X)
+		return "not " + cond, args, err
+
+	case TagCond:
+		return "id in (" + withTagSQL + ")", []any{e.Tag}, nil
+
+	case TitleCond:
+		if e.Contains {
+			return "lower(title) like ? escape '!'", []any{"%" + escapeLike(e.Text) + "%"}, nil
+		}
+		return "lower(title) = ?", []any{e.Text}, nil
+
+	case CompletedCond:
+		if e.Completed {
+			return "completed_at is not null", nil, nil
+		}
+		return "completed_at is null", nil, nil
+
+	case ListCond:
+		if e.ListID == "" {
+			return "list_id is null", nil, nil
+		}
+		return "list_id = ?", []any{e.ListID}, nil
+
+	case BlockedCond:
+		cond := "exists (" + openBlockerSQL + ")"
+		if !e.Blocked {
+			cond = "not " + cond
+		}
+		return cond, nil, nil
+
+	case SeriesCond:
+		if e.SeriesID == "" {
+			return "series_id is null", nil, nil
+		}
+		return "series_id = ?", []any{e.SeriesID}, nil
+
+	case ParentCond:
+		if e.ParentID == "" {
+			return "parent_id is null", nil, nil
+		}
+		return "parent_id = ?", []any{e.ParentID}, nil
+
+	case PriorityCond:
+		if !isComparison(e.Op) {
+			break
+		}
+		return "priority " + e.Op + " ?", []any{int(e.Priority)}, nil
+
+	case TimeCond:
+		if !isComparison(e.Op) || (e.Field != SortByDueAt && e.Field != SortByCompletedAt) {
+			break
+		}
+		col := string(e.Field)
+		return "(" + col + " is not null and " + col + " " + e.Op + " ?)", []any{e.Time}, nil
+	}
+
+	return "", nil, fmt.Errorf("filter not supported: %v", e)
+}
+
+func joinSQL(op string, l, r Expr) (string, []any, error) {
+	left, lArgs, err := filterSQL(l)
+	if err != nil {
+		return "", nil, err
+	}
+	right, rArgs, err := filterSQL(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "(" + left + op + right + ")", append(lArgs, rArgs...), nil
+}
+
+func isComparison(op string) bool {
+	switch op {
+	case "=", "<", "<=", ">", ">=":
+		return true
+	}
+	return false
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(s)
+}
+
+func timeValue(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+
+	return *t
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullRecurrence(r *Recurrence) sql.NullString {
+	if r == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: r.String(), Valid: true}
+}
+
+// nullChecklist stores the checklist as a JSON array, an empty checklist as NULL
+func nullChecklist(items []ChecklistItem) (sql.NullString, error) {
+	if len(items) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(items)
+
+	return sql.NullString{String: string(b), Valid: err == nil}, err
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func nullZone(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return zoneOf(*t)
+}
+
+// zonedTime is the reverse of nullTime and nullZone
+func zonedTime(t sql.NullTime, zone string) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	zoned := inZone(t.Time, zone)
+	return &zoned
+}
+
+// ListTags returns the tags used by at least one todo, sorted by name
+func (r *repositoryDB) ListTags(ctx context.Context) ([]TagCount, error) {
+	qry := `select tg.name,
+			sum(case when t.completed_at is null then 1 else 0 end),
+			sum(case when t.completed_at is null then 0 else 1 end)
+		from tags tg
+		join todo_tags tt on tt.tag_id = tg.id
+		join v_todos t on t.id = tt.todo_id
+		group by tg.name
+		order by tg.name`
+
+	rows, err := r.conn.QueryContext(ctx, qry)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make([]TagCount, 0)
+	for rows.Next() {
+		var c TagCount
+		if err := rows.Scan(&c.Name, &c.Open, &c.Completed); err != nil {
+			return all, err
+		}
+		all = append(all, c)
+	}
+
+	return all, rows.Err()
+}
+
+// RenameTag changes the tag on all the todo's. Renaming to a tag that is already used is an error.
+func (r *repositoryDB) RenameTag(ctx context.Context, from, to string) error {
+	return r.inTx(ctx, func(tx dbTx) error {
+		if from != to {
+			used, err := tagID(ctx, tx, to)
+			if err != nil {
+				return err
+			}
+			if used != 0 {
+				return ErrTagExists{to}
+			}
+		}
+
+		return mergeTagTx(ctx, tx, from, to)
+	})
+}
+
+// MergeTag replaces the tag `from` with `into` on all the todo's
+func (r *repositoryDB) MergeTag(ctx context.Context, from, into string) error {
+	return r.inTx(ctx, func(tx dbTx) error {
+		return mergeTagTx(ctx, tx, from, into)
+	})
+}
+
+func mergeTagTx(ctx context.Context, tx dbTx, from, into string) error {
+	fromID, err := tagID(ctx, tx, from)
+	if err != nil {
+		return err
+	}
+	if fromID == 0 {
+		return ErrTagNotFound{from}
+	}
+	if from == into {
+		return nil
+	}
+
+	// the tags are part of the todo's, so their version changes
+	qry := "update todos set version = version + 1 where id in (select todo_id from todo_tags where tag_id = ?)"
+	if _, err := tx.ExecContext(ctx, qry, fromID); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, tx.insertIgnore("tags (name) values (?)"), into); err != nil {
+		return err
+	}
+
+	qry = tx.insertIgnore(`todo_tags (todo_id, tag_id)
+		select tt.todo_id, tg.id from todo_tags tt, tags tg where tt.tag_id = ? and tg.name = ?`)
+	if _, err := tx.ExecContext(ctx, qry, fromID, into); err != nil {
+		return err
+	}
+
+	// the links to the old tag are removed by the foreign key
+	_, err = tx.ExecContext(ctx, "delete from tags where id = ?", fromID)
+
+	return err
+}
+
+// tagID locks and returns the ID of a tag that is used by at least one todo. 0 means it is not used.
+func tagID(ctx context.Context, tx dbTx, name string) (int64, error) {
+	qry := `select id from tags where name = ? and exists (
+			select 1 from todo_tags tt join todos t on t.id = tt.todo_id where tt.tag_id = tags.id and t.deleted_at is null
+		) for update`
+
+	var id int64
+	err := tx.QueryRowContext(ctx, qry, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return id, err
+}
@@ -0,0 +1,104 @@
+package todos
+
+import (
+	"context"
+	"database/sql"
+)
+
+type attachmentsDB struct {
+	conn dbConn
+}
+
+func NewDbAttachments(c *sql.DB) AttachmentRepository {
+	return &attachmentsDB{conn: dbConn{c, mysqlDialect}}
+}
+
+const attachmentColumns = "id, todo_id, name, content_type, size, sha256, created_at"
+
+func (a *attachmentsDB) AddAttachment(ctx context.Context, at Attachment) error {
+	qry := "insert into attachments (" + attachmentColumns + ") values (?, ?, ?, ?, ?, ?, ?)"
+	_, err := a.conn.ExecContext(ctx, qry, at.ID, at.TodoID, at.Name, at.ContentType, at.Size, at.SHA256, at.CreatedAt.UTC())
+
+	return err
+}
+
+func (a *attachmentsDB) FindAttachment(ctx context.Context, id string) (Attachment, error) {
+	all, err := a.find(ctx, "select "+attachmentColumns+" from attachments where id = ?", id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if len(all) == 0 {
+		return Attachment{}, ErrAttachmentNotFound{id}
+	}
+
+	return all[0], nil
+}
+
+func (a *attachmentsDB) FindAttachments(ctx context.Context, todoID string) ([]Attachment, error) {
+	return a.find(ctx, "select "+attachmentColumns+" from attachments where todo_id = ? order by created_at, id", todoID)
+}
+
+func (a *attachmentsDB) find(ctx context.Context, qry string, args ...any) ([]Attachment, error) {
+	rows, err := a.conn.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make([]Attachment, 0)
+	for rows.Next() {
+		var at Attachment
+		if err := rows.Scan(&at.ID, &at.TodoID, &at.Name, &at.ContentType, &at.Size, &at.SHA256, &at.CreatedAt); err != nil {
+			return nil, err
+		}
+		at.CreatedAt = at.CreatedAt.UTC()
+		all = append(all, at)
+	}
+
+	return all, rows.Err()
+}
+
+func (a *attachmentsDB) DeleteAttachment(ctx context.Context, id string) error {
+	res, err := a.conn.ExecContext(ctx, "delete from attachments where id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAttachmentNotFound{id}
+	}
+
+	return nil
+}
+
+func (a *attachmentsDB) DeleteAttachments(ctx context.Context, todoIDs []string) ([]Attachment, error) {
+	todos := make([]Todo, len(todoIDs))
+	for i, id := range todoIDs {
+		todos[i] = Todo{ID: id}
+	}
+
+	var removed []Attachment
+	err := inBatches(todos, func(ids []any) error {
+		in := " where todo_id in (" + placeholders(len(ids)) + ")"
+		found, err := a.find(ctx, "select "+attachmentColumns+" from attachments"+in, ids...)
+		if err != nil {
+			return err
+		}
+		if _, err := a.conn.ExecContext(ctx, "delete from attachments"+in, ids...); err != nil {
+			return err
+		}
+		removed = append(removed, found...)
+		return nil
+	})
+
+	return removed, err
+}
+
+func (a *attachmentsDB) CountBySHA256(ctx context.Context, sum string) (int, error) {
+	var n int
+	err := a.conn.QueryRowContext(ctx, "select count(*) from attachments where sha256 = ?", sum).Scan(&n)
+
+	return n, err
+}
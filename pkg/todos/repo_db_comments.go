@@ -0,0 +1,167 @@
+package todos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type commentsDB struct {
+	conn dbConn
+}
+
+func NewDbComments(c *sql.DB) CommentRepository {
+	return &commentsDB{conn: dbConn{c, mysqlDialect}}
+}
+
+func (c *commentsDB) AddComment(ctx context.Context, cm Comment) error {
+	qry := "insert into comments (id, todo_id, author, body, created_at) values (?, ?, ?, ?, ?)"
+	_, err := c.conn.ExecContext(ctx, qry, cm.ID, cm.TodoID, cm.Author, cm.Body, cm.CreatedAt.UTC())
+
+	return err
+}
+
+func (c *commentsDB) FindComment(ctx context.Context, id string) (Comment, error) {
+	all, err := c.find(ctx, "id = ?", id)
+	if err != nil {
+		return Comment{}, err
+	}
+	if len(all) == 0 {
+		return Comment{}, ErrCommentNotFound{id}
+	}
+
+	return all[0], nil
+}
+
+func (c *commentsDB) FindComments(ctx context.Context, todoID string) ([]Comment, error) {
+	return c.find(ctx, "todo_id = ?", todoID)
+}
+
+// find returns the comments that match the condition and are not trashed, with their edits
+func (c *commentsDB) find(ctx context.Context, cond string, args ...any) ([]Comment, error) {
+	qry := "select id, todo_id, author, body, created_at, updated_at from comments where deleted_at is null and " + cond +
+		" order by created_at, id"
+
+	rows, err := c.conn.QueryContext(ctx, qry, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make([]Comment, 0)
+	idx := make(map[string]int)
+	for rows.Next() {
+		var cm Comment
+		var updatedAt sql.NullTime
+		if err := rows.Scan(&cm.ID, &cm.TodoID, &cm.Author, &cm.Body, &cm.CreatedAt, &updatedAt); err != nil {
+			return nil, err
+		}
+		cm.CreatedAt = cm.CreatedAt.UTC()
+		cm.UpdatedAt = zonedTime(updatedAt, "Z")
+		idx[cm.ID] = len(all)
+		all = append(all, cm)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(all) == 0 {
+		return all, nil
+	}
+
+	ids := make([]any, 0, len(all))
+	for _, cm := range all {
+		ids = append(ids, cm.ID)
+	}
+
+	qry = "select comment_id, body, edited_at from comment_edits where comment_id in (" + placeholders(len(ids)) + ") order by id"
+	edits, err := c.conn.QueryContext(ctx, qry, ids...)
+	if err != nil {
+		return nil, err
+	}
+	defer edits.Close()
+
+	for edits.Next() {
+		var id string
+		var e CommentEdit
+		if err := edits.Scan(&id, &e.Body, &e.EditedAt); err != nil {
+			return nil, err
+		}
+		e.EditedAt = e.EditedAt.UTC()
+		all[idx[id]].Edits = append(all[idx[id]].Edits, e)
+	}
+
+	return all, edits.Err()
+}
+
+func (c *commentsDB) UpdateComment(ctx context.Context, id, body string, at time.Time) error {
+	tx, err := c.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, "select body from comments where id = ? and deleted_at is null for update", id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return ErrCommentNotFound{id}
+	}
+	if err != nil {
+		return err
+	}
+
+	qry := "insert into comment_edits (comment_id, body, edited_at) values (?, ?, ?)"
+	if _, err := tx.ExecContext(ctx, qry, id, previous, at.UTC()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "update comments set body = ?, updated_at = ? where id = ?", body, at.UTC(), id); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (c *commentsDB) DeleteComment(ctx context.Context, id string) error {
+	res, err := c.conn.ExecContext(ctx, "delete from comments where id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrCommentNotFound{id}
+	}
+
+	return nil
+}
+
+func (c *commentsDB) TrashComments(ctx context.Context, todoIDs []string, at time.Time) error {
+	return c.inBatches(todoIDs, func(ids []any) error {
+		qry := "update comments set deleted_at = ? where deleted_at is null and todo_id in (" + placeholders(len(ids)) + ")"
+		_, err := c.conn.ExecContext(ctx, qry, append([]any{at.UTC()}, ids...)...)
+		return err
+	})
+}
+
+func (c *commentsDB) RestoreComments(ctx context.Context, todoIDs []string) error {
+	return c.inBatches(todoIDs, func(ids []any) error {
+		qry := "update comments set deleted_at = null where todo_id in (" + placeholders(len(ids)) + ")"
+		_, err := c.conn.ExecContext(ctx, qry, ids...)
+		return err
+	})
+}
+
+// PurgeComments removes the trashed comments. Those of the purged todo's are removed by the foreign key already.
+func (c *commentsDB) PurgeComments(ctx context.Context, before time.Time) error {
+	_, err := c.conn.ExecContext(ctx, "delete from comments where deleted_at < ?", before)
+	return err
+}
+
+func (c *commentsDB) inBatches(todoIDs []string, f func(ids []any) error) error {
+	todos := make([]Todo, len(todoIDs))
+	for i, id := range todoIDs {
+		todos[i] = Todo{ID: id}
+	}
+
+	return inBatches(todos, f)
+}
@@ -0,0 +1,133 @@
+package todos
+
+import (
+	"context"
+	"database/sql"
+)
+
+// openBlockerSQL selects the open todo's that block the todo of the outer query
+const openBlockerSQL = `select 1 from todo_dependencies d join todos b on b.id = d.blocker_id
+	where d.todo_id = v_todos.id and b.completed_at is null and b.deleted_at is null`
+
+// AddDependency locks both todo's, so a concurrent change that depends on one of them waits
+func (r *repositoryDB) AddDependency(ctx context.Context, d Dependency) error {
+	return r.inTx(ctx, func(tx dbTx) error {
+		for _, id := range []string{d.TodoID, d.BlockerID} {
+			var found string
+			err := tx.QueryRowContext(ctx, "select id from todos where id = ? and deleted_at is null for update", id).Scan(&found)
+			if err == sql.ErrNoRows {
+				return ErrNotFound{id}
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		cycle, err := dependsOn(ctx, tx, d.BlockerID, d.TodoID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrDependencyCycle{d.TodoID, d.BlockerID}
+		}
+
+		qry := tx.insertIgnore("todo_dependencies (todo_id, blocker_id) values (?, ?)")
+		_, err = tx.ExecContext(ctx, qry, d.TodoID, d.BlockerID)
+
+		return err
+	})
+}
+
+// dependsOn reports whether the todo is, or depends on, the other todo at any distance
+func dependsOn(ctx context.Context, tx dbTx, id, other string) (bool, error) {
+	seen := make(map[string]bool)
+	for next := []string{id}; len(next) > 0; {
+		var args []any
+		for _, n := range next {
+			if n == other {
+				return true, nil
+			}
+			if !seen[n] {
+				seen[n] = true
+				args = append(args, n)
+			}
+		}
+		if len(args) == 0 {
+			break
+		}
+
+		qry := "select blocker_id from todo_dependencies where todo_id in (" + placeholders(len(args)) + ")"
+		rows, err := tx.QueryContext(ctx, qry, args...)
+		if err != nil {
+			return false, err
+		}
+
+		next = next[:0]
+		for rows.Next() {
+			var b string
+			if err := rows.Scan(&b); err != nil {
+				rows.Close()
+				return false, err
+			}
+			next = append(next, b)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+// RemoveDependency removes the dependency. Removing a missing dependency is not an error.
+func (r *repositoryDB) RemoveDependency(ctx context.Context, d Dependency) error {
+	qry := "delete from todo_dependencies where todo_id = ? and blocker_id = ?"
+	_, err := r.conn.ExecContext(ctx, qry, d.TodoID, d.BlockerID)
+
+	return err
+}
+
+// FindDependencies leaves out the dependencies with a todo in the trash
+func (r *repositoryDB) FindDependencies(ctx context.Context, ids []string) ([]Dependency, error) {
+	todos := make([]Todo, len(ids))
+	for i, id := range ids {
+		todos[i].ID = id
+	}
+
+	found := make(map[Dependency]bool)
+	err := inBatches(todos, func(ids []any) error {
+		in := "(" + placeholders(len(ids)) + ")"
+		qry := `select d.todo_id, d.blocker_id from todo_dependencies d
+			join v_todos t on t.id = d.todo_id
+			join v_todos b on b.id = d.blocker_id
+			where d.todo_id in ` + in + " or d.blocker_id in " + in
+
+		rows, err := r.conn.QueryContext(ctx, qry, append(ids, ids...)...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var d Dependency
+			if err := rows.Scan(&d.TodoID, &d.BlockerID); err != nil {
+				return err
+			}
+			found[d] = true
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]Dependency, 0, len(found))
+	for d := range found {
+		all = append(all, d)
+	}
+	sortDependencies(all)
+
+	return all, nil
+}
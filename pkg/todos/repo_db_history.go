@@ -0,0 +1,53 @@
+package todos
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+type historyDB struct {
+	conn dbConn
+}
+
+func NewDbHistory(c *sql.DB) HistoryRepository {
+	return &historyDB{conn: dbConn{c, mysqlDialect}}
+}
+
+func (h *historyDB) AddEvent(ctx context.Context, e Event) error {
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return err
+	}
+
+	qry := "insert into todo_events (todo_id, action, actor, at, changes) values (?, ?, ?, ?, ?)"
+	_, err = h.conn.ExecContext(ctx, qry, e.TodoID, e.Action, e.Actor, e.At.UTC(), string(changes))
+
+	return err
+}
+
+func (h *historyDB) FindEvents(ctx context.Context, id string) ([]Event, error) {
+	qry := "select id, todo_id, action, actor, at, changes from todo_events where todo_id = ? order by id"
+
+	rows, err := h.conn.QueryContext(ctx, qry, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make([]Event, 0)
+	for rows.Next() {
+		var e Event
+		var changes []byte
+		if err := rows.Scan(&e.ID, &e.TodoID, &e.Action, &e.Actor, &e.At, &changes); err != nil {
+			return all, err
+		}
+		if err := json.Unmarshal(changes, &e.Changes); err != nil {
+			return all, err
+		}
+		e.At = e.At.UTC()
+		all = append(all, e)
+	}
+
+	return all, rows.Err()
+}
@@ -0,0 +1,106 @@
+package todos
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+func (r *repositoryDB) FindList(ctx context.Context, id string) (List, error) {
+	row := r.conn.QueryRowContext(ctx, "select id, name, archived_at from lists where id = ?", id)
+
+	l, err := scanList(row)
+	if err == sql.ErrNoRows {
+		return List{}, ErrListNotFound{id}
+	}
+
+	return l, err
+}
+
+// ListLists returns the lists sorted by name. Archived lists are only returned when archived is true.
+func (r *repositoryDB) ListLists(ctx context.Context, archived bool) ([]List, error) {
+	qry := "select id, name, archived_at from lists where (? or archived_at is null) order by lower(name), id"
+
+	rows, err := r.conn.QueryContext(ctx, qry, archived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := make([]List, 0)
+	for rows.Next() {
+		l, err := scanList(rows)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, l)
+	}
+
+	return all, rows.Err()
+}
+
+func (r *repositoryDB) AddList(ctx context.Context, l List) error {
+	qry := "insert into lists (id, name, archived_at) values (?, ?, ?)"
+	_, err := r.conn.ExecContext(ctx, qry, l.ID, l.Name, nullTime(l.ArchivedAt))
+
+	return err
+}
+
+func (r *repositoryDB) UpdateList(ctx context.Context, l List) error {
+	return r.inTx(ctx, func(tx dbTx) error {
+		if err := checkList(ctx, tx, l.ID); err != nil {
+			return err
+		}
+
+		qry := "update lists set name = ?, archived_at = ? where id = ?"
+		_, err := tx.ExecContext(ctx, qry, l.Name, nullTime(l.ArchivedAt), l.ID)
+
+		return err
+	})
+}
+
+// DeleteList removes the list. Its todo's are removed by the foreign key.
+func (r *repositoryDB) DeleteList(ctx context.Context, id string) error {
+	res, err := r.conn.ExecContext(ctx, "delete from lists where id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrListNotFound{id}
+	}
+
+	return nil
+}
+
+// checkList locks the list so it is not deleted while a todo is added to it. An empty ID is no list.
+func checkList(ctx context.Context, tx dbTx, id string) error {
+	if id == "" {
+		return nil
+	}
+
+	var found string
+	err := tx.QueryRowContext(ctx, "select id from lists where id = ? for update", id).Scan(&found)
+	if err == sql.ErrNoRows {
+		return ErrListNotFound{id}
+	}
+
+	return err
+}
+
+func scanList[T Scanner](r T) (List, error) {
+	var l List
+	var archivedAt sql.NullTime
+	if err := r.Scan(&l.ID, &l.Name, &archivedAt); err != nil {
+		return List{}, err
+	}
+
+	if archivedAt.Valid {
+		at := archivedAt.Time.In(time.UTC)
+		l.ArchivedAt = &at
+	}
+
+	return l, nil
+}
@@ -0,0 +1,103 @@
+package todos
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Apply stores the todo's as they are after the revisions, in one transaction
+func (r *repositoryDB) Apply(ctx context.Context, revs []Revision) error {
+	return r.inTx(ctx, func(tx dbTx) error {
+		for _, rev := range revs {
+			if err := checkUnchanged(ctx, tx, rev.ID, rev.Before); err != nil {
+				return err
+			}
+		}
+
+		for _, rev := range revs {
+			if rev.After == nil {
+				if err := removeTodo(ctx, tx, rev.ID); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := saveTodo(ctx, tx, *rev.After); err != nil {
+				return err
+			}
+		}
+
+		// the references are checked once all the todo's are stored
+		for _, rev := range revs {
+			if t := rev.After; t != nil && t.DeletedAt == nil {
+				if err := checkList(ctx, tx, t.ListID); err != nil {
+					return err
+				}
+				if err := checkParent(ctx, tx, t.ID, t.ParentID); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+}
+
+// checkUnchanged locks the todo and makes sure it is still in the state `before`: missing,
+// in the todo's or in the trash with the same version
+func checkUnchanged(ctx context.Context, tx dbTx, id string, before *Todo) error {
+	var version int64
+	var deleted bool
+	err := tx.QueryRowContext(ctx, "select version, deleted_at is not null from todos where id = ? for update", id).Scan(&version, &deleted)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	found := err == nil
+	switch {
+	case before == nil && !found:
+		return nil
+	case before != nil && found && version == before.Version && deleted == (before.DeletedAt != nil):
+		return nil
+	}
+
+	return ErrVersionMismatch{id}
+}
+
+// removeTodo deletes the todo for good. A todo with subtasks is not removed, they would be removed with it.
+func removeTodo(ctx context.Context, tx dbTx, id string) error {
+	var child string
+	err := tx.QueryRowContext(ctx, "select id from todos where parent_id = ? limit 1", id).Scan(&child)
+	if err == nil {
+		return ErrVersionMismatch{id}
+	}
+	if err != sql.ErrNoRows {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "delete from todos where id = ?", id)
+
+	return err
+}
+
+// savedColumns are the columns of the todos table written by saveTodo, in the order of its values
+var savedColumns = []string{"id", "title", "description", "checklist", "list_id", "parent_id", "priority", "completed_at", "due_at", "due_tz",
+	"remind_at", "remind_tz", "recurrence", "series_id", "occurrence", "version", "deleted_at"}
+
+// saveTodo inserts or replaces the todo with its version and deletion time
+func saveTodo(ctx context.Context, tx dbTx, t Todo) error {
+	checklist, err := nullChecklist(t.Checklist)
+	if err != nil {
+		return err
+	}
+
+	qry := tx.upsert("todos", "id", savedColumns)
+
+	_, err = tx.ExecContext(ctx, qry, t.ID, t.Title, t.Description, checklist, nullString(t.ListID), nullString(t.ParentID), t.Priority, nullTime(t.CompletedAt),
+		nullTime(t.DueAt), nullZone(t.DueAt), nullTime(t.RemindAt), nullZone(t.RemindAt),
+		nullRecurrence(t.Recurrence), nullString(t.SeriesID), t.Occurrence, t.Version, nullTime(t.DeletedAt))
+	if err != nil {
+		return err
+	}
+
+	return setTags(ctx, tx, t.ID, t.UniqueTags())
+}
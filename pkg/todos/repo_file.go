@@ -0,0 +1,644 @@
+package todos
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// The files of the repository in its directory
+const (
+	logFile      = "todos.wal"
+	snapshotFile = "todos.snapshot"
+)
+
+// maxRecordSize is larger than any record that is written. A larger size is a broken header.
+const maxRecordSize = 64 << 20
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// errTornRecord is a record that was not completely written, at the end of the log
+var errTornRecord = errors.New("torn record")
+
+// errCorruptRecord is a damaged record that is followed by more records. It is not a torn write,
+// and cutting the log there would lose changes that were reported as written.
+var errCorruptRecord = errors.New("damaged record in the middle of the log")
+
+// The changes of the repository, as they are written in the log
+const (
+	opAdd              = "add"
+	opUpdate           = "update"
+	opDelete           = "delete"
+	opRestore          = "restore"
+	opPurge            = "purge"
+	opRenameTag        = "rename_tag"
+	opMergeTag         = "merge_tag"
+	opAddDependency    = "add_dependency"
+	opRemoveDependency = "remove_dependency"
+	opAddList          = "add_list"
+	opUpdateList       = "update_list"
+	opDeleteList       = "delete_list"
+	opApply            = "apply"
+)
+
+// The changes of the history, comments and attachments of the todo's
+const (
+	opAddEvent          = "add_event"
+	opAddComment        = "add_comment"
+	opUpdateComment     = "update_comment"
+	opDeleteComment     = "delete_comment"
+	opTrashComments     = "trash_comments"
+	opRestoreComments   = "restore_comments"
+	opPurgeComments     = "purge_comments"
+	opAddAttachment     = "add_attachment"
+	opDeleteAttachment  = "delete_attachment"
+	opDeleteAttachments = "delete_attachments"
+)
+
+// walRecord is a change of the repository with its arguments. Replaying the records in order
+// on the in-memory repository rebuilds the state.
+type walRecord struct {
+	Seq int64  `json:"seq"`
+	Op  string `json:"op"`
+	// At is when the change was made, the deletion time of a delete
+	At         time.Time   `json:"at"`
+	ID         string      `json:"id,omitempty"`
+	Version    int64       `json:"version,omitempty"`
+	Before     *time.Time  `json:"before,omitempty"`
+	Todo       *Todo       `json:"todo,omitempty"`
+	List       *List       `json:"list,omitempty"`
+	Dependency *Dependency `json:"dependency,omitempty"`
+	From       string      `json:"from,omitempty"`
+	To         string      `json:"to,omitempty"`
+	Revisions  []Revision  `json:"revisions,omitempty"`
+	// IDs are the todo's of the comments or attachments
+	IDs []string `json:"ids,omitempty"`
+	// Time is the time given to the change, like the time of the edit of a comment
+	Time       *time.Time  `json:"time,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	Event      *Event      `json:"event,omitempty"`
+	Comment    *Comment    `json:"comment,omitempty"`
+	Attachment *Attachment `json:"attachment,omitempty"`
+}
+
+// fileSnapshot is the whole state after the record Seq
+type fileSnapshot struct {
+	Seq          int64        `json:"seq"`
+	Todos        []Todo       `json:"todos"`
+	Trash        []Todo       `json:"trash"`
+	Lists        []List       `json:"lists"`
+	Dependencies []Dependency `json:"dependencies"`
+	Events       []Event      `json:"events"`
+	// LastEvent is the ID of the last event, the next ones continue from it
+	LastEvent   int64         `json:"last_event"`
+	Comments    []fileComment `json:"comments"`
+	Attachments []Attachment  `json:"attachments"`
+}
+
+// fileComment is a comment in the snapshot, with the time it was trashed with its todo
+type fileComment struct {
+	Comment
+	TrashedAt *time.Time `json:"trashed_at,omitempty"`
+}
+
+// repositoryFile keeps the todo's, their history, comments and attachments in memory and every change in
+// a write-ahead log, so they survive a restart. It is also the HistoryRepository, CommentRepository and
+// AttachmentRepository of the todo's. Every change is synced to the disk before it returns.
+// After `compactEvery` changes the whole state is written to a snapshot and the log starts over.
+//
+// The directory must be used by a single process at a time.
+type repositoryFile struct {
+	*repositoryMem
+	history     *historyMem
+	comments    *commentsMem
+	attachments *attachmentsMem
+
+	dir string
+	log *os.File
+	// seq is the number of the last record in the log
+	seq int64
+	// records counts the records since the last snapshot
+	records      int
+	compactEvery int
+	// err is set when the log could not be written, every change fails after it
+	err error
+	w   sync.Mutex
+}
+
+// NewFileRepository opens the repository in the directory, or creates it. The state is rebuilt from the
+// snapshot and the log. A record at the end of the log that was not completely written is dropped.
+// compactEvery is the number of changes between the snapshots, 0 never writes a snapshot.
+func NewFileRepository(dir string, compactEvery int) (Repository, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	r := &repositoryFile{
+		repositoryMem: NewInMemoryRepository().(*repositoryMem),
+		history:       NewInMemoryHistory().(*historyMem),
+		comments:      NewInMemoryComments().(*commentsMem),
+		attachments:   NewInMemoryAttachments().(*attachmentsMem),
+		dir:           dir,
+		compactEvery:  compactEvery,
+	}
+	if err := r.readSnapshot(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, logFile), os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncDir(dir); err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.log = f
+
+	if err := r.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Close closes the log. The changes are already on the disk.
+func (r *repositoryFile) Close() error {
+	r.w.Lock()
+	defer r.w.Unlock()
+
+	if errors.Is(r.err, fs.ErrClosed) {
+		return nil
+	}
+	r.err = fs.ErrClosed
+
+	return r.log.Close()
+}
+
+func (r *repositoryFile) Add(ctx context.Context, td Todo) error {
+	_, err := r.write(ctx, walRecord{Op: opAdd, Todo: &td})
+	return err
+}
+
+func (r *repositoryFile) Update(ctx context.Context, id string, td Todo) error {
+	_, err := r.write(ctx, walRecord{Op: opUpdate, ID: id, Todo: &td})
+	return err
+}
+
+func (r *repositoryFile) Delete(ctx context.Context, id string, version int64) error {
+	_, err := r.write(ctx, walRecord{Op: opDelete, ID: id, Version: version})
+	return err
+}
+
+func (r *repositoryFile) Restore(ctx context.Context, id string) error {
+	_, err := r.write(ctx, walRecord{Op: opRestore, ID: id})
+	return err
+}
+
+func (r *repositoryFile) Purge(ctx context.Context, before time.Time) (int, error) {
+	return r.write(ctx, walRecord{Op: opPurge, Before: &before})
+}
+
+func (r *repositoryFile) RenameTag(ctx context.Context, from, to string) error {
+	_, err := r.write(ctx, walRecord{Op: opRenameTag, From: from, To: to})
+	return err
+}
+
+func (r *repositoryFile) MergeTag(ctx context.Context, from, into string) error {
+	_, err := r.write(ctx, walRecord{Op: opMergeTag, From: from, To: into})
+	return err
+}
+
+func (r *repositoryFile) AddDependency(ctx context.Context, d Dependency) error {
+	_, err := r.write(ctx, walRecord{Op: opAddDependency, Dependency: &d})
+	return err
+}
+
+func (r *repositoryFile) RemoveDependency(ctx context.Context, d Dependency) error {
+	_, err := r.write(ctx, walRecord{Op: opRemoveDependency, Dependency: &d})
+	return err
+}
+
+func (r *repositoryFile) AddList(ctx context.Context, l List) error {
+	_, err := r.write(ctx, walRecord{Op: opAddList, List: &l})
+	return err
+}
+
+func (r *repositoryFile) UpdateList(ctx context.Context, l List) error {
+	_, err := r.write(ctx, walRecord{Op: opUpdateList, List: &l})
+	return err
+}
+
+func (r *repositoryFile) DeleteList(ctx context.Context, id string) error {
+	_, err := r.write(ctx, walRecord{Op: opDeleteList, ID: id})
+	return err
+}
+
+func (r *repositoryFile) Apply(ctx context.Context, revs []Revision) error {
+	_, err := r.write(ctx, walRecord{Op: opApply, Revisions: revs})
+	return err
+}
+
+// write makes the change in memory and appends it to the log. The changes that fail are not logged.
+// When the log cannot be written the memory is ahead of the disk, so all the following changes fail
+// until the repository is opened again.
+func (r *repositoryFile) write(ctx context.Context, rec walRecord) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.w.Lock()
+	defer r.w.Unlock()
+
+	return r.writeLocked(rec)
+}
+
+// writeLocked is write with the write lock held
+func (r *repositoryFile) writeLocked(rec walRecord) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	rec.Seq = r.seq + 1
+	rec.At = time.Now().UTC()
+	n, err := r.apply(rec)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := r.append(rec); err != nil {
+		r.err = fmt.Errorf("writing the log of the repository: %w", err)
+		return 0, r.err
+	}
+	r.seq = rec.Seq
+
+	// the change is safe in the log, a failed snapshot is tried again after the next change
+	if r.records++; r.compactEvery > 0 && r.records >= r.compactEvery {
+		if err := r.compact(); err != nil {
+			log.Printf("Compacting the log: %v\n", err)
+		}
+	}
+
+	return n, nil
+}
+
+// apply makes the change of the record in memory. Purge returns the number of todo's removed.
+func (r *repositoryFile) apply(rec walRecord) (int, error) {
+	ctx := context.Background()
+	mem := r.repositoryMem
+
+	switch rec.Op {
+	case opAdd:
+		return 0, mem.Add(ctx, *rec.Todo)
+	case opUpdate:
+		return 0, mem.Update(ctx, rec.ID, *rec.Todo)
+	case opDelete:
+		mem.m.Lock()
+		defer mem.m.Unlock()
+		return 0, mem.delete(rec.ID, rec.Version, rec.At)
+	case opRestore:
+		return 0, mem.Restore(ctx, rec.ID)
+	case opPurge:
+		return mem.Purge(ctx, *rec.Before)
+	case opRenameTag:
+		return 0, mem.RenameTag(ctx, rec.From, rec.To)
+	case opMergeTag:
+		return 0, mem.MergeTag(ctx, rec.From, rec.To)
+	case opAddDependency:
+		return 0, mem.AddDependency(ctx, *rec.Dependency)
+	case opRemoveDependency:
+		return 0, mem.RemoveDependency(ctx, *rec.Dependency)
+	case opAddList:
+		return 0, mem.AddList(ctx, *rec.List)
+	case opUpdateList:
+		return 0, mem.UpdateList(ctx, *rec.List)
+	case opDeleteList:
+		return 0, mem.DeleteList(ctx, rec.ID)
+	case opApply:
+		return 0, mem.Apply(ctx, rec.Revisions)
+	case opAddEvent:
+		return 0, r.history.AddEvent(ctx, *rec.Event)
+	case opAddComment:
+		return 0, r.comments.AddComment(ctx, *rec.Comment)
+	case opUpdateComment:
+		return 0, r.comments.UpdateComment(ctx, rec.ID, rec.Body, *rec.Time)
+	case opDeleteComment:
+		return 0, r.comments.DeleteComment(ctx, rec.ID)
+	case opTrashComments:
+		return 0, r.comments.TrashComments(ctx, rec.IDs, *rec.Time)
+	case opRestoreComments:
+		return 0, r.comments.RestoreComments(ctx, rec.IDs)
+	case opPurgeComments:
+		return 0, r.comments.PurgeComments(ctx, *rec.Before)
+	case opAddAttachment:
+		return 0, r.attachments.AddAttachment(ctx, *rec.Attachment)
+	case opDeleteAttachment:
+		return 0, r.attachments.DeleteAttachment(ctx, rec.ID)
+	case opDeleteAttachments:
+		_, err := r.attachments.DeleteAttachments(ctx, rec.IDs)
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("unknown change in the log: %q", rec.Op)
+}
+
+// append writes the record to the log and syncs it. A record is its length and CRC-32C, 4 bytes each, and its JSON.
+func (r *repositoryFile) append(rec walRecord) error {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	b := make([]byte, 8+len(payload))
+	binary.BigEndian.PutUint32(b[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(b[4:8], crc32.Checksum(payload, crcTable))
+	copy(b[8:], payload)
+
+	if _, err := r.log.Write(b); err != nil {
+		return err
+	}
+
+	return r.log.Sync()
+}
+
+// readRecord reads the next record of the log and returns its size in the log.
+// It returns io.EOF at the end of the log and errTornRecord for an incomplete or damaged record.
+func readRecord(rd io.Reader) (walRecord, int64, error) {
+	var rec walRecord
+
+	var header [8]byte
+	if _, err := io.ReadFull(rd, header[:]); err == io.EOF {
+		return rec, 0, io.EOF
+	} else if err == io.ErrUnexpectedEOF {
+		return rec, 0, errTornRecord
+	} else if err != nil {
+		return rec, 0, err
+	}
+
+	size := binary.BigEndian.Uint32(header[0:4])
+	if size > maxRecordSize {
+		return rec, 0, errTornRecord
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(rd, payload); err == io.EOF || err == io.ErrUnexpectedEOF {
+		return rec, 0, errTornRecord
+	} else if err != nil {
+		return rec, 0, err
+	}
+	if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return rec, 0, errTornRecord
+	}
+
+	if err := json.Unmarshal(payload, &rec); err != nil {
+		return rec, 0, fmt.Errorf("reading a record of the log: %w", err)
+	}
+
+	return rec, int64(len(header)) + int64(size), nil
+}
+
+// replay applies the records of the log that are not in the snapshot yet. A torn record is cut off
+// the log: it was never reported as written, and the next records must follow the last good one.
+// Only the last record can be torn, a damaged record followed by a good one fails with errCorruptRecord.
+func (r *repositoryFile) replay() error {
+	if _, err := r.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	rd := bufio.NewReader(r.log)
+	var offset int64
+	for {
+		rec, size, err := readRecord(rd)
+		if err == io.EOF {
+			return nil
+		}
+		if err == errTornRecord {
+			if found, err := r.recordAfter(offset); err != nil {
+				return err
+			} else if found {
+				return fmt.Errorf("%w at offset %d", errCorruptRecord, offset)
+			}
+			log.Printf("Dropping a torn record at offset %d of the log\n", offset)
+			if err := r.log.Truncate(offset); err != nil {
+				return err
+			}
+			return r.log.Sync()
+		}
+		if err != nil {
+			return err
+		}
+		offset += size
+
+		// the records before the snapshot are left when the log was not cleared after it
+		if rec.Seq <= r.seq {
+			continue
+		}
+		if rec.Seq != r.seq+1 {
+			return fmt.Errorf("the log misses the records %d to %d", r.seq+1, rec.Seq-1)
+		}
+		if _, err := r.apply(rec); err != nil {
+			return fmt.Errorf("replaying the record %d of the log: %w", rec.Seq, err)
+		}
+		r.seq = rec.Seq
+		r.records++
+	}
+}
+
+// recordAfter reports whether a good record starts somewhere after the bad one at the offset.
+// The size of the bad record cannot be trusted, so every position is tried.
+func (r *repositoryFile) recordAfter(offset int64) (bool, error) {
+	st, err := r.log.Stat()
+	if err != nil {
+		return false, err
+	}
+	tail := make([]byte, st.Size()-offset)
+	if _, err := r.log.ReadAt(tail, offset); err != nil {
+		return false, err
+	}
+
+	for i := 1; i+8 <= len(tail); i++ {
+		size := int(binary.BigEndian.Uint32(tail[i : i+4]))
+		if size == 0 || size > len(tail)-i-8 {
+			continue
+		}
+		if crc32.Checksum(tail[i+8:i+8+size], crcTable) == binary.BigEndian.Uint32(tail[i+4:i+8]) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// compact writes the state to the snapshot and clears the log. The write lock must be held.
+func (r *repositoryFile) compact() error {
+	mem := r.repositoryMem
+	snap := fileSnapshot{Seq: r.seq}
+
+	mem.m.RLock()
+	for _, td := range mem.data {
+		snap.Todos = append(snap.Todos, td)
+	}
+	for _, td := range mem.trash {
+		snap.Trash = append(snap.Trash, td)
+	}
+	for _, l := range mem.lists {
+		snap.Lists = append(snap.Lists, l)
+	}
+	for id, blockers := range mem.blockers {
+		for b := range blockers {
+			snap.Dependencies = append(snap.Dependencies, Dependency{TodoID: id, BlockerID: b})
+		}
+	}
+	mem.m.RUnlock()
+
+	r.history.m.RLock()
+	for _, events := range r.history.events {
+		snap.Events = append(snap.Events, events...)
+	}
+	snap.LastEvent = r.history.lastID
+	r.history.m.RUnlock()
+
+	r.comments.m.RLock()
+	for id, cm := range r.comments.data {
+		fc := fileComment{Comment: cm}
+		if at, ok := r.comments.trashed[id]; ok {
+			fc.TrashedAt = &at
+		}
+		snap.Comments = append(snap.Comments, fc)
+	}
+	r.comments.m.RUnlock()
+
+	r.attachments.m.RLock()
+	for _, at := range r.attachments.data {
+		snap.Attachments = append(snap.Attachments, at)
+	}
+	r.attachments.m.RUnlock()
+
+	byID := func(a, b Todo) bool { return a.ID < b.ID }
+	slices.SortFunc(snap.Todos, byID)
+	slices.SortFunc(snap.Trash, byID)
+	slices.SortFunc(snap.Lists, func(a, b List) bool { return a.ID < b.ID })
+	sortDependencies(snap.Dependencies)
+	slices.SortFunc(snap.Events, func(a, b Event) bool { return a.ID < b.ID })
+	slices.SortFunc(snap.Comments, func(a, b fileComment) bool { return a.ID < b.ID })
+	slices.SortFunc(snap.Attachments, func(a, b Attachment) bool { return a.ID < b.ID })
+
+	b, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+
+	// the snapshot replaces the old one at once, or not at all
+	tmp, err := os.CreateTemp(r.dir, snapshotFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0o640); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(r.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(r.dir); err != nil {
+		return err
+	}
+
+	// a crash before the log is cleared only leaves records that are skipped at the next start
+	if err := r.log.Truncate(0); err != nil {
+		return err
+	}
+	if err := r.log.Sync(); err != nil {
+		return err
+	}
+	r.records = 0
+
+	return nil
+}
+
+// readSnapshot loads the state of the snapshot, if there is one
+func (r *repositoryFile) readSnapshot() error {
+	b, err := os.ReadFile(filepath.Join(r.dir, snapshotFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var snap fileSnapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return fmt.Errorf("reading the snapshot: %w", err)
+	}
+
+	mem := r.repositoryMem
+	for _, td := range snap.Todos {
+		mem.data[td.ID] = td
+	}
+	for _, td := range snap.Trash {
+		mem.trash[td.ID] = td
+	}
+	for _, l := range snap.Lists {
+		mem.lists[l.ID] = l
+	}
+	for _, d := range snap.Dependencies {
+		if mem.blockers[d.TodoID] == nil {
+			mem.blockers[d.TodoID] = make(map[string]bool)
+		}
+		mem.blockers[d.TodoID][d.BlockerID] = true
+	}
+	for _, e := range snap.Events {
+		r.history.events[e.TodoID] = append(r.history.events[e.TodoID], e)
+	}
+	r.history.lastID = snap.LastEvent
+	for _, fc := range snap.Comments {
+		r.comments.data[fc.ID] = fc.Comment
+		if fc.TrashedAt != nil {
+			r.comments.trashed[fc.ID] = *fc.TrashedAt
+		}
+	}
+	for _, at := range snap.Attachments {
+		r.attachments.data[at.ID] = at
+	}
+	r.seq = snap.Seq
+
+	return nil
+}
+
+// syncDir makes the files created or renamed in the directory durable
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
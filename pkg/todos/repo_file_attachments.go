@@ -0,0 +1,50 @@
+package todos
+
+import "context"
+
+func (r *repositoryFile) AddAttachment(ctx context.Context, at Attachment) error {
+	_, err := r.write(ctx, walRecord{Op: opAddAttachment, Attachment: &at})
+	return err
+}
+
+func (r *repositoryFile) FindAttachment(ctx context.Context, id string) (Attachment, error) {
+	return r.attachments.FindAttachment(ctx, id)
+}
+
+func (r *repositoryFile) FindAttachments(ctx context.Context, todoID string) ([]Attachment, error) {
+	return r.attachments.FindAttachments(ctx, todoID)
+}
+
+func (r *repositoryFile) DeleteAttachment(ctx context.Context, id string) error {
+	_, err := r.write(ctx, walRecord{Op: opDeleteAttachment, ID: id})
+	return err
+}
+
+// DeleteAttachments reads the attachments under the write lock, so they are the ones the logged change removes
+func (r *repositoryFile) DeleteAttachments(ctx context.Context, todoIDs []string) ([]Attachment, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.w.Lock()
+	defer r.w.Unlock()
+
+	var removed []Attachment
+	for _, id := range todoIDs {
+		all, err := r.attachments.FindAttachments(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		removed = append(removed, all...)
+	}
+
+	if _, err := r.writeLocked(walRecord{Op: opDeleteAttachments, IDs: todoIDs}); err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+func (r *repositoryFile) CountBySHA256(ctx context.Context, sum string) (int, error) {
+	return r.attachments.CountBySHA256(ctx, sum)
+}
@@ -0,0 +1,44 @@
+package todos
+
+import (
+	"context"
+	"time"
+)
+
+func (r *repositoryFile) AddComment(ctx context.Context, cm Comment) error {
+	_, err := r.write(ctx, walRecord{Op: opAddComment, Comment: &cm})
+	return err
+}
+
+func (r *repositoryFile) FindComment(ctx context.Context, id string) (Comment, error) {
+	return r.comments.FindComment(ctx, id)
+}
+
+func (r *repositoryFile) FindComments(ctx context.Context, todoID string) ([]Comment, error) {
+	return r.comments.FindComments(ctx, todoID)
+}
+
+func (r *repositoryFile) UpdateComment(ctx context.Context, id, body string, at time.Time) error {
+	_, err := r.write(ctx, walRecord{Op: opUpdateComment, ID: id, Body: body, Time: &at})
+	return err
+}
+
+func (r *repositoryFile) DeleteComment(ctx context.Context, id string) error {
+	_, err := r.write(ctx, walRecord{Op: opDeleteComment, ID: id})
+	return err
+}
+
+func (r *repositoryFile) TrashComments(ctx context.Context, todoIDs []string, at time.Time) error {
+	_, err := r.write(ctx, walRecord{Op: opTrashComments, IDs: todoIDs, Time: &at})
+	return err
+}
+
+func (r *repositoryFile) RestoreComments(ctx context.Context, todoIDs []string) error {
+	_, err := r.write(ctx, walRecord{Op: opRestoreComments, IDs: todoIDs})
+	return err
+}
+
+func (r *repositoryFile) PurgeComments(ctx context.Context, before time.Time) error {
+	_, err := r.write(ctx, walRecord{Op: opPurgeComments, Before: &before})
+	return err
+}
@@ -0,0 +1,12 @@
+package todos
+
+import "context"
+
+func (r *repositoryFile) AddEvent(ctx context.Context, e Event) error {
+	_, err := r.write(ctx, walRecord{Op: opAddEvent, Event: &e})
+	return err
+}
+
+func (r *repositoryFile) FindEvents(ctx context.Context, id string) ([]Event, error) {
+	return r.history.FindEvents(ctx, id)
+}
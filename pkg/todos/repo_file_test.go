@@ -0,0 +1,350 @@
+package todos
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func openFileRepository(t *testing.T, dir string, compactEvery int) *repositoryFile {
+	t.Helper()
+
+	r, err := NewFileRepository(dir, compactEvery)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.(*repositoryFile).Close() })
+
+	return r.(*repositoryFile)
+}
+
+// fileState returns everything the repository keeps, to compare it before and after a restart
+func fileState(t *testing.T, r Repository) string {
+	t.Helper()
+	ctx := context.Background()
+
+	all, err := r.ListAll(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash, err := r.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lists, err := r.ListLists(ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags, err := r.ListTags(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, td := range append(all, trash...) {
+		ids = append(ids, td.ID)
+	}
+	deps, err := r.FindDependencies(ctx, ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal([]any{all, trash, lists, tags, deps})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(b)
+}
+
+func TestFileRepositoryReopen(t *testing.T) {
+	for _, compactEvery := range []int{0, 1, 4} {
+		dir := t.TempDir()
+		r := openFileRepository(t, dir, compactEvery)
+		ctx := context.Background()
+
+		l := List{ID: uuid.NewString(), Name: "home"}
+		due := time.Date(2023, 6, 1, 9, 30, 0, 0, time.FixedZone("", 2*3600))
+		a := Todo{ID: uuid.NewString(), Title: "a", Tags: []string{"go"}, DueAt: &due, ListID: l.ID}
+		b := Todo{ID: uuid.NewString(), Title: "b", Tags: []string{"golang"}, ParentID: a.ID}
+		c := Todo{ID: uuid.NewString(), Title: "c"}
+		gone := Todo{ID: uuid.NewString(), Title: "gone"}
+
+		for _, err := range []error{
+			r.AddList(ctx, l),
+			r.Add(ctx, a),
+			r.Add(ctx, b),
+			r.Add(ctx, c),
+			r.Add(ctx, gone),
+			r.AddDependency(ctx, Dependency{TodoID: c.ID, BlockerID: a.ID}),
+			r.AddDependency(ctx, Dependency{TodoID: a.ID, BlockerID: gone.ID}),
+			r.RemoveDependency(ctx, Dependency{TodoID: a.ID, BlockerID: gone.ID}),
+			r.Update(ctx, c.ID, Todo{Title: "c2", Tags: []string{"work"}, Version: 1}),
+			r.MergeTag(ctx, "golang", "go"),
+			r.RenameTag(ctx, "work", "job"),
+			r.Delete(ctx, a.ID, 0),
+			r.Restore(ctx, a.ID),
+			r.Delete(ctx, gone.ID, 0),
+			r.Delete(ctx, c.ID, 0),
+			r.UpdateList(ctx, List{ID: l.ID, Name: "house"}),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if n, err := r.Purge(ctx, time.Now().Add(time.Second)); err != nil || n != 2 {
+			t.Fatalf("wrong purge: %d, %v", n, err)
+		}
+		before, _ := r.FindByID(ctx, b.ID)
+		after := before
+		after.Title = "b2"
+		after.Version++
+		if err := r.Apply(ctx, []Revision{{ID: b.ID, Before: &before, After: &after}}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Delete(ctx, b.ID, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		want := fileState(t, r)
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Add(ctx, Todo{ID: uuid.NewString()}); err == nil {
+			t.Fatal("todo added to a closed repository")
+		}
+
+		reopened := openFileRepository(t, dir, compactEvery)
+		if got := fileState(t, reopened); got != want {
+			t.Fatalf("wrong state after a restart with compaction every %d changes:\n%s\nwant:\n%s", compactEvery, got, want)
+		}
+		if td, err := reopened.FindByID(ctx, a.ID); err != nil || zoneOf(*td.DueAt) != "+02:00" || td.Version != 2 {
+			t.Fatalf("wrong todo after a restart: %+v, %v", td, err)
+		}
+
+		// the deleted list takes its todo with it, also after a restart
+		if err := reopened.DeleteList(ctx, l.ID); err != nil {
+			t.Fatal(err)
+		}
+		want = fileState(t, reopened)
+		reopened.Close()
+		if got := fileState(t, openFileRepository(t, dir, compactEvery)); got != want {
+			t.Fatalf("wrong state after deleting the list:\n%s\nwant:\n%s", got, want)
+		}
+	}
+}
+
+func TestFileRepositoryTornLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, logFile)
+
+	r := openFileRepository(t, dir, 0)
+	for _, title := range []string{"first", "second"} {
+		if err := r.Add(ctx, Todo{ID: uuid.NewString(), Title: title}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	good, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(ctx, Todo{ID: uuid.NewString(), Title: "third", Description: "written when the power failed"}); err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+
+	full, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a crash can stop the write of the last record at any byte
+	for cut := int(good.Size()) + 1; cut < len(full); cut++ {
+		crashed := t.TempDir()
+		if err := os.WriteFile(filepath.Join(crashed, logFile), full[:cut], 0o640); err != nil {
+			t.Fatal(err)
+		}
+
+		r := openFileRepository(t, crashed, 0)
+		if all, err := r.ListAll(ctx, nil); err != nil || len(all) != 2 {
+			t.Fatalf("wrong todo's after a cut at %d: %d, %v", cut, len(all), err)
+		}
+		if st, err := os.Stat(filepath.Join(crashed, logFile)); err != nil || st.Size() != good.Size() {
+			t.Fatalf("torn record not removed after a cut at %d: %v", cut, err)
+		}
+
+		// the next records follow the last good one
+		if err := r.Add(ctx, Todo{ID: uuid.NewString(), Title: "after"}); err != nil {
+			t.Fatal(err)
+		}
+		r.Close()
+		if all, err := openFileRepository(t, crashed, 0).ListAll(ctx, nil); err != nil || len(all) != 3 {
+			t.Fatalf("wrong todo's after a restart with a cut at %d: %d, %v", cut, len(all), err)
+		}
+	}
+
+	// a record that is complete but damaged is dropped too
+	damaged := append([]byte(nil), full...)
+	damaged[len(damaged)-10] ^= 0xff
+	if err := os.WriteFile(logPath, damaged, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := openFileRepository(t, dir, 0).ListAll(ctx, nil); err != nil || len(all) != 2 {
+		t.Fatalf("wrong todo's with a damaged record: %d, %v", len(all), err)
+	}
+}
+
+func TestFileRepositoryCorruptLog(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, logFile)
+
+	r := openFileRepository(t, dir, 0)
+	first, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, title := range []string{"first", "second", "third"} {
+		if err := r.Add(ctx, Todo{ID: uuid.NewString(), Title: title}); err != nil {
+			t.Fatal(err)
+		}
+		if first.Size() == 0 {
+			first, _ = os.Stat(logPath)
+		}
+	}
+	r.Close()
+
+	full, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a damaged record in the middle is not a torn write: the records after it were written
+	for _, at := range []int{1, 6, int(first.Size()) - 2} {
+		damaged := append([]byte(nil), full...)
+		damaged[at] ^= 0xff
+		if err := os.WriteFile(logPath, damaged, 0o640); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewFileRepository(dir, 0); !errors.Is(err, errCorruptRecord) {
+			t.Fatalf("damaged byte %d: expected a corrupt log, got: %v", at, err)
+		}
+		if kept, err := os.ReadFile(logPath); err != nil || len(kept) != len(full) {
+			t.Fatalf("damaged byte %d: the log was cut: %d bytes, %v", at, len(kept), err)
+		}
+	}
+}
+
+func TestFileRepositoryCompaction(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, logFile)
+
+	r := openFileRepository(t, dir, 3)
+	for i := 0; i < 2; i++ {
+		if err := r.Add(ctx, Todo{ID: uuid.NewString(), Title: "before"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Add(ctx, Todo{ID: uuid.NewString(), Title: "compacted"}); err != nil {
+		t.Fatal(err)
+	}
+	if st, err := os.Stat(logPath); err != nil || st.Size() != 0 {
+		t.Fatalf("log not cleared after the snapshot: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, snapshotFile)); err != nil {
+		t.Fatalf("no snapshot: %v", err)
+	}
+	if err := r.Add(ctx, Todo{ID: uuid.NewString(), Title: "after"}); err != nil {
+		t.Fatal(err)
+	}
+	want := fileState(t, r)
+	r.Close()
+
+	if got := fileState(t, openFileRepository(t, dir, 3)); got != want {
+		t.Fatalf("wrong state from the snapshot and the log:\n%s\nwant:\n%s", got, want)
+	}
+
+	// a crash after the snapshot, before the log was cleared, leaves the records of the snapshot in the log
+	if err := os.WriteFile(logPath, stale, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := openFileRepository(t, dir, 3).ListAll(ctx, nil); err != nil || len(all) != 3 {
+		t.Fatalf("wrong todo's with the records of the snapshot in the log: %d, %v", len(all), err)
+	}
+}
+
+// TestFileRepositoryReopenHistory checks that the history, comments and attachments survive a restart too
+func TestFileRepositoryReopenHistory(t *testing.T) {
+	for _, compactEvery := range []int{0, 3} {
+		dir := t.TempDir()
+		r := openFileRepository(t, dir, compactEvery)
+		ctx := context.Background()
+
+		a, b := uuid.NewString(), uuid.NewString()
+		now := time.Now().UTC().Truncate(time.Second)
+		cm := Comment{ID: uuid.NewString(), TodoID: a, Body: "first", CreatedAt: now}
+		trashed := Comment{ID: uuid.NewString(), TodoID: b, Body: "gone with b", CreatedAt: now}
+		at := Attachment{ID: uuid.NewString(), TodoID: a, Name: "a.txt", SHA256: "abc", CreatedAt: now}
+		removed := Attachment{ID: uuid.NewString(), TodoID: b, Name: "b.txt", SHA256: "abc", CreatedAt: now}
+
+		for _, err := range []error{
+			r.AddEvent(ctx, Event{TodoID: a, Action: ActionCreate, At: now}),
+			r.AddEvent(ctx, Event{TodoID: a, Action: ActionUpdate, At: now}),
+			r.AddComment(ctx, cm),
+			r.AddComment(ctx, trashed),
+			r.UpdateComment(ctx, cm.ID, "second", now.Add(time.Minute)),
+			r.TrashComments(ctx, []string{b}, now),
+			r.AddAttachment(ctx, at),
+			r.AddAttachment(ctx, removed),
+		} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		if gone, err := r.DeleteAttachments(ctx, []string{b}); err != nil || len(gone) != 1 || gone[0].ID != removed.ID {
+			t.Fatalf("wrong removed attachments: %+v, %v", gone, err)
+		}
+		r.Close()
+
+		reopened := openFileRepository(t, dir, compactEvery)
+		if events, err := reopened.FindEvents(ctx, a); err != nil || len(events) != 2 || events[1].ID != 2 || events[1].Action != ActionUpdate {
+			t.Fatalf("wrong events after a restart: %+v, %v", events, err)
+		}
+		if found, err := reopened.FindComment(ctx, cm.ID); err != nil || found.Body != "second" || len(found.Edits) != 1 {
+			t.Fatalf("wrong comment after a restart: %+v, %v", found, err)
+		}
+		if _, err := reopened.FindComment(ctx, trashed.ID); err == nil {
+			t.Fatal("trashed comment found after a restart")
+		}
+		if n, err := reopened.CountBySHA256(ctx, "abc"); err != nil || n != 1 {
+			t.Fatalf("wrong attachments after a restart: %d, %v", n, err)
+		}
+
+		// the events continue after the last one, and the trashed comment can be restored
+		if err := reopened.AddEvent(ctx, Event{TodoID: b, Action: ActionCreate, At: now}); err != nil {
+			t.Fatal(err)
+		}
+		if events, err := reopened.FindEvents(ctx, b); err != nil || len(events) != 1 || events[0].ID != 3 {
+			t.Fatalf("wrong event after a restart: %+v, %v", events, err)
+		}
+		if err := reopened.RestoreComments(ctx, []string{b}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := reopened.FindComment(ctx, trashed.ID); err != nil {
+			t.Fatalf("restored comment not found: %v", err)
+		}
+	}
+}
@@ -4,22 +4,34 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"time"
 
 	"golang.org/x/exp/slices"
 )
 
 type repositoryMem struct {
 	data map[string]Todo
-	m    sync.RWMutex
+	// trash holds the deleted todo's until they are restored or purged
+	trash map[string]Todo
+	lists map[string]List
+	// blockers maps a todo to the todo's it depends on
+	blockers map[string]map[string]bool
+	m        sync.RWMutex
 }
 
 func NewInMemoryRepository() Repository {
 	return &repositoryMem{
-		data: make(map[string]Todo),
+		data:     make(map[string]Todo),
+		trash:    make(map[string]Todo),
+		lists:    make(map[string]List),
+		blockers: make(map[string]map[string]bool),
 	}
 }
 
-func (r *repositoryMem) Add(_ context.Context, td Todo) error {
+func (r *repositoryMem) Add(ctx context.Context, td Todo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
 	r.m.Lock()
 	defer r.m.Unlock()
@@ -27,59 +39,328 @@ func (r *repositoryMem) Add(_ context.Context, td Todo) error {
 	if _, ok := r.data[td.ID]; ok {
 		return fmt.Errorf("a todo with this ID already exists")
 	}
+	if _, ok := r.trash[td.ID]; ok {
+		return fmt.Errorf("a todo with this ID already exists")
+	}
+	if _, ok := r.lists[td.ListID]; td.ListID != "" && !ok {
+		return ErrListNotFound{td.ListID}
+	}
+	if err := r.checkParent(td.ID, td.ParentID); err != nil {
+		return err
+	}
+	td.Version = 1
+	td.BlockedBy = nil
+	td.DeletedAt = nil
 	r.data[td.ID] = td
 
 	return nil
 }
 
-func (r *repositoryMem) Delete(_ context.Context, id string) error {
+// Delete moves the todo and its subtasks to the trash
+func (r *repositoryMem) Delete(ctx context.Context, id string, version int64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.delete(id, version, time.Now().UTC())
+}
+
+// delete moves the todo and its subtasks to the trash at the time. The lock must be held.
+func (r *repositoryMem) delete(id string, version int64, now time.Time) error {
+	old, ok := r.data[id]
+	if !ok {
+		return nil
+	}
+	if version != 0 && old.Version != version {
+		return ErrVersionMismatch{id}
+	}
+
+	for _, td := range append(r.descendants(r.data, id), old) {
+		td.DeletedAt = &now
+		r.trash[td.ID] = td
+		delete(r.data, td.ID)
+	}
+
+	return nil
+}
+
+// ListTrash returns the deleted todo's, the last deleted first
+func (r *repositoryMem) ListTrash(ctx context.Context) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	all := make([]Todo, 0, len(r.trash))
+	for _, td := range r.trash {
+		all = append(all, td)
+	}
+
+	slices.SortFunc(all, func(a, b Todo) bool {
+		if !a.DeletedAt.Equal(*b.DeletedAt) {
+			return a.DeletedAt.After(*b.DeletedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	return all, nil
+}
+
+// Restore takes the todo out of the trash, together with the subtasks that were deleted with it
+func (r *repositoryMem) Restore(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	td, ok := r.trash[id]
+	if !ok {
+		return ErrNotFound{id}
+	}
+	if _, ok := r.data[td.ParentID]; td.ParentID != "" && !ok {
+		return ErrParentNotFound{td.ParentID}
+	}
+	if _, ok := r.lists[td.ListID]; td.ListID != "" && !ok {
+		return ErrListNotFound{td.ListID}
+	}
+
+	children := make(map[string][]Todo)
+	for _, d := range r.trash {
+		if d.ParentID != "" {
+			children[d.ParentID] = append(children[d.ParentID], d)
+		}
+	}
+
+	restored := []Todo{td}
+	for i := 0; i < len(restored); i++ {
+		for _, c := range children[restored[i].ID] {
+			// subtasks deleted before their parent stay in the trash
+			if c.DeletedAt.Equal(*td.DeletedAt) {
+				restored = append(restored, c)
+			}
+		}
+	}
+
+	for _, d := range restored {
+		d.DeletedAt = nil
+		d.Version++
+		r.data[d.ID] = d
+		delete(r.trash, d.ID)
+	}
+
+	return nil
+}
+
+// Purge removes the todo's that were deleted before the time for good
+func (r *repositoryMem) Purge(ctx context.Context, before time.Time) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
 	r.m.Lock()
 	defer r.m.Unlock()
 
+	n := 0
+	for id, td := range r.trash {
+		if td.DeletedAt.Before(before) {
+			delete(r.trash, id)
+			r.forget(id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// deleteTree removes the todo and its subtasks for good, also those in the trash. The lock must be held.
+func (r *repositoryMem) deleteTree(id string) {
+	for _, td := range append(r.descendants(r.data, id), r.descendants(r.trash, id)...) {
+		r.remove(td.ID)
+	}
+	r.remove(id)
+}
+
+// remove deletes the todo and its dependencies. The lock must be held.
+func (r *repositoryMem) remove(id string) {
 	delete(r.data, id)
+	delete(r.trash, id)
+	r.forget(id)
+}
+
+// forget removes the dependencies of the todo. The lock must be held.
+func (r *repositoryMem) forget(id string) {
+	delete(r.blockers, id)
+	for _, b := range r.blockers {
+		delete(b, id)
+	}
+}
+
+// withBlockers sets the open blockers of the todo. The lock must be held.
+func (r *repositoryMem) withBlockers(td Todo) Todo {
+	td.BlockedBy = nil
+	for b := range r.blockers[td.ID] {
+		if blocker, ok := r.data[b]; ok && blocker.CompletedAt == nil {
+			td.BlockedBy = append(td.BlockedBy, b)
+		}
+	}
+	slices.Sort(td.BlockedBy)
+
+	return td
+}
+
+// descendants returns the subtasks of the todo at any depth, from the todo's or the trash.
+// The lock must be held.
+func (r *repositoryMem) descendants(from map[string]Todo, id string) []Todo {
+	children := make(map[string][]Todo)
+	for _, td := range from {
+		if td.ParentID != "" {
+			children[td.ParentID] = append(children[td.ParentID], td)
+		}
+	}
+
+	var all []Todo
+	for next := []string{id}; len(next) > 0; {
+		var level []string
+		for _, p := range next {
+			for _, td := range children[p] {
+				all = append(all, td)
+				level = append(level, td.ID)
+			}
+		}
+		next = level
+	}
+
+	return all
+}
+
+// checkParent walks up from the parent to make sure the todo is not one of its ancestors.
+// The lock must be held.
+func (r *repositoryMem) checkParent(id, parentID string) error {
+	for p := parentID; p != ""; {
+		if p == id {
+			return ErrCycle{id}
+		}
+		parent, ok := r.data[p]
+		if !ok {
+			return ErrParentNotFound{p}
+		}
+		p = parent.ParentID
+	}
 
 	return nil
 }
 
-func (r *repositoryMem) Update(_ context.Context, id string, td Todo) error {
+func (r *repositoryMem) FindDescendants(ctx context.Context, id string) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	all := r.descendants(r.data, id)
+	for i := range all {
+		all[i] = r.withBlockers(all[i])
+	}
+	slices.SortFunc(all, func(a, b Todo) bool { return a.ID < b.ID })
+
+	return all, nil
+}
+
+func (r *repositoryMem) Update(ctx context.Context, id string, td Todo) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	r.m.Lock()
 	defer r.m.Unlock()
 
-	if _, ok := r.data[id]; !ok {
-		return fmt.Errorf("a todo with this ID does not exist")
+	old, ok := r.data[id]
+	if !ok {
+		return ErrNotFound{id}
+	}
+	if td.Version != 0 && old.Version != td.Version {
+		return ErrVersionMismatch{id}
+	}
+	if _, ok := r.lists[td.ListID]; td.ListID != "" && !ok {
+		return ErrListNotFound{td.ListID}
+	}
+	if err := r.checkParent(id, td.ParentID); err != nil {
+		return err
 	}
 
+	// an update does not move the todo to the trash
+	td.ID = id
+	td.DeletedAt = nil
+	td.Version = old.Version + 1
+	td.BlockedBy = nil
 	r.data[id] = td
 
 	return nil
 }
 
-func (r *repositoryMem) ListAll(_ context.Context) ([]Todo, error) {
+func (r *repositoryMem) ListAll(ctx context.Context, srt Sort) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
 	all := make([]Todo, 0)
 
 	r.m.RLock()
 	for _, td := range r.data {
-		all = append(all, td)
+		all = append(all, r.withBlockers(td))
 	}
 	r.m.RUnlock()
 
+	slices.SortFunc(all, srt.Less)
+
 	return all, nil
 }
 
-func (r *repositoryMem) FindByID(_ context.Context, id string) (Todo, error) {
+// FindPage returns the todo's selected by the query. The map is sorted on every call,
+// the cursor of the query is the position after which the page starts.
+func (r *repositoryMem) FindPage(ctx context.Context, q Query) (Page, error) {
+	if err := ctx.Err(); err != nil {
+		return Page{}, err
+	}
+
+	all := r.filter(func(td Todo) bool {
+		return q.Filter == nil || q.Filter.Match(td)
+	})
+
+	slices.SortFunc(all, q.Sort.Less)
+
+	return paginate(all, q), nil
+}
+
+func (r *repositoryMem) FindByID(ctx context.Context, id string) (Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return Todo{}, err
+	}
+
 	r.m.RLock()
 	defer r.m.RUnlock()
 
 	td, ok := r.data[id]
 	if !ok {
-		return Todo{}, fmt.Errorf("a todo with this ID does not exist")
+		return Todo{}, ErrNotFound{id}
 	}
 
-	return td, nil
+	return r.withBlockers(td), nil
 }
 
-func (r *repositoryMem) FindByTag(_ context.Context, tg string) ([]Todo, error) {
+func (r *repositoryMem) FindByTag(ctx context.Context, tg string, srt Sort) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	r.m.RLock()
 	defer r.m.RUnlock()
 
@@ -87,9 +368,165 @@ func (r *repositoryMem) FindByTag(_ context.Context, tg string) ([]Todo, error)
 
 	for _, v := range r.data {
 		if slices.Contains(v.Tags, tg) {
+			all = append(all, r.withBlockers(v))
+		}
+	}
+
+	slices.SortFunc(all, srt.Less)
+
+	return all, nil
+}
+
+// FindDue returns the todo's with a due date in [after, before). A zero time leaves that end open.
+func (r *repositoryMem) FindDue(ctx context.Context, after, before time.Time) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return r.filter(func(td Todo) bool {
+		if td.DueAt == nil {
+			return false
+		}
+		if !after.IsZero() && td.DueAt.Before(after) {
+			return false
+		}
+		if !before.IsZero() && !td.DueAt.Before(before) {
+			return false
+		}
+		return true
+	}), nil
+}
+
+func (r *repositoryMem) FindOverdue(ctx context.Context, at time.Time) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return r.filter(func(td Todo) bool {
+		return td.IsOverdue(at)
+	}), nil
+}
+
+func (r *repositoryMem) FindReminders(ctx context.Context, at time.Time) ([]Todo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return r.filter(func(td Todo) bool {
+		return td.CompletedAt == nil && td.RemindAt != nil && !td.RemindAt.After(at)
+	}), nil
+}
+
+func (r *repositoryMem) filter(keep func(Todo) bool) []Todo {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	all := make([]Todo, 0)
+
+	for _, v := range r.data {
+		if v = r.withBlockers(v); keep(v) {
 			all = append(all, v)
 		}
 	}
 
+	return all
+}
+
+// ListTags returns the tags used by at least one todo, sorted by name
+func (r *repositoryMem) ListTags(ctx context.Context) ([]TagCount, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	counts := make(map[string]*TagCount)
+	for _, td := range r.data {
+		for _, tg := range td.Tags {
+			c, ok := counts[tg]
+			if !ok {
+				c = &TagCount{Name: tg}
+				counts[tg] = c
+			}
+			if td.CompletedAt == nil {
+				c.Open++
+			} else {
+				c.Completed++
+			}
+		}
+	}
+
+	all := make([]TagCount, 0, len(counts))
+	for _, c := range counts {
+		all = append(all, *c)
+	}
+	slices.SortFunc(all, func(a, b TagCount) bool { return a.Name < b.Name })
+
 	return all, nil
 }
+
+// RenameTag changes the tag on all the todo's. Renaming to a tag that is already used is an error.
+func (r *repositoryMem) RenameTag(ctx context.Context, from, to string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if from != to && r.tagUsed(to) {
+		return ErrTagExists{to}
+	}
+
+	return r.mergeTag(from, to)
+}
+
+// MergeTag replaces the tag `from` with `into` on all the todo's
+func (r *repositoryMem) MergeTag(ctx context.Context, from, into string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	return r.mergeTag(from, into)
+}
+
+// mergeTag expects the write lock to be held
+func (r *repositoryMem) mergeTag(from, into string) error {
+	if !r.tagUsed(from) {
+		return ErrTagNotFound{from}
+	}
+	if from == into {
+		return nil
+	}
+
+	// the todo's in the trash are changed too, so they are restored with the new tag
+	for _, m := range []map[string]Todo{r.data, r.trash} {
+		for id, td := range m {
+			idx := slices.Index(td.Tags, from)
+			if idx < 0 {
+				continue
+			}
+
+			tags := slices.Clone(td.Tags)
+			tags[idx] = into
+			td.Tags = Todo{Tags: tags}.UniqueTags()
+			td.Version++
+			m[id] = td
+		}
+	}
+
+	return nil
+}
+
+func (r *repositoryMem) tagUsed(tag string) bool {
+	for _, td := range r.data {
+		if slices.Contains(td.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
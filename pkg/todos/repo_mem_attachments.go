@@ -0,0 +1,100 @@
+package todos
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+type attachmentsMem struct {
+	data map[string]Attachment
+	m    sync.RWMutex
+}
+
+func NewInMemoryAttachments() AttachmentRepository {
+	return &attachmentsMem{data: make(map[string]Attachment)}
+}
+
+func (a *attachmentsMem) AddAttachment(_ context.Context, at Attachment) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	a.data[at.ID] = at
+
+	return nil
+}
+
+func (a *attachmentsMem) FindAttachment(_ context.Context, id string) (Attachment, error) {
+	a.m.RLock()
+	defer a.m.RUnlock()
+
+	at, ok := a.data[id]
+	if !ok {
+		return Attachment{}, ErrAttachmentNotFound{id}
+	}
+
+	return at, nil
+}
+
+func (a *attachmentsMem) FindAttachments(_ context.Context, todoID string) ([]Attachment, error) {
+	a.m.RLock()
+	defer a.m.RUnlock()
+
+	all := make([]Attachment, 0)
+	for _, at := range a.data {
+		if at.TodoID == todoID {
+			all = append(all, at)
+		}
+	}
+
+	slices.SortFunc(all, func(x, y Attachment) bool {
+		if !x.CreatedAt.Equal(y.CreatedAt) {
+			return x.CreatedAt.Before(y.CreatedAt)
+		}
+		return x.ID < y.ID
+	})
+
+	return all, nil
+}
+
+func (a *attachmentsMem) DeleteAttachment(_ context.Context, id string) error {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	if _, ok := a.data[id]; !ok {
+		return ErrAttachmentNotFound{id}
+	}
+	delete(a.data, id)
+
+	return nil
+}
+
+func (a *attachmentsMem) DeleteAttachments(_ context.Context, todoIDs []string) ([]Attachment, error) {
+	a.m.Lock()
+	defer a.m.Unlock()
+
+	var removed []Attachment
+	for id, at := range a.data {
+		if slices.Contains(todoIDs, at.TodoID) {
+			removed = append(removed, at)
+			delete(a.data, id)
+		}
+	}
+
+	return removed, nil
+}
+
+func (a *attachmentsMem) CountBySHA256(_ context.Context, sum string) (int, error) {
+	a.m.RLock()
+	defer a.m.RUnlock()
+
+	n := 0
+	for _, at := range a.data {
+		if at.SHA256 == sum {
+			n++
+		}
+	}
+
+	return n, nil
+}
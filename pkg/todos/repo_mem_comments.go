@@ -0,0 +1,136 @@
+package todos
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+type commentsMem struct {
+	data map[string]Comment
+	// trashed holds the time the comments were trashed with their todo
+	trashed map[string]time.Time
+	m       sync.RWMutex
+}
+
+func NewInMemoryComments() CommentRepository {
+	return &commentsMem{
+		data:    make(map[string]Comment),
+		trashed: make(map[string]time.Time),
+	}
+}
+
+func (c *commentsMem) AddComment(_ context.Context, cm Comment) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cm.Edits = nil
+	c.data[cm.ID] = cm
+
+	return nil
+}
+
+func (c *commentsMem) FindComment(_ context.Context, id string) (Comment, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	cm, ok := c.data[id]
+	if _, trashed := c.trashed[id]; !ok || trashed {
+		return Comment{}, ErrCommentNotFound{id}
+	}
+
+	return cm, nil
+}
+
+func (c *commentsMem) FindComments(_ context.Context, todoID string) ([]Comment, error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+
+	all := make([]Comment, 0)
+	for id, cm := range c.data {
+		if _, trashed := c.trashed[id]; cm.TodoID == todoID && !trashed {
+			all = append(all, cm)
+		}
+	}
+
+	slices.SortFunc(all, func(a, b Comment) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.Before(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	})
+
+	return all, nil
+}
+
+func (c *commentsMem) UpdateComment(_ context.Context, id, body string, at time.Time) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	cm, ok := c.data[id]
+	if _, trashed := c.trashed[id]; !ok || trashed {
+		return ErrCommentNotFound{id}
+	}
+
+	cm.Edits = append(slices.Clip(cm.Edits), CommentEdit{Body: cm.Body, EditedAt: at})
+	cm.Body = body
+	cm.UpdatedAt = &at
+	c.data[id] = cm
+
+	return nil
+}
+
+func (c *commentsMem) DeleteComment(_ context.Context, id string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if _, ok := c.data[id]; !ok {
+		return ErrCommentNotFound{id}
+	}
+	delete(c.data, id)
+	delete(c.trashed, id)
+
+	return nil
+}
+
+func (c *commentsMem) TrashComments(_ context.Context, todoIDs []string, at time.Time) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for id, cm := range c.data {
+		if _, trashed := c.trashed[id]; !trashed && slices.Contains(todoIDs, cm.TodoID) {
+			c.trashed[id] = at
+		}
+	}
+
+	return nil
+}
+
+func (c *commentsMem) RestoreComments(_ context.Context, todoIDs []string) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for id := range c.trashed {
+		if slices.Contains(todoIDs, c.data[id].TodoID) {
+			delete(c.trashed, id)
+		}
+	}
+
+	return nil
+}
+
+func (c *commentsMem) PurgeComments(_ context.Context, before time.Time) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for id, at := range c.trashed {
+		if at.Before(before) {
+			delete(c.data, id)
+			delete(c.trashed, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package todos
+
+import "context"
+
+func (r *repositoryMem) AddDependency(ctx context.Context, d Dependency) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for _, id := range []string{d.TodoID, d.BlockerID} {
+		if _, ok := r.data[id]; !ok {
+			return ErrNotFound{id}
+		}
+	}
+
+	if r.dependsOn(d.BlockerID, d.TodoID) {
+		return ErrDependencyCycle{d.TodoID, d.BlockerID}
+	}
+
+	if r.blockers[d.TodoID] == nil {
+		r.blockers[d.TodoID] = make(map[string]bool)
+	}
+	r.blockers[d.TodoID][d.BlockerID] = true
+
+	return nil
+}
+
+// dependsOn reports whether the todo is, or depends on, the other todo at any distance. The lock must be held.
+func (r *repositoryMem) dependsOn(id, other string) bool {
+	seen := make(map[string]bool)
+	for next := []string{id}; len(next) > 0; {
+		var level []string
+		for _, n := range next {
+			if n == other {
+				return true
+			}
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			for b := range r.blockers[n] {
+				level = append(level, b)
+			}
+		}
+		next = level
+	}
+
+	return false
+}
+
+// RemoveDependency removes the dependency. Removing a missing dependency is not an error.
+func (r *repositoryMem) RemoveDependency(ctx context.Context, d Dependency) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	delete(r.blockers[d.TodoID], d.BlockerID)
+
+	return nil
+}
+
+// FindDependencies leaves out the dependencies with a todo in the trash
+func (r *repositoryMem) FindDependencies(ctx context.Context, ids []string) ([]Dependency, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	all := make([]Dependency, 0)
+	for id, blockers := range r.blockers {
+		for b := range blockers {
+			_, todoFound := r.data[id]
+			_, blockerFound := r.data[b]
+			if (wanted[id] || wanted[b]) && todoFound && blockerFound {
+				all = append(all, Dependency{TodoID: id, BlockerID: b})
+			}
+		}
+	}
+	sortDependencies(all)
+
+	return all, nil
+}
@@ -0,0 +1,39 @@
+package todos
+
+import (
+	"context"
+	"sync"
+)
+
+type historyMem struct {
+	events map[string][]Event
+	lastID int64
+	m      sync.RWMutex
+}
+
+func NewInMemoryHistory() HistoryRepository {
+	return &historyMem{
+		events: make(map[string][]Event),
+	}
+}
+
+func (h *historyMem) AddEvent(_ context.Context, e Event) error {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	h.lastID++
+	e.ID = h.lastID
+	h.events[e.TodoID] = append(h.events[e.TodoID], e)
+
+	return nil
+}
+
+func (h *historyMem) FindEvents(_ context.Context, id string) ([]Event, error) {
+	h.m.RLock()
+	defer h.m.RUnlock()
+
+	all := make([]Event, len(h.events[id]))
+	copy(all, h.events[id])
+
+	return all, nil
+}
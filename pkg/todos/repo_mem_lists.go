@@ -0,0 +1,108 @@
+package todos
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+func (r *repositoryMem) FindList(ctx context.Context, id string) (List, error) {
+	if err := ctx.Err(); err != nil {
+		return List{}, err
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	l, ok := r.lists[id]
+	if !ok {
+		return List{}, ErrListNotFound{id}
+	}
+
+	return l, nil
+}
+
+// ListLists returns the lists sorted by name. Archived lists are only returned when archived is true.
+func (r *repositoryMem) ListLists(ctx context.Context, archived bool) ([]List, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	all := make([]List, 0, len(r.lists))
+	for _, l := range r.lists {
+		if archived || l.ArchivedAt == nil {
+			all = append(all, l)
+		}
+	}
+
+	slices.SortFunc(all, func(a, b List) bool {
+		if la, lb := strings.ToLower(a.Name), strings.ToLower(b.Name); la != lb {
+			return la < lb
+		}
+		return a.ID < b.ID
+	})
+
+	return all, nil
+}
+
+func (r *repositoryMem) AddList(ctx context.Context, l List) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.lists[l.ID]; ok {
+		return fmt.Errorf("a list with this ID already exists")
+	}
+	r.lists[l.ID] = l
+
+	return nil
+}
+
+func (r *repositoryMem) UpdateList(ctx context.Context, l List) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.lists[l.ID]; !ok {
+		return ErrListNotFound{l.ID}
+	}
+	r.lists[l.ID] = l
+
+	return nil
+}
+
+func (r *repositoryMem) DeleteList(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if _, ok := r.lists[id]; !ok {
+		return ErrListNotFound{id}
+	}
+
+	// the todo's of the list in the trash go with it too
+	for _, todos := range []map[string]Todo{r.data, r.trash} {
+		for tid, td := range todos {
+			if td.ListID == id {
+				r.deleteTree(tid)
+			}
+		}
+	}
+	delete(r.lists, id)
+
+	return nil
+}
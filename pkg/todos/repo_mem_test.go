@@ -2,11 +2,14 @@ package todos
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/exp/slices"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -42,7 +45,7 @@ func TestDeleteSuccess(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if err := r.Delete(ctx, id); err != nil {
+	if err := r.Delete(ctx, id, 0); err != nil {
 		t.Fatal(err)
 	}
 
@@ -77,7 +80,7 @@ func TestFetchAllAfterConcurrentInserts(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	all, err := r.ListAll(ctx)
+	all, err := r.ListAll(ctx, nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,3 +114,450 @@ func TestUpdate(t *testing.T) {
 		t.Fatal("did not update the todo")
 	}
 }
+
+func TestFindDueAndOverdue(t *testing.T) {
+	ctx := context.TODO()
+
+	now := time.Now()
+	yesterday := now.Add(-24 * time.Hour)
+	tomorrow := now.Add(24 * time.Hour)
+
+	r := NewInMemoryRepository()
+	for _, td := range []Todo{
+		{ID: "late", Title: "late", DueAt: &yesterday},
+		{ID: "done", Title: "done", DueAt: &yesterday, CompletedAt: &now},
+		{ID: "soon", Title: "soon", DueAt: &tomorrow, RemindAt: &yesterday},
+		{ID: "never", Title: "never"},
+	} {
+		if err := r.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	overdue, err := r.FindOverdue(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overdue) != 1 || overdue[0].ID != "late" {
+		t.Fatalf("wrong overdue todos: %v", overdue)
+	}
+
+	due, err := r.FindDue(ctx, time.Time{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 {
+		t.Fatalf("wrong number of due todos. expected: %d, got: %d", 2, len(due))
+	}
+
+	due, err = r.FindDue(ctx, now, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 1 || due[0].ID != "soon" {
+		t.Fatalf("wrong due todos: %v", due)
+	}
+
+	remind, err := r.FindReminders(ctx, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(remind) != 1 || remind[0].ID != "soon" {
+		t.Fatalf("wrong reminders: %v", remind)
+	}
+}
+
+func TestListAllSorted(t *testing.T) {
+	ctx := context.TODO()
+
+	now := time.Now()
+	later := now.Add(time.Hour)
+
+	r := NewInMemoryRepository()
+	for _, td := range []Todo{
+		{ID: "1", Title: "b", Priority: PriorityHigh},
+		{ID: "2", Title: "A", Priority: PriorityHigh, DueAt: &now},
+		{ID: "3", Title: "c", Priority: PriorityHigh, DueAt: &later},
+		{ID: "4", Title: "a", Priority: PriorityLow},
+		{ID: "5", Title: "a", Priority: PriorityUrgent},
+	} {
+		if err := r.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srt, err := ParseSort("-priority,due_at,title")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := r.ListAll(ctx, srt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids string
+	for _, td := range all {
+		ids += td.ID
+	}
+	if ids != "52314" {
+		t.Fatalf("wrong order. expected: %s, got: %s", "52314", ids)
+	}
+
+	if _, err := ParseSort("-colour"); err == nil {
+		t.Fatal("sorting by an unknown field should fail")
+	}
+}
+
+func TestFindPageFollowsCursor(t *testing.T) {
+	ctx := context.TODO()
+
+	r := NewInMemoryRepository()
+	for i := 0; i < 25; i++ {
+		td := Todo{ID: uuid.NewString(), Title: fmt.Sprintf("todo %d", i%4), Priority: Priority(rand.Intn(5))}
+		if i%3 == 0 {
+			due := time.Now().Add(time.Duration(rand.Intn(3)) * time.Hour)
+			td.DueAt = &due
+		}
+		if err := r.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	srt, err := ParseSort("-priority,due_at,title")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := r.ListAll(ctx, srt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paged []Todo
+	q := Query{Sort: srt, Limit: 7}
+	for pages := 1; ; pages++ {
+		page, err := r.FindPage(ctx, q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		paged = append(paged, page.Todos...)
+
+		if page.Next == nil {
+			if pages != 4 {
+				t.Fatalf("wrong number of pages. expected: %d, got: %d", 4, pages)
+			}
+			break
+		}
+
+		// the cursor goes through the client and back
+		if q.After, err = ParseCursor(page.Next.String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(paged) != len(all) {
+		t.Fatalf("wrong number of todos. expected: %d, got: %d", len(all), len(paged))
+	}
+	for i := range all {
+		if all[i].ID != paged[i].ID {
+			t.Fatalf("pages out of order at %d", i)
+		}
+	}
+}
+
+func TestFindByTagIsExact(t *testing.T) {
+	ctx := context.TODO()
+
+	svc := NewService(WithRepo(NewInMemoryRepository()))
+	for _, td := range []Todo{
+		{Title: "go", Tags: []string{" Go ", "go"}},
+		{Title: "golang", Tags: []string{"golang"}},
+		{Title: "comma", Tags: []string{"one, two"}},
+	} {
+		if _, err := svc.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for tag, title := range map[string]string{"go": "go", "golang": "golang", "one, two": "comma"} {
+		found, err := svc.FindByTags(ctx, []string{tag}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(found) != 1 || found[0].Title != title {
+			t.Fatalf("wrong todos for tag %q: %v", tag, found)
+		}
+		if len(found[0].Tags) != 1 {
+			t.Fatalf("tags not deduplicated: %v", found[0].Tags)
+		}
+	}
+}
+
+func TestRenameAndMergeTags(t *testing.T) {
+	ctx := context.TODO()
+
+	now := time.Now()
+
+	r := NewInMemoryRepository()
+	for _, td := range []Todo{
+		{ID: "1", Tags: []string{"go", "work"}},
+		{ID: "2", Tags: []string{"golang"}, CompletedAt: &now},
+		{ID: "3", Tags: []string{"work"}},
+	} {
+		if err := r.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var exists ErrTagExists
+	if err := r.RenameTag(ctx, "go", "work"); !errors.As(err, &exists) {
+		t.Fatalf("renaming to a used tag should fail. got: %v", err)
+	}
+	var notFound ErrTagNotFound
+	if err := r.MergeTag(ctx, "rust", "work"); !errors.As(err, &notFound) {
+		t.Fatalf("merging a missing tag should fail. got: %v", err)
+	}
+
+	if err := r.MergeTag(ctx, "golang", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenameTag(ctx, "work", "job"); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := r.ListTags(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []TagCount{{Name: "go", Open: 1, Completed: 1}, {Name: "job", Open: 2}}
+	if !slices.Equal(all, expected) {
+		t.Fatalf("wrong tags. expected: %v, got: %v", expected, all)
+	}
+}
+
+func TestUpdateComparesVersions(t *testing.T) {
+	ctx := context.TODO()
+
+	id := uuid.NewString()
+
+	r := NewInMemoryRepository()
+	if err := r.Add(ctx, Todo{ID: id, Title: "first"}); err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := r.FindByID(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if td.Version != 1 {
+		t.Fatalf("wrong version of a new todo. expected: %d, got: %d", 1, td.Version)
+	}
+
+	td.Title = "second"
+	if err := r.Update(ctx, id, td); err != nil {
+		t.Fatal(err)
+	}
+
+	// td still has version 1
+	var mismatch ErrVersionMismatch
+	if err := r.Update(ctx, id, td); !errors.As(err, &mismatch) {
+		t.Fatalf("updating an old version should fail. got: %v", err)
+	}
+	if err := r.Delete(ctx, id, td.Version); !errors.As(err, &mismatch) {
+		t.Fatalf("deleting an old version should fail. got: %v", err)
+	}
+
+	if err := r.Delete(ctx, id, td.Version+1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteListRemovesItsTodos(t *testing.T) {
+	ctx := context.TODO()
+
+	r := NewInMemoryRepository()
+
+	var notFound ErrListNotFound
+	if err := r.Add(ctx, Todo{ID: "1", ListID: "missing"}); !errors.As(err, &notFound) {
+		t.Fatalf("adding to a missing list should fail. got: %v", err)
+	}
+
+	for _, l := range []List{{ID: "home", Name: "Home"}, {ID: "work", Name: "Work"}} {
+		if err := r.AddList(ctx, l); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, td := range []Todo{{ID: "1", ListID: "home"}, {ID: "2", ListID: "work"}, {ID: "3"}} {
+		if err := r.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.DeleteList(ctx, "home"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FindList(ctx, "home"); !errors.As(err, &notFound) {
+		t.Fatalf("deleted list still found. got: %v", err)
+	}
+
+	all, err := r.ListAll(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, td := range all {
+		ids = append(ids, td.ID)
+	}
+	if expected := []string{"2", "3"}; !slices.Equal(ids, expected) {
+		t.Fatalf("wrong todos after deleting the list. expected: %v, got: %v", expected, ids)
+	}
+}
+
+func TestSubtasksRejectCycles(t *testing.T) {
+	ctx := context.TODO()
+
+	r := NewInMemoryRepository()
+	for _, td := range []Todo{{ID: "1"}, {ID: "2", ParentID: "1"}, {ID: "3", ParentID: "2"}, {ID: "4"}} {
+		if err := r.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cycle ErrCycle
+	if err := r.Update(ctx, "1", Todo{ID: "1", ParentID: "3"}); !errors.As(err, &cycle) {
+		t.Fatalf("moving a todo under its own subtask should fail. got: %v", err)
+	}
+	if err := r.Update(ctx, "1", Todo{ID: "1", ParentID: "1"}); !errors.As(err, &cycle) {
+		t.Fatalf("a todo cannot be its own parent. got: %v", err)
+	}
+	var notFound ErrParentNotFound
+	if err := r.Add(ctx, Todo{ID: "5", ParentID: "missing"}); !errors.As(err, &notFound) {
+		t.Fatalf("adding under a missing parent should fail. got: %v", err)
+	}
+
+	descendants, err := r.FindDescendants(ctx, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descendants) != 2 {
+		t.Fatalf("wrong number of descendants. expected: 2, got: %d", len(descendants))
+	}
+
+	if err := r.Delete(ctx, "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	all, err := r.ListAll(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ID != "4" {
+		t.Fatalf("subtasks not deleted with their parent: %v", all)
+	}
+}
+
+func TestDependenciesBlockUntilCompleted(t *testing.T) {
+	ctx := context.TODO()
+
+	r := NewInMemoryRepository()
+	for _, id := range []string{"1", "2", "3"} {
+		if err := r.Add(ctx, Todo{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 1 blocks 2, 2 blocks 3
+	for _, d := range []Dependency{{TodoID: "2", BlockerID: "1"}, {TodoID: "3", BlockerID: "2"}} {
+		if err := r.AddDependency(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cycle ErrDependencyCycle
+	if err := r.AddDependency(ctx, Dependency{TodoID: "1", BlockerID: "3"}); !errors.As(err, &cycle) {
+		t.Fatalf("a dependency cycle should be rejected. got: %v", err)
+	}
+
+	blocked := func() []string {
+		page, err := r.FindPage(ctx, Query{Filter: BlockedCond{true}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, td := range page.Todos {
+			ids = append(ids, td.ID)
+		}
+		return ids
+	}
+
+	if got := blocked(); !slices.Equal(got, []string{"2", "3"}) {
+		t.Fatalf("wrong blocked todos: %v", got)
+	}
+
+	now := time.Now()
+	if err := r.Update(ctx, "1", Todo{ID: "1", CompletedAt: &now}); err != nil {
+		t.Fatal(err)
+	}
+	if got := blocked(); !slices.Equal(got, []string{"3"}) {
+		t.Fatalf("wrong blocked todos after completing the blocker: %v", got)
+	}
+
+	if err := r.Delete(ctx, "2", 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := blocked(); len(got) != 0 {
+		t.Fatalf("dependencies of a deleted todo still block: %v", got)
+	}
+}
+
+func TestTrashRestoreAndPurge(t *testing.T) {
+	ctx := context.TODO()
+
+	r := NewInMemoryRepository()
+	for _, td := range []Todo{{ID: "1"}, {ID: "2", ParentID: "1"}, {ID: "3"}} {
+		if err := r.Add(ctx, td); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := r.Delete(ctx, "1", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FindByID(ctx, "2"); err == nil {
+		t.Fatal("the subtask of a deleted todo is still found")
+	}
+
+	trash, err := r.ListTrash(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 2 || trash[0].DeletedAt == nil {
+		t.Fatalf("wrong trash: %v", trash)
+	}
+
+	var notFound ErrNotFound
+	if err := r.Restore(ctx, "3"); !errors.As(err, &notFound) {
+		t.Fatalf("restoring a todo that is not in the trash should fail. got: %v", err)
+	}
+	if err := r.Restore(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FindByID(ctx, "2"); err != nil {
+		t.Fatalf("the subtask was not restored with its parent: %v", err)
+	}
+
+	if err := r.Delete(ctx, "3", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("purged todos deleted after the time: %d, %v", n, err)
+	}
+	if n, err := r.Purge(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Fatalf("wrong number of purged todos: %d, %v", n, err)
+	}
+	if err := r.Restore(ctx, "3"); !errors.As(err, &notFound) {
+		t.Fatalf("a purged todo was restored. got: %v", err)
+	}
+}
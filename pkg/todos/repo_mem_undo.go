@@ -0,0 +1,108 @@
+package todos
+
+import (
+	"context"
+)
+
+// Apply stores the todo's as they are after the revisions. Everything is checked before the first change.
+func (r *repositoryMem) Apply(ctx context.Context, revs []Revision) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	// after holds the todo's changed by the revisions, nil for those removed
+	after := make(map[string]*Todo)
+	for _, rev := range revs {
+		if !r.unchanged(rev.ID, rev.Before) {
+			return ErrVersionMismatch{rev.ID}
+		}
+		after[rev.ID] = rev.After
+	}
+
+	live := func(id string) (Todo, bool) {
+		if t, ok := after[id]; ok {
+			if t == nil || t.DeletedAt != nil {
+				return Todo{}, false
+			}
+			return *t, true
+		}
+		t, ok := r.data[id]
+		return t, ok
+	}
+
+	for id, t := range after {
+		if t == nil {
+			// a todo is only removed for good without subtasks
+			if r.hasChildren(id) {
+				return ErrVersionMismatch{id}
+			}
+			continue
+		}
+		if t.DeletedAt != nil {
+			continue
+		}
+		if _, ok := r.lists[t.ListID]; t.ListID != "" && !ok {
+			return ErrListNotFound{t.ListID}
+		}
+		for p := t.ParentID; p != ""; {
+			if p == id {
+				return ErrCycle{id}
+			}
+			parent, ok := live(p)
+			if !ok {
+				return ErrParentNotFound{p}
+			}
+			p = parent.ParentID
+		}
+	}
+
+	for id, t := range after {
+		delete(r.data, id)
+		delete(r.trash, id)
+		switch {
+		case t == nil:
+			r.forget(id)
+		case t.DeletedAt != nil:
+			td := *t
+			td.BlockedBy = nil
+			r.trash[id] = td
+		default:
+			td := *t
+			td.BlockedBy = nil
+			r.data[id] = td
+		}
+	}
+
+	return nil
+}
+
+// unchanged reports whether the todo is still in the state `before`: missing, in the todo's
+// or in the trash with the same version. The lock must be held.
+func (r *repositoryMem) unchanged(id string, before *Todo) bool {
+	live, inData := r.data[id]
+	deleted, inTrash := r.trash[id]
+
+	switch {
+	case before == nil:
+		return !inData && !inTrash
+	case before.DeletedAt == nil:
+		return inData && live.Version == before.Version
+	default:
+		return inTrash && deleted.Version == before.Version
+	}
+}
+
+// hasChildren reports whether the todo has subtasks, also in the trash. The lock must be held.
+func (r *repositoryMem) hasChildren(id string) bool {
+	for _, m := range []map[string]Todo{r.data, r.trash} {
+		for _, td := range m {
+			if td.ParentID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package todos
+
+import "database/sql"
+
+// The PostgreSQL stores run the same queries as the MariaDB ones, translated by postgresDialect.
+// The schema is in database/migrations/postgres.
+
+func NewPostgresRepository(c *sql.DB) Repository {
+	return &repositoryDB{conn: dbConn{c, postgresDialect}}
+}
+
+func NewPostgresHistory(c *sql.DB) HistoryRepository {
+	return &historyDB{conn: dbConn{c, postgresDialect}}
+}
+
+func NewPostgresComments(c *sql.DB) CommentRepository {
+	return &commentsDB{conn: dbConn{c, postgresDialect}}
+}
+
+func NewPostgresAttachments(c *sql.DB) AttachmentRepository {
+	return &attachmentsDB{conn: dbConn{c, postgresDialect}}
+}
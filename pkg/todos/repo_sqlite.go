@@ -0,0 +1,22 @@
+package todos
+
+import "database/sql"
+
+// The SQLite stores run the same queries as the MariaDB ones, translated by sqliteDialect.
+// The schema is in database/migrations/sqlite.
+
+func NewSqliteRepository(c *sql.DB) Repository {
+	return &repositoryDB{conn: dbConn{c, sqliteDialect}}
+}
+
+func NewSqliteHistory(c *sql.DB) HistoryRepository {
+	return &historyDB{conn: dbConn{c, sqliteDialect}}
+}
+
+func NewSqliteComments(c *sql.DB) CommentRepository {
+	return &commentsDB{conn: dbConn{c, sqliteDialect}}
+}
+
+func NewSqliteAttachments(c *sql.DB) AttachmentRepository {
+	return &attachmentsDB{conn: dbConn{c, sqliteDialect}}
+}
@@ -0,0 +1,687 @@
+// Package repotest is the conformance suite of todos.Repository: every implementation must pass it,
+// so the application behaves the same on all the storages.
+//
+// The service cleans the tags before they reach the repository, so the suite only stores clean tags.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mehix/go-todos/pkg/todos"
+	"golang.org/x/exp/slices"
+	"golang.org/x/sync/errgroup"
+)
+
+// Factory returns a new, empty repository for every test
+type Factory func(t *testing.T) todos.Repository
+
+// Run runs the whole suite, every part as a subtest with its own repository
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		test func(*testing.T, todos.Repository)
+	}{
+		{"CRUD", testCRUD},
+		{"NotFound", testNotFound},
+		{"Tags", testTags},
+		{"SortAndPages", testSortAndPages},
+		{"Trash", testTrash},
+		{"Subtasks", testSubtasks},
+		{"Lists", testLists},
+		{"Dependencies", testDependencies},
+		{"Apply", testApply},
+		{"ConcurrentWriters", testConcurrentWriters},
+		{"ContextCanceled", testContextCanceled},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, factory(t))
+		})
+	}
+}
+
+func add(t *testing.T, r todos.Repository, td todos.Todo) todos.Todo {
+	t.Helper()
+
+	if td.ID == "" {
+		td.ID = uuid.NewString()
+	}
+	if err := r.Add(context.Background(), td); err != nil {
+		t.Fatalf("add %q: %v", td.Title, err)
+	}
+
+	return find(t, r, td.ID)
+}
+
+func find(t *testing.T, r todos.Repository, id string) todos.Todo {
+	t.Helper()
+
+	td, err := r.FindByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("find %s: %v", id, err)
+	}
+
+	return td
+}
+
+func ids(all []todos.Todo) []string {
+	ids := make([]string, len(all))
+	for i, td := range all {
+		ids[i] = td.ID
+	}
+	return ids
+}
+
+func sortedIDs(all []todos.Todo) []string {
+	ids := ids(all)
+	slices.Sort(ids)
+	return ids
+}
+
+func isNotFound(err error) bool {
+	return errors.As(err, new(todos.ErrNotFound))
+}
+
+func testCRUD(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+
+	due := time.Date(2023, 6, 1, 9, 30, 0, 0, time.FixedZone("", 2*3600))
+	remind := due.Add(-time.Hour)
+	td := add(t, r, todos.Todo{Title: "Invoice", Description: "for *June*", Tags: []string{"go", "work"},
+		Priority: todos.PriorityHigh, DueAt: &due, RemindAt: &remind,
+		Checklist: []todos.ChecklistItem{{ID: "a", Text: "send"}, {ID: "b", Text: "file", Done: true}}})
+
+	if td.Version != 1 || td.Title != "Invoice" || td.Description != "for *June*" || td.Priority != todos.PriorityHigh {
+		t.Fatalf("wrong todo: %+v", td)
+	}
+	if td.DueAt == nil || !td.DueAt.Equal(due) || td.DueAt.Format("Z07:00") != "+02:00" || td.RemindAt == nil || !td.RemindAt.Equal(remind) {
+		t.Fatalf("wrong dates: %v, %v", td.DueAt, td.RemindAt)
+	}
+	if !slices.Equal(td.Tags, []string{"go", "work"}) || len(td.Checklist) != 2 || !td.Checklist[1].Done {
+		t.Fatalf("wrong tags or checklist: %v, %+v", td.Tags, td.Checklist)
+	}
+	if td.CompletedAt != nil || td.DeletedAt != nil {
+		t.Fatalf("new todo is completed or deleted: %+v", td)
+	}
+
+	if err := r.Add(ctx, todos.Todo{ID: td.ID, Title: "again"}); err == nil {
+		t.Fatal("a todo was added twice")
+	}
+
+	done := time.Now().UTC().Truncate(time.Second)
+	td.Title = "Invoice sent"
+	td.CompletedAt = &done
+	td.DueAt = nil
+	td.Tags = []string{"work"}
+	if err := r.Update(ctx, td.ID, td); err != nil {
+		t.Fatal(err)
+	}
+	updated := find(t, r, td.ID)
+	if updated.Version != 2 || updated.Title != "Invoice sent" || updated.CompletedAt == nil || !updated.CompletedAt.Equal(done) ||
+		updated.DueAt != nil || !slices.Equal(updated.Tags, []string{"work"}) {
+		t.Fatalf("wrong updated todo: %+v", updated)
+	}
+
+	// td still has the version it was read with
+	if err := r.Update(ctx, td.ID, td); !errors.As(err, new(todos.ErrVersionMismatch)) {
+		t.Fatalf("an old version was updated: %v", err)
+	}
+	if err := r.Delete(ctx, td.ID, td.Version); !errors.As(err, new(todos.ErrVersionMismatch)) {
+		t.Fatalf("an old version was deleted: %v", err)
+	}
+
+	// version 0 skips the check, and the ID is the one of the call
+	updated.Version = 0
+	updated.ID = ""
+	updated.Title = "Invoice paid"
+	if err := r.Update(ctx, td.ID, updated); err != nil {
+		t.Fatal(err)
+	}
+	if found := find(t, r, td.ID); found.ID != td.ID || found.Version != 3 || found.Title != "Invoice paid" {
+		t.Fatalf("wrong todo after an update without version: %+v", found)
+	}
+
+	// the deletion time is only set by Delete
+	deleted := time.Now()
+	updated.DeletedAt = &deleted
+	if err := r.Update(ctx, td.ID, updated); err != nil {
+		t.Fatal(err)
+	}
+	if found := find(t, r, td.ID); found.DeletedAt != nil || found.Version != 4 {
+		t.Fatalf("wrong todo after an update with a deletion time: %+v", found)
+	}
+	if trash, err := r.ListTrash(ctx); err != nil || len(trash) != 0 {
+		t.Fatalf("updated todo in the trash: %v, %v", ids(trash), err)
+	}
+
+	if err := r.Delete(ctx, td.ID, 4); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FindByID(ctx, td.ID); !isNotFound(err) {
+		t.Fatalf("deleted todo is found: %v", err)
+	}
+	if all, err := r.ListAll(ctx, nil); err != nil || len(all) != 0 {
+		t.Fatalf("deleted todo is listed: %v, %v", ids(all), err)
+	}
+}
+
+func testNotFound(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+	missing := uuid.NewString()
+
+	if _, err := r.FindByID(ctx, missing); !isNotFound(err) || !strings.Contains(err.Error(), missing) {
+		t.Fatalf("wrong error of FindByID: %v", err)
+	}
+	if err := r.Update(ctx, missing, todos.Todo{Title: "missing"}); !isNotFound(err) {
+		t.Fatalf("wrong error of Update: %v", err)
+	}
+	if err := r.Update(ctx, missing, todos.Todo{Title: "missing", Version: 1}); !isNotFound(err) {
+		t.Fatalf("wrong error of Update with a version: %v", err)
+	}
+	if err := r.Restore(ctx, missing); !isNotFound(err) {
+		t.Fatalf("wrong error of Restore: %v", err)
+	}
+	if err := r.AddDependency(ctx, todos.Dependency{TodoID: missing, BlockerID: uuid.NewString()}); !isNotFound(err) {
+		t.Fatalf("wrong error of AddDependency: %v", err)
+	}
+
+	// removing what is not there is not an error
+	if err := r.Delete(ctx, missing, 0); err != nil {
+		t.Fatalf("deleting a missing todo failed: %v", err)
+	}
+	if err := r.RemoveDependency(ctx, todos.Dependency{TodoID: missing, BlockerID: missing}); err != nil {
+		t.Fatalf("removing a missing dependency failed: %v", err)
+	}
+
+	if _, err := r.FindList(ctx, missing); !errors.As(err, new(todos.ErrListNotFound)) {
+		t.Fatalf("wrong error of FindList: %v", err)
+	}
+	if err := r.Add(ctx, todos.Todo{ID: uuid.NewString(), Title: "orphan", ListID: missing}); !errors.As(err, new(todos.ErrListNotFound)) {
+		t.Fatalf("wrong error for a todo in a missing list: %v", err)
+	}
+	if err := r.Add(ctx, todos.Todo{ID: uuid.NewString(), Title: "orphan", ParentID: missing}); !errors.As(err, new(todos.ErrParentNotFound)) {
+		t.Fatalf("wrong error for a todo with a missing parent: %v", err)
+	}
+	if err := r.MergeTag(ctx, "missing", "other"); !errors.As(err, new(todos.ErrTagNotFound)) {
+		t.Fatalf("wrong error of MergeTag: %v", err)
+	}
+}
+
+func testTags(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	goTodo := add(t, r, todos.Todo{Title: "go", Tags: []string{"go", "work"}})
+	golang := add(t, r, todos.Todo{Title: "golang", Tags: []string{"golang"}, CompletedAt: &now})
+	comma := add(t, r, todos.Todo{Title: "comma", Tags: []string{"one, two", "work"}})
+	add(t, r, todos.Todo{Title: "untagged"})
+
+	for tag, want := range map[string][]string{
+		"go":       {goTodo.ID},
+		"golang":   {golang.ID},
+		"one, two": {comma.ID},
+		"one":      {},
+		"work":     {comma.ID, goTodo.ID},
+	} {
+		found, err := r.FindByTag(ctx, tag, todos.Sort{{Field: todos.SortByTitle}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids(found), want) {
+			t.Fatalf("wrong todo's for tag %q: %v", tag, ids(found))
+		}
+	}
+
+	tags, err := r.ListTags(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []todos.TagCount{{Name: "go", Open: 1}, {Name: "golang", Completed: 1}, {Name: "one, two", Open: 1}, {Name: "work", Open: 2}}
+	if !slices.Equal(tags, want) {
+		t.Fatalf("wrong tags: %+v", tags)
+	}
+
+	if err := r.RenameTag(ctx, "golang", "go"); !errors.As(err, new(todos.ErrTagExists)) {
+		t.Fatalf("renamed to a tag in use: %v", err)
+	}
+	if err := r.RenameTag(ctx, "one, two", "three"); err != nil {
+		t.Fatal(err)
+	}
+	if found := find(t, r, comma.ID); !slices.Equal(found.Tags, []string{"three", "work"}) || found.Version != comma.Version+1 {
+		t.Fatalf("tag not renamed: %v, version %d", found.Tags, found.Version)
+	}
+	if found := find(t, r, goTodo.ID); found.Version != goTodo.Version {
+		t.Fatalf("todo without the renamed tag changed its version: %d", found.Version)
+	}
+
+	// merging keeps a single copy of the tag on the todo's that had both
+	if err := r.MergeTag(ctx, "work", "go"); err != nil {
+		t.Fatal(err)
+	}
+	if found := find(t, r, goTodo.ID); !slices.Equal(found.Tags, []string{"go"}) || found.Version != goTodo.Version+1 {
+		t.Fatalf("wrong tags after the merge: %v, version %d", found.Tags, found.Version)
+	}
+	if found := find(t, r, comma.ID); found.Version != comma.Version+2 {
+		t.Fatalf("wrong version after the merge: %d", found.Version)
+	}
+	if found := find(t, r, golang.ID); found.Version != golang.Version {
+		t.Fatalf("todo without the merged tag changed its version: %d", found.Version)
+	}
+	found, err := r.FindByTag(ctx, "go", todos.Sort{{Field: todos.SortByTitle}})
+	if err != nil || !slices.Equal(ids(found), []string{comma.ID, goTodo.ID}) {
+		t.Fatalf("wrong todo's after the merge: %v, %v", ids(found), err)
+	}
+	if found, err := r.FindByTag(ctx, "work", nil); err != nil || len(found) != 0 {
+		t.Fatalf("merged tag is still used: %v, %v", ids(found), err)
+	}
+}
+
+func testSortAndPages(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+	day := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	tomorrow := day.AddDate(0, 0, 1)
+
+	c := add(t, r, todos.Todo{ID: "c", Title: "charlie", Priority: todos.PriorityLow, DueAt: &day})
+	a := add(t, r, todos.Todo{ID: "a", Title: "Alpha", Priority: todos.PriorityHigh})
+	b := add(t, r, todos.Todo{ID: "b", Title: "bravo", Priority: todos.PriorityHigh, DueAt: &tomorrow})
+	// titles compare by their bytes whatever the collation of the database: `_` comes before the letters
+	d := add(t, r, todos.Todo{ID: "d", Title: "_delta", Priority: todos.PriorityLow})
+
+	for _, tt := range []struct {
+		sort todos.Sort
+		want []string
+	}{
+		{nil, []string{a.ID, b.ID, c.ID, d.ID}},
+		{todos.Sort{{Field: todos.SortByTitle, Desc: true}}, []string{c.ID, b.ID, a.ID, d.ID}},
+		{todos.Sort{{Field: todos.SortByDueAt}}, []string{c.ID, b.ID, a.ID, d.ID}},
+		// missing dates stay last
+		{todos.Sort{{Field: todos.SortByDueAt, Desc: true}}, []string{b.ID, c.ID, a.ID, d.ID}},
+		{todos.Sort{{Field: todos.SortByPriority, Desc: true}, {Field: todos.SortByTitle}}, []string{a.ID, b.ID, d.ID, c.ID}},
+	} {
+		all, err := r.ListAll(ctx, tt.sort)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(ids(all), tt.want) {
+			t.Fatalf("wrong order for %q: %v", tt.sort, ids(all))
+		}
+	}
+
+	for _, desc := range []bool{false, true} {
+		var seen []string
+		q := todos.Query{Sort: todos.Sort{{Field: todos.SortByDueAt, Desc: desc}}, Limit: 2}
+		for i := 0; ; i++ {
+			page, err := r.FindPage(ctx, q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen = append(seen, ids(page.Todos)...)
+			if page.Next == nil {
+				break
+			}
+			if i > 2 {
+				t.Fatalf("too many pages: %v", seen)
+			}
+			q.After = page.Next
+		}
+		want := []string{c.ID, b.ID, a.ID, d.ID}
+		if desc {
+			want = []string{b.ID, c.ID, a.ID, d.ID}
+		}
+		if !slices.Equal(seen, want) {
+			t.Fatalf("wrong pages for %q: %v", q.Sort, seen)
+		}
+	}
+
+	// the other lists have no order
+	due, err := r.FindDue(ctx, day, tomorrow.Add(time.Hour))
+	if err != nil || !slices.Equal(sortedIDs(due), []string{b.ID, c.ID}) {
+		t.Fatalf("wrong due todo's: %v, %v", ids(due), err)
+	}
+	overdue, err := r.FindOverdue(ctx, day.Add(time.Hour))
+	if err != nil || !slices.Equal(sortedIDs(overdue), []string{c.ID}) {
+		t.Fatalf("wrong overdue todo's: %v, %v", ids(overdue), err)
+	}
+}
+
+func testTrash(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+
+	parent := add(t, r, todos.Todo{Title: "parent"})
+	child := add(t, r, todos.Todo{Title: "child", ParentID: parent.ID})
+	other := add(t, r, todos.Todo{Title: "other"})
+
+	if err := r.Delete(ctx, parent.ID, parent.Version); err != nil {
+		t.Fatal(err)
+	}
+	trash, err := r.ListTrash(ctx)
+	if err != nil || len(trash) != 2 {
+		t.Fatalf("wrong trash: %v, %v", ids(trash), err)
+	}
+	for _, td := range trash {
+		if td.DeletedAt == nil {
+			t.Fatalf("todo in the trash has no deletion time: %+v", td)
+		}
+	}
+	if _, err := r.FindByID(ctx, child.ID); !isNotFound(err) {
+		t.Fatalf("subtask of a deleted todo is found: %v", err)
+	}
+
+	if err := r.Restore(ctx, child.ID); !errors.As(err, new(todos.ErrParentNotFound)) {
+		t.Fatalf("subtask restored without its parent: %v", err)
+	}
+	if err := r.Restore(ctx, parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found := find(t, r, child.ID); found.DeletedAt != nil || found.Version <= child.Version {
+		t.Fatalf("wrong restored subtask: %+v", found)
+	}
+	if err := r.Restore(ctx, parent.ID); !isNotFound(err) {
+		t.Fatalf("todo restored twice: %v", err)
+	}
+
+	if err := r.Delete(ctx, other.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(ctx, parent.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := r.Purge(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Fatalf("recent todo's purged: %d, %v", n, err)
+	}
+	// the subtask is counted with its parent
+	if n, err := r.Purge(ctx, time.Now().Add(time.Hour)); err != nil || n != 3 {
+		t.Fatalf("wrong purge: %d, %v", n, err)
+	}
+	if trash, err := r.ListTrash(ctx); err != nil || len(trash) != 0 {
+		t.Fatalf("trash not empty after the purge: %v, %v", ids(trash), err)
+	}
+	if err := r.Restore(ctx, other.ID); !isNotFound(err) {
+		t.Fatalf("purged todo restored: %v", err)
+	}
+}
+
+func testSubtasks(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+
+	root := add(t, r, todos.Todo{Title: "root"})
+	child := add(t, r, todos.Todo{Title: "child", ParentID: root.ID})
+	grandchild := add(t, r, todos.Todo{Title: "grandchild", ParentID: child.ID})
+	add(t, r, todos.Todo{Title: "unrelated"})
+
+	desc, err := r.FindDescendants(ctx, root.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{child.ID, grandchild.ID}
+	slices.Sort(want)
+	if !slices.Equal(sortedIDs(desc), want) {
+		t.Fatalf("wrong descendants: %v", ids(desc))
+	}
+
+	if desc, err := r.FindDescendants(ctx, uuid.NewString()); err != nil || len(desc) != 0 {
+		t.Fatalf("wrong descendants of a missing todo: %v, %v", ids(desc), err)
+	}
+
+	root.ParentID = grandchild.ID
+	if err := r.Update(ctx, root.ID, root); !errors.As(err, new(todos.ErrCycle)) {
+		t.Fatalf("cycle of subtasks accepted: %v", err)
+	}
+	if found := find(t, r, root.ID); found.ParentID != "" || found.Version != root.Version {
+		t.Fatalf("todo changed by a failed update: %+v", found)
+	}
+}
+
+func testLists(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+
+	l := todos.List{ID: uuid.NewString(), Name: "home"}
+	if err := r.AddList(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := r.FindList(ctx, l.ID); err != nil || found.Name != "home" || found.ArchivedAt != nil {
+		t.Fatalf("wrong list: %+v, %v", found, err)
+	}
+
+	td := add(t, r, todos.Todo{Title: "in the list", ListID: l.ID})
+	if td.ListID != l.ID {
+		t.Fatalf("todo not in the list: %+v", td)
+	}
+
+	archived := time.Now().UTC().Truncate(time.Second)
+	l.ArchivedAt = &archived
+	if err := r.UpdateList(ctx, l); err != nil {
+		t.Fatal(err)
+	}
+	if open, err := r.ListLists(ctx, false); err != nil || len(open) != 0 {
+		t.Fatalf("archived list is listed: %+v, %v", open, err)
+	}
+	if all, err := r.ListLists(ctx, true); err != nil || len(all) != 1 || all[0].ArchivedAt == nil {
+		t.Fatalf("wrong lists: %+v, %v", all, err)
+	}
+	if err := r.UpdateList(ctx, todos.List{ID: uuid.NewString(), Name: "missing"}); !errors.As(err, new(todos.ErrListNotFound)) {
+		t.Fatalf("wrong error for a missing list: %v", err)
+	}
+
+	if err := r.DeleteList(ctx, l.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.FindByID(ctx, td.ID); !isNotFound(err) {
+		t.Fatalf("todo of a deleted list is found: %v", err)
+	}
+	if err := r.DeleteList(ctx, l.ID); !errors.As(err, new(todos.ErrListNotFound)) {
+		t.Fatalf("list deleted twice: %v", err)
+	}
+
+	// trashed todo's of a deleted list are gone, with their subtasks
+	work := todos.List{ID: uuid.NewString(), Name: "work"}
+	if err := r.AddList(ctx, work); err != nil {
+		t.Fatal(err)
+	}
+	trashed := add(t, r, todos.Todo{Title: "trashed in the list", ListID: work.ID})
+	sub := add(t, r, todos.Todo{Title: "subtask", ParentID: trashed.ID})
+	if err := r.Delete(ctx, trashed.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteList(ctx, work.ID); err != nil {
+		t.Fatal(err)
+	}
+	if trash, err := r.ListTrash(ctx); err != nil || len(trash) != 0 {
+		t.Fatalf("todo's of a deleted list in the trash: %v, %v", ids(trash), err)
+	}
+	for _, id := range []string{trashed.ID, sub.ID} {
+		if err := r.Restore(ctx, id); !isNotFound(err) {
+			t.Fatalf("todo of a deleted list restored: %v", err)
+		}
+	}
+}
+
+func testDependencies(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+
+	blocker := add(t, r, todos.Todo{Title: "blocker"})
+	blocked := add(t, r, todos.Todo{Title: "blocked"})
+	d := todos.Dependency{TodoID: blocked.ID, BlockerID: blocker.ID}
+
+	if err := r.AddDependency(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	// adding it again changes nothing
+	if err := r.AddDependency(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddDependency(ctx, todos.Dependency{TodoID: blocker.ID, BlockerID: blocked.ID}); !errors.As(err, new(todos.ErrDependencyCycle)) {
+		t.Fatalf("dependency cycle accepted: %v", err)
+	}
+
+	if found := find(t, r, blocked.ID); !slices.Equal(found.BlockedBy, []string{blocker.ID}) {
+		t.Fatalf("wrong blockers: %v", found.BlockedBy)
+	}
+	if all, err := r.FindDependencies(ctx, []string{blocker.ID}); err != nil || !slices.Equal(all, []todos.Dependency{d}) {
+		t.Fatalf("wrong dependencies: %+v, %v", all, err)
+	}
+
+	done := time.Now().UTC().Truncate(time.Second)
+	blocker.CompletedAt = &done
+	if err := r.Update(ctx, blocker.ID, blocker); err != nil {
+		t.Fatal(err)
+	}
+	if found := find(t, r, blocked.ID); len(found.BlockedBy) != 0 {
+		t.Fatalf("blocked by a completed todo: %v", found.BlockedBy)
+	}
+
+	if err := r.RemoveDependency(ctx, d); err != nil {
+		t.Fatal(err)
+	}
+	if all, err := r.FindDependencies(ctx, []string{blocked.ID}); err != nil || len(all) != 0 {
+		t.Fatalf("dependency not removed: %+v, %v", all, err)
+	}
+}
+
+func testApply(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+
+	kept := add(t, r, todos.Todo{Title: "kept", Tags: []string{"old"}})
+	removed := add(t, r, todos.Todo{Title: "removed"})
+
+	changed := kept
+	changed.Title = "changed"
+	changed.Tags = []string{"new"}
+	changed.Version++
+	added := todos.Todo{ID: uuid.NewString(), Title: "added", Version: 1}
+	revs := []todos.Revision{
+		{ID: kept.ID, Before: &kept, After: &changed},
+		{ID: removed.ID, Before: &removed, After: nil},
+		{ID: added.ID, Before: nil, After: &added},
+	}
+	if err := r.Apply(ctx, revs); err != nil {
+		t.Fatal(err)
+	}
+
+	if found := find(t, r, kept.ID); found.Title != "changed" || found.Version != changed.Version || !slices.Equal(found.Tags, []string{"new"}) {
+		t.Fatalf("revision not applied: %+v", found)
+	}
+	if _, err := r.FindByID(ctx, removed.ID); !isNotFound(err) {
+		t.Fatalf("removed todo is found: %v", err)
+	}
+	if found := find(t, r, added.ID); found.Title != "added" {
+		t.Fatalf("wrong added todo: %+v", found)
+	}
+
+	// the revisions are stale now, and nothing is changed
+	stale := changed
+	stale.Title = "stale"
+	stale.Version++
+	other := todos.Todo{ID: uuid.NewString(), Title: "other", Version: 1}
+	err := r.Apply(ctx, []todos.Revision{
+		{ID: other.ID, Before: nil, After: &other},
+		{ID: kept.ID, Before: &kept, After: &stale},
+	})
+	if !errors.As(err, new(todos.ErrVersionMismatch)) {
+		t.Fatalf("stale revision applied: %v", err)
+	}
+	if _, err := r.FindByID(ctx, other.ID); !isNotFound(err) {
+		t.Fatalf("part of a failed apply was stored: %v", err)
+	}
+}
+
+func testConcurrentWriters(t *testing.T, r todos.Repository) {
+	ctx := context.Background()
+
+	const n = 20
+	var g errgroup.Group
+	for i := 0; i < n; i++ {
+		i := i
+		g.Go(func() error {
+			return r.Add(ctx, todos.Todo{ID: uuid.NewString(), Title: fmt.Sprintf("todo %d", i), Tags: []string{"shared", fmt.Sprint(i)}})
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if found, err := r.FindByTag(ctx, "shared", nil); err != nil || len(found) != n {
+		t.Fatalf("wrong concurrent todo's: %d, %v", len(found), err)
+	}
+
+	// writers of the same version: one wins, the others see the conflict
+	td := add(t, r, todos.Todo{Title: "contended"})
+	results := make(chan error, n)
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			upd := td
+			upd.Title = fmt.Sprintf("writer %d", i)
+			results <- r.Update(ctx, td.ID, upd)
+		}()
+	}
+
+	won := 0
+	for i := 0; i < n; i++ {
+		err := <-results
+		switch {
+		case err == nil:
+			won++
+		case !errors.As(err, new(todos.ErrVersionMismatch)):
+			t.Fatalf("wrong error of a concurrent update: %v", err)
+		}
+	}
+	if won != 1 {
+		t.Fatalf("%d concurrent updates of the same version succeeded", won)
+	}
+	if found := find(t, r, td.ID); found.Version != td.Version+1 {
+		t.Fatalf("wrong version after the concurrent updates: %d", found.Version)
+	}
+}
+
+func testContextCanceled(t *testing.T, r todos.Repository) {
+	td := add(t, r, todos.Todo{Title: "untouched", Tags: []string{"kept"}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := r.FindByID(ctx, td.ID); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wrong error of FindByID: %v", err)
+	}
+	if _, err := r.ListAll(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wrong error of ListAll: %v", err)
+	}
+	if _, err := r.FindPage(ctx, todos.Query{Limit: 1}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("wrong error of FindPage: %v", err)
+	}
+
+	writes := map[string]func() error{
+		"Add": func() error { return r.Add(ctx, todos.Todo{ID: uuid.NewString(), Title: "added"}) },
+		"Update": func() error {
+			upd := td
+			upd.Title = "changed"
+			return r.Update(ctx, td.ID, upd)
+		},
+		"Delete":    func() error { return r.Delete(ctx, td.ID, 0) },
+		"RenameTag": func() error { return r.RenameTag(ctx, "kept", "renamed") },
+		"AddList":   func() error { return r.AddList(ctx, todos.List{ID: uuid.NewString(), Name: "list"}) },
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("wrong error of %s: %v", name, err)
+		}
+	}
+
+	all, err := r.ListAll(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].Title != "untouched" || all[0].Version != td.Version || !slices.Equal(all[0].Tags, []string{"kept"}) {
+		t.Fatalf("canceled writes changed the todo's: %+v", all)
+	}
+	if lists, err := r.ListLists(context.Background(), true); err != nil || len(lists) != 0 {
+		t.Fatalf("canceled write added a list: %+v, %v", lists, err)
+	}
+}
@@ -3,6 +3,7 @@ package todos
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
@@ -16,31 +17,121 @@ func Handler(svc Service) http.Handler {
 	r.Use(middleware.RequestID)
 	r.Use(middleware.RealIP)
 	r.Use(middleware.Logger)
+	r.Use(ActorCtx)
+	r.Use(ClientCtx)
 
 	r.Use(middleware.Timeout(time.Minute))
 
 	r.Get("/health", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("OK")) })
 
-	r.With(middleware.AllowContentType("application/json")).Route("/todos", func(r chi.Router) {
-		r.Get("/", listTodos(svc))
+	r.Post("/undo", undo(svc)) // reverts the last change of the client
+	r.Post("/redo", redo(svc))
+
+	r.With(middleware.AllowContentType("application/json", "application/merge-patch+json", "application/json-patch+json", "multipart/form-data")).Route("/todos", func(r chi.Router) {
+		r.Get("/", listTodos(svc)) // ?filter=tag:work AND completed:false&sort=priority,-due_at,title&limit=50&cursor=...
 		r.Post("/", createTodo(svc))
 
-		//r.Get("/completed", listCompletedTodos(svc))
-		r.Get("/search/tags", searchByTag(svc)) // ?q=tag1,tag2,tag3
+		r.Get("/completed", listCompletedTodos(svc)) // ?after=2023-06-01T00:00:00Z&before=2023-07-01T00:00:00Z
+		r.Get("/open", listOpenTodos(svc))
+		r.Get("/actionable", listActionable(svc)) // open todos without open blockers
+		r.Get("/due", listDue(svc))               // ?after=2023-06-01T00:00:00Z&before=2023-07-01T00:00:00Z
+		r.Get("/overdue", listOverdue(svc))
+		r.Get("/reminders", listReminders(svc))
+		r.Get("/search/tags", searchByTag(svc)) // ?q=tag1,tag2,tag3&mode=any|all&all=tag4,tag5&none=tag6&sort=...&limit=...&cursor=...
+
+		// the todo can be in the trash or removed, so these are outside of TodoCtx
+		r.Post("/{id:[0-9a-z-]+}/undo", undo(svc)) // reverts the last change of the client to the todo
+		r.Post("/{id:[0-9a-z-]+}/redo", redo(svc))
 
 		r.Route("/{id:[0-9a-z-]+}", func(r chi.Router) {
 			r.Use(TodoCtx(svc))
-			r.Get("/", getTodo(svc))
+			r.Get("/", getTodo(svc)) // ?tree=true returns the subtasks at any depth with their progress, ?render=html the todo as HTML
 			r.Put("/", updateTodo(svc))
+			r.Patch("/", patchTodo(svc))
 			r.Delete("/", deleteTodo(svc))
 
-			r.Post("/complete", completeTodo(svc))
+			r.Get("/children", listChildren(svc))  // same parameters as GET /todos
+			r.Post("/complete", completeTodo(svc)) // ?descendants=true also completes the subtasks, ?force=true ignores open blockers
+			r.Post("/reopen", reopenTodo(svc))
+			r.Post("/skip", skipTodo(svc)) // moves a recurring todo to its next occurrence
+			r.Post("/end-series", endSeries(svc))
+			r.Post("/dependencies", addDependency(svc)) // {"id": "..."} of the todo that blocks this one
+			r.Delete("/dependencies/{dep}", removeDependency(svc))
+			r.Get("/graph", dependencyGraph(svc))
+			r.Post("/move", moveTodo(svc)) // {"list_id": "..."}, an empty list_id takes the todo out of its list
+			r.Get("/history", listHistory(svc))
+			r.Get("/comments", listComments(svc))
+			r.Post("/comments", createComment(svc))    // {"body": "..."}, the author is the X-Actor header
+			r.Put("/comments/{cid}", editComment(svc)) // {"body": "..."}, the previous body is kept in the edits
+			r.Delete("/comments/{cid}", deleteComment(svc))
+			r.Get("/attachments", listAttachments(svc))
+			r.Post("/attachments", uploadAttachment(svc)) // multipart/form-data with the file in the `file` part
+			r.Get("/attachments/{aid}", downloadAttachment(svc))
+			r.Delete("/attachments/{aid}", deleteAttachment(svc))
+			r.Post("/checklist/{item}/toggle", toggleChecklistItem(svc))
+			r.Put("/checklist/order", reorderChecklist(svc)) // {"ids": [...]} of all the items in the new order
+		})
+	})
+
+	r.With(middleware.AllowContentType("application/json")).Route("/tags", func(r chi.Router) {
+		r.Get("/", listTags(svc))
+		r.Put("/{name}", renameTag(svc))       // {"name": "new name"}
+		r.Post("/{name}/merge", mergeTag(svc)) // {"into": "other tag"}
+	})
+
+	r.With(middleware.AllowContentType("application/json")).Route("/trash", func(r chi.Router) {
+		r.Get("/", listTrash(svc))
+		r.Post("/{id:[0-9a-z-]+}/restore", restoreTodo(svc))
+		r.Get("/{id:[0-9a-z-]+}/history", listHistory(svc))
+	})
+
+	r.With(middleware.AllowContentType("application/json")).Route("/lists", func(r chi.Router) {
+		r.Get("/", listLists(svc)) // ?archived=true
+		r.Post("/", createList(svc))
+
+		r.Route("/{listID:[0-9a-z-]+}", func(r chi.Router) {
+			r.Use(ListCtx(svc))
+			r.Get("/", getList(svc))
+			r.Put("/", renameList(svc))    // {"name": "new name"}
+			r.Delete("/", deleteList(svc)) // ?cascade=true also deletes the todos, otherwise the list is archived
+			r.Post("/archive", archiveList(svc))
+			r.Get("/todos", listListTodos(svc)) // same parameters as GET /todos
 		})
 	})
 
 	return r
 }
 
+// ActorCtx records the changes of the request in the name of the X-Actor header
+func ActorCtx(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if actor := r.Header.Get("X-Actor"); actor != "" {
+			r = r.WithContext(WithActor(r.Context(), actor))
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// ClientCtx separates the changes to undo by the X-Client-ID header. Without it the client is the actor,
+// or the address of the request.
+func ClientCtx(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := r.Header.Get("X-Client-ID")
+		if client == "" {
+			client = ActorFrom(r.Context())
+		}
+		if client == "" {
+			client = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				client = host
+			}
+		}
+
+		h.ServeHTTP(w, r.WithContext(WithClient(r.Context(), client)))
+	})
+}
+
 type todoCtxKey struct{}
 
 var TodoCtxKey = &todoCtxKey{}
@@ -63,3 +154,26 @@ func TodoCtx(svc Service) func(http.Handler) http.Handler {
 		})
 	}
 }
+
+type listCtxKey struct{}
+
+var ListCtxKey = &listCtxKey{}
+
+func ListCtx(svc Service) func(http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			id := chi.URLParam(r, "listID")
+			l, err := svc.FindList(r.Context(), id)
+			if err != nil {
+				log.Printf("Preparing ListCtx: %v\n", err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), ListCtxKey, &l)
+
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
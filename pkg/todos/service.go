@@ -1,28 +1,89 @@
 package todos
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
-	"golang.org/x/sync/errgroup"
+	"golang.org/x/exp/slices"
 )
 
 type Service interface {
 	FindByID(context.Context, string) (Todo, error)
-	FindByTags(context.Context, []string) ([]Todo, error)
-	ListAll(context.Context) ([]Todo, error)
+	FindByTags(context.Context, []string, Sort) ([]Todo, error)
+	FindDue(ctx context.Context, after, before time.Time) ([]Todo, error)
+	FindOverdue(context.Context) ([]Todo, error)
+	FindReminders(context.Context) ([]Todo, error)
+	ListAll(context.Context, Sort) ([]Todo, error)
+	FindPage(context.Context, Query) (Page, error)
 	Add(context.Context, Todo) (Todo, error)
-	Delete(context.Context, string) error
+	Delete(ctx context.Context, id string, version int64) error
 	Update(context.Context, string, Todo) (Todo, error)
-	MarkCompleted(context.Context, Todo) (Todo, error)
+	ListTrash(context.Context) ([]Todo, error)
+	Restore(context.Context, string) (Todo, error)
+	Purge(ctx context.Context, retention time.Duration) (int, error)
+	Patch(ctx context.Context, id string, version int64, p Patch) (Todo, error)
+	MarkCompleted(ctx context.Context, t Todo, force bool) (Todo, error)
+	CompleteTree(ctx context.Context, t Todo, force bool) (Todo, error)
+	Reopen(context.Context, Todo) (Todo, error)
+	Skip(context.Context, Todo) (Todo, error)
+	EndSeries(context.Context, Todo) (Todo, error)
+	FindCompleted(ctx context.Context, after, before time.Time, q Query) (Page, error)
+	FindOpen(context.Context, Query) (Page, error)
+	ListTags(context.Context) ([]TagCount, error)
+	RenameTag(ctx context.Context, from, to string) (TagCount, error)
+	MergeTag(ctx context.Context, from, into string) (TagCount, error)
+	FindChildren(ctx context.Context, id string, q Query) (Page, error)
+	Tree(context.Context, string) (Tree, error)
+	AddDependency(ctx context.Context, id, blocker string) (Todo, error)
+	RemoveDependency(ctx context.Context, id, blocker string) (Todo, error)
+	FindActionable(context.Context, Query) (Page, error)
+	Graph(context.Context, string) (Graph, error)
+	ListLists(ctx context.Context, archived bool) ([]List, error)
+	FindList(context.Context, string) (List, error)
+	AddList(context.Context, List) (List, error)
+	RenameList(ctx context.Context, id, name string) (List, error)
+	ArchiveList(context.Context, string) (List, error)
+	DeleteList(ctx context.Context, id string, cascade bool) error
+	MoveTodo(ctx context.Context, t Todo, listID string) (Todo, error)
+	History(context.Context, string) ([]Event, error)
+	ListComments(ctx context.Context, todoID string) ([]Comment, error)
+	ListAttachments(ctx context.Context, todoID string) ([]Attachment, error)
+	AddAttachment(ctx context.Context, todoID, name, contentType string, r io.Reader) (Attachment, error)
+	OpenAttachment(ctx context.Context, todoID, id string) (Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx context.Context, todoID, id string) error
+	AddComment(ctx context.Context, todoID, body string) (Comment, error)
+	EditComment(ctx context.Context, todoID, id, body string) (Comment, error)
+	DeleteComment(ctx context.Context, todoID, id string) error
+	ToggleChecklistItem(ctx context.Context, t Todo, itemID string) (Todo, error)
+	ReorderChecklist(ctx context.Context, t Todo, itemIDs []string) (Todo, error)
+	// Undo reverts the last operation of the client, or the last one that changed the todo when the id is not empty
+	Undo(ctx context.Context, id string) (Operation, error)
+	// Redo reverts the last operation undone by the client, or the last one that changed the todo
+	Redo(ctx context.Context, id string) (Operation, error)
 }
 
 type service struct {
-	repo Repository
+	repo     Repository
+	history  HistoryRepository
+	comments CommentRepository
+	undo     *undoStack
+
+	attachments     AttachmentRepository
+	blobs           BlobStore
+	attachmentLimit int64
+	// blobsMu keeps a blob from being deleted while it is attached again
+	blobsMu sync.Mutex
 }
 
 func NewService(opts ...Option) Service {
@@ -41,104 +102,1240 @@ func WithRepo(r Repository) Option {
 	}
 }
 
+// WithUndo keeps the last `depth` operations of every client to be undone
+func WithUndo(depth int) Option {
+	return func(s *service) {
+		if depth > 0 {
+			s.undo = newUndoStack(depth)
+		}
+	}
+}
+
+// WithAttachments keeps the attachments in the repository and their content in the blob store.
+// Attachments cannot be larger than `limit` bytes.
+func WithAttachments(a AttachmentRepository, b BlobStore, limit int64) Option {
+	return func(s *service) {
+		s.attachments = a
+		s.blobs = b
+		s.attachmentLimit = limit
+	}
+}
+
+// WithComments keeps the comments of the todo's in the repository
+func WithComments(c CommentRepository) Option {
+	return func(s *service) {
+		s.comments = c
+	}
+}
+
+// WithHistory records the changes of the todo's in the repository
+func WithHistory(h HistoryRepository) Option {
+	return func(s *service) {
+		s.history = h
+	}
+}
+
 func (s *service) FindByID(ctx context.Context, id string) (Todo, error) {
 	return s.repo.FindByID(ctx, id)
 }
 
-func (s *service) ListAll(ctx context.Context) ([]Todo, error) {
-	return s.repo.ListAll(ctx)
+func (s *service) ListAll(ctx context.Context, srt Sort) ([]Todo, error) {
+	return s.repo.ListAll(ctx, srt)
+}
+
+// FindPage returns one page of the todo's selected by the query
+func (s *service) FindPage(ctx context.Context, q Query) (Page, error) {
+	if q.Limit < 0 {
+		return Page{}, fmt.Errorf("limit cannot be negative")
+	}
+	if q.Limit > MaxPageSize {
+		q.Limit = MaxPageSize
+	}
+	if q.After != nil && !q.After.Matches(q.Sort) {
+		return Page{}, fmt.Errorf("cursor does not match the sort order")
+	}
+
+	return s.repo.FindPage(ctx, q)
 }
 
 func (s *service) Add(ctx context.Context, t Todo) (Todo, error) {
 	t.ID = uuid.NewString()
+	t.Tags = t.UniqueTags()
+	t.SeriesID, t.Occurrence = "", 0
+	startSeries(&t)
+
+	var err error
+	if t.Checklist, err = cleanChecklist(t.Checklist); err != nil {
+		return Todo{}, err
+	}
+
 	if err := s.repo.Add(ctx, t); err != nil {
 		return Todo{}, err
 	}
 
-	return s.FindByID(ctx, t.ID)
+	return s.recorded(ctx, ActionCreate, nil, t.ID)
 }
 
-func (s *service) Delete(ctx context.Context, id string) error {
+// Delete removes the todo. A version other than 0 must match the stored version.
+func (s *service) Delete(ctx context.Context, id string, version int64) error {
 	if _, err := uuid.Parse(id); err != nil {
 		return fmt.Errorf("provided ID is not a UUID")
 	}
 
-	return s.repo.Delete(ctx, id)
+	// the subtasks are deleted too
+	var deleted []Todo
+	if s.tracking() || s.comments != nil {
+		if before, err := s.repo.FindByID(ctx, id); err == nil {
+			descendants, err := s.repo.FindDescendants(ctx, id)
+			if err != nil {
+				return err
+			}
+			deleted = append([]Todo{before}, descendants...)
+		}
+	}
+
+	if err := s.repo.Delete(ctx, id, version); err != nil {
+		return err
+	}
+
+	op := Operation{Action: ActionDelete, TodoID: id}
+	now := time.Now().UTC()
+	ids := make([]string, 0, len(deleted))
+	for i := range deleted {
+		trashed := deleted[i]
+		trashed.DeletedAt = &now
+		s.record(ctx, ActionDelete, trashed.ID, &deleted[i], &trashed)
+		op.add(&deleted[i], &trashed)
+		ids = append(ids, trashed.ID)
+	}
+	s.commit(ctx, op)
+
+	if s.comments != nil && len(ids) > 0 {
+		return s.comments.TrashComments(ctx, ids, now)
+	}
+
+	return nil
+}
+
+// ListTrash returns the deleted todo's, the last deleted first
+func (s *service) ListTrash(ctx context.Context) ([]Todo, error) {
+	return s.repo.ListTrash(ctx)
+}
+
+// Restore takes a deleted todo out of the trash, with the subtasks that were deleted together with it
+func (s *service) Restore(ctx context.Context, id string) (Todo, error) {
+	trash := make(map[string]Todo)
+	if s.tracking() {
+		all, err := s.repo.ListTrash(ctx)
+		if err != nil {
+			return Todo{}, err
+		}
+		for _, t := range all {
+			trash[t.ID] = t
+		}
+	}
+
+	if err := s.repo.Restore(ctx, id); err != nil {
+		return Todo{}, err
+	}
+
+	op := Operation{Action: ActionRestore, TodoID: id}
+	restored, err := s.revise(ctx, &op, ActionRestore, trashed(trash, id), id)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	if s.tracking() || s.comments != nil {
+		descendants, err := s.repo.FindDescendants(ctx, id)
+		if err != nil {
+			return Todo{}, err
+		}
+		ids := []string{id}
+		for i, d := range descendants {
+			ids = append(ids, d.ID)
+			// the subtasks that were not in the trash are not changed
+			if before := trashed(trash, d.ID); before != nil {
+				s.record(ctx, ActionRestore, d.ID, before, &descendants[i])
+				op.add(before, &descendants[i])
+			}
+		}
+		if s.comments != nil {
+			if err := s.comments.RestoreComments(ctx, ids); err != nil {
+				return Todo{}, err
+			}
+		}
+	}
+	s.commit(ctx, op)
+
+	return restored, nil
 }
 
+func trashed(trash map[string]Todo, id string) *Todo {
+	t, ok := trash[id]
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+// Purge empties the trash of the todo's deleted more than `retention` ago
+func (s *service) Purge(ctx context.Context, retention time.Duration) (int, error) {
+	before := time.Now().Add(-retention)
+
+	var purged []string
+	if s.attachments != nil {
+		trash, err := s.repo.ListTrash(ctx)
+		if err != nil {
+			return 0, err
+		}
+		for _, t := range trash {
+			if t.DeletedAt.Before(before) {
+				purged = append(purged, t.ID)
+			}
+		}
+	}
+
+	n, err := s.repo.Purge(ctx, before)
+	if err != nil {
+		return n, err
+	}
+
+	if s.comments != nil {
+		if err := s.comments.PurgeComments(ctx, before); err != nil {
+			return n, err
+		}
+	}
+
+	return n, s.removeAttachments(ctx, purged)
+}
+
+// Update replaces the todo. A version other than 0 must match the stored version.
+// The fields managed by the server keep their stored value.
 func (s *service) Update(ctx context.Context, id string, t Todo) (Todo, error) {
 	if _, err := uuid.Parse(id); err != nil {
 		return Todo{}, fmt.Errorf("provided ID is not a UUID")
 	}
 
+	stored, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	t.ID = id
+	t.SeriesID, t.Occurrence = stored.SeriesID, stored.Occurrence
+	t.DeletedAt = nil
+	t.Tags = t.UniqueTags()
+	startSeries(&t)
+
+	if t.Checklist, err = cleanChecklist(t.Checklist); err != nil {
+		return Todo{}, err
+	}
+
+	var before *Todo
+	if s.tracking() {
+		before = &stored
+	}
 	if err := s.repo.Update(ctx, id, t); err != nil {
 		return Todo{}, err
 	}
 
-	return s.FindByID(ctx, id)
+	return s.recorded(ctx, ActionUpdate, before, id)
+}
+
+// Patch applies the patch to the JSON representation of the todo and saves the result.
+// The ID of the todo cannot be changed. A version other than 0 must match the stored version.
+// The todo is only saved if it was not changed while the patch was applied.
+func (s *service) Patch(ctx context.Context, id string, version int64, p Patch) (Todo, error) {
+	old, err := s.FindByID(ctx, id)
+	if err != nil {
+		return Todo{}, err
+	}
+	if version != 0 && old.Version != version {
+		return Todo{}, ErrVersionMismatch{id}
+	}
+
+	doc, err := json.Marshal(old)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	if doc, err = p.Apply(doc); err != nil {
+		return Todo{}, ErrInvalidPatch{err}
+	}
+
+	var patched Todo
+	if err := json.Unmarshal(doc, &patched); err != nil {
+		return Todo{}, ErrInvalidPatch{err}
+	}
+	patched.Version = old.Version
+
+	return s.Update(ctx, id, patched)
+}
+
+// FindByTags returns all the todo's that contain at least one of the provided tags
+func (s *service) FindByTags(ctx context.Context, tags []string, srt Sort) ([]Todo, error) {
+	page, err := s.repo.FindPage(ctx, Query{Filter: TagFilter(tags, nil, nil), Sort: srt})
+	if err != nil {
+		return nil, err
+	}
+
+	return page.Todos, nil
+}
+
+// FindDue returns the todo's due in [after, before). A zero time leaves that end open.
+func (s *service) FindDue(ctx context.Context, after, before time.Time) ([]Todo, error) {
+	return s.repo.FindDue(ctx, after, before)
+}
+
+// FindOverdue returns the open todo's that are past their due date
+func (s *service) FindOverdue(ctx context.Context) ([]Todo, error) {
+	return s.repo.FindOverdue(ctx, time.Now())
+}
+
+// FindReminders returns the open todo's with a reminder that is due
+func (s *service) FindReminders(ctx context.Context) ([]Todo, error) {
+	return s.repo.FindReminders(ctx, time.Now())
+}
+
+// MarkCompleted completes the todo. Completing an open recurring todo adds the next occurrence of the series.
+// A blocked todo is only completed with force.
+func (s *service) MarkCompleted(ctx context.Context, t Todo, force bool) (Todo, error) {
+	if !force {
+		if err := blocked([]Todo{t}); err != nil {
+			return Todo{}, err
+		}
+	}
+
+	op := Operation{Action: ActionComplete, TodoID: t.ID}
+	completed, err := s.markCompleted(ctx, &op, t)
+	if err != nil {
+		return Todo{}, err
+	}
+	s.commit(ctx, op)
+
+	return completed, nil
+}
+
+func (s *service) markCompleted(ctx context.Context, op *Operation, t Todo) (Todo, error) {
+	wasOpen := t.CompletedAt == nil
+
+	now := time.Now()
+	t.CompletedAt = &now
+
+	before := s.snapshot(ctx, t.ID)
+	if err := s.repo.Update(ctx, t.ID, t); err != nil {
+		return Todo{}, err
+	}
+
+	if wasOpen && t.Recurrence != nil {
+		if next, ok := nextOccurrence(t, now); ok {
+			next.ID = uuid.NewString()
+			next.CompletedAt = nil
+			next.Version = 0
+			if err := s.repo.Add(ctx, next); err != nil {
+				return Todo{}, err
+			}
+			if _, err := s.revise(ctx, op, ActionCreate, nil, next.ID); err != nil {
+				return Todo{}, err
+			}
+		}
+	}
+
+	return s.revise(ctx, op, ActionComplete, before, t.ID)
+}
+
+// Skip moves a recurring todo to its next occurrence without completing it
+func (s *service) Skip(ctx context.Context, t Todo) (Todo, error) {
+	if t.Recurrence == nil {
+		return Todo{}, ErrNotRecurring{t.ID}
+	}
+
+	next, ok := nextOccurrence(t, time.Now())
+	if !ok {
+		return Todo{}, ErrSeriesEnded{t.ID}
+	}
+
+	before := s.snapshot(ctx, t.ID)
+	if err := s.repo.Update(ctx, t.ID, next); err != nil {
+		return Todo{}, err
+	}
+
+	return s.recorded(ctx, ActionUpdate, before, t.ID)
+}
+
+// EndSeries stops the todo from repeating. It stays linked to the earlier occurrences.
+func (s *service) EndSeries(ctx context.Context, t Todo) (Todo, error) {
+	if t.Recurrence == nil {
+		return Todo{}, ErrNotRecurring{t.ID}
+	}
+
+	t.Recurrence = nil
+	before := s.snapshot(ctx, t.ID)
+	if err := s.repo.Update(ctx, t.ID, t); err != nil {
+		return Todo{}, err
+	}
+
+	return s.recorded(ctx, ActionUpdate, before, t.ID)
+}
+
+// startSeries makes a recurring todo the first occurrence of its series, unless it is in a series already
+func startSeries(t *Todo) {
+	if t.Recurrence == nil || t.SeriesID != "" {
+		return
+	}
+
+	t.SeriesID = t.ID
+	if t.Occurrence == 0 {
+		t.Occurrence = 1
+	}
+}
+
+// nextOccurrence returns the todo moved to the next occurrence of its series. The schedule continues
+// from the due date, or from `now` for todo's without one. The reminder keeps its distance to the due date.
+func nextOccurrence(t Todo, now time.Time) (Todo, bool) {
+	from := now
+	if t.DueAt != nil {
+		from = *t.DueAt
+	}
+
+	due, ok := t.Recurrence.Next(from, t.Occurrence)
+	if !ok {
+		return Todo{}, false
+	}
+
+	if t.RemindAt != nil && t.DueAt != nil {
+		remind := due.Add(t.RemindAt.Sub(*t.DueAt))
+		t.RemindAt = &remind
+	} else {
+		t.RemindAt = nil
+	}
+
+	t.DueAt = &due
+	t.Occurrence++
+	if t.SeriesID == "" {
+		t.SeriesID = t.ID
+	}
+
+	return t, true
+}
+
+// CompleteTree completes the open subtasks at any depth and then the todo itself.
+// It stops at the first subtask that was changed since it was read. Without force nothing
+// is completed while one of them waits for an open todo outside of the tree.
+func (s *service) CompleteTree(ctx context.Context, t Todo, force bool) (Todo, error) {
+	descendants, err := s.repo.FindDescendants(ctx, t.ID)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	if !force {
+		tree := []Todo{t}
+		for _, d := range descendants {
+			if d.CompletedAt == nil {
+				tree = append(tree, d)
+			}
+		}
+		if err := blocked(tree); err != nil {
+			return Todo{}, err
+		}
+	}
+
+	// the subtasks completed before a failure can be undone
+	op := Operation{Action: ActionComplete, TodoID: t.ID}
+	defer func() { s.commit(ctx, op) }()
+
+	// recurring subtasks get their next occurrence like any completed todo
+	for _, d := range descendants {
+		if d.CompletedAt != nil {
+			continue
+		}
+		if _, err := s.markCompleted(ctx, &op, d); err != nil {
+			return Todo{}, err
+		}
+	}
+
+	return s.markCompleted(ctx, &op, t)
+}
+
+// blocked returns ErrBlocked for the first todo that waits for an open todo that is not completed with it
+func blocked(completing []Todo) error {
+	ids := make(map[string]bool, len(completing))
+	for _, t := range completing {
+		ids[t.ID] = true
+	}
+
+	for _, t := range completing {
+		var blockers []string
+		for _, b := range t.BlockedBy {
+			if !ids[b] {
+				blockers = append(blockers, b)
+			}
+		}
+		if len(blockers) > 0 {
+			return ErrBlocked{t.ID, blockers}
+		}
+	}
+
+	return nil
+}
+
+// Reopen clears the completion of a todo
+func (s *service) Reopen(ctx context.Context, t Todo) (Todo, error) {
+	if t.CompletedAt == nil {
+		return t, nil
+	}
+
+	t.CompletedAt = nil
+
+	before := s.snapshot(ctx, t.ID)
+	if err := s.repo.Update(ctx, t.ID, t); err != nil {
+		return Todo{}, err
+	}
+
+	return s.recorded(ctx, ActionReopen, before, t.ID)
 }
 
-// FindByTags returns all the todo's that contain at least one of the provided flags.
-// Launches a separate go routine for each tag and then collects the unique results.
-func (s *service) FindByTags(ctx context.Context, tags []string) ([]Todo, error) {
+// FindCompleted returns a page of the todo's completed in [after, before), narrowed down by the query.
+// A zero time leaves that end open.
+func (s *service) FindCompleted(ctx context.Context, after, before time.Time, q Query) (Page, error) {
+	completed := Expr(CompletedCond{true})
+	if !after.IsZero() {
+		completed = joinExpr(completed, TimeCond{Field: SortByCompletedAt, Op: ">=", Time: after})
+	}
+	if !before.IsZero() {
+		completed = joinExpr(completed, TimeCond{Field: SortByCompletedAt, Op: "<", Time: before})
+	}
+
+	q.Filter = joinExpr(completed, q.Filter)
+
+	return s.FindPage(ctx, q)
+}
 
-	ch := make(chan Todo)
-	errs := make(chan error, 1)
-	unique := make(map[string]Todo)
+// FindOpen returns a page of the todo's that are not completed, narrowed down by the query
+func (s *service) FindOpen(ctx context.Context, q Query) (Page, error) {
+	q.Filter = joinExpr(CompletedCond{false}, q.Filter)
 
-	g, gCtx := errgroup.WithContext(ctx)
+	return s.FindPage(ctx, q)
+}
+
+func (s *service) ListTags(ctx context.Context) ([]TagCount, error) {
+	return s.repo.ListTags(ctx)
+}
+
+// RenameTag renames the tag on all todo's and returns the renamed tag
+func (s *service) RenameTag(ctx context.Context, from, to string) (TagCount, error) {
+	from, to = cleanTag(from), cleanTag(to)
+	if from == "" || to == "" {
+		return TagCount{}, fmt.Errorf("tag names cannot be empty")
+	}
+
+	tagged, err := s.tagged(ctx, from)
+	if err != nil {
+		return TagCount{}, err
+	}
+	if err := s.repo.RenameTag(ctx, from, to); err != nil {
+		return TagCount{}, err
+	}
+	s.retagged(ctx, tagged)
 
-	for _, t := range tags {
-		t = strings.ToLower(strings.TrimSpace(t))
-		// https://golang.org/doc/faq#closures_and_goroutines
-		g.Go(func(t string) func() error {
-			return func() error {
-				tds, err := s.repo.FindByTag(ctx, t)
+	return s.findTag(ctx, to)
+}
+
+// MergeTag folds the tag `from` into `into` and returns the merged tag
+func (s *service) MergeTag(ctx context.Context, from, into string) (TagCount, error) {
+	from, into = cleanTag(from), cleanTag(into)
+	if from == "" || into == "" {
+		return TagCount{}, fmt.Errorf("tag names cannot be empty")
+	}
+
+	tagged, err := s.tagged(ctx, from)
+	if err != nil {
+		return TagCount{}, err
+	}
+	if err := s.repo.MergeTag(ctx, from, into); err != nil {
+		return TagCount{}, err
+	}
+	s.retagged(ctx, tagged)
+
+	return s.findTag(ctx, into)
+}
+
+// tagged reads the todo's with the tag before it is changed, when the changes are recorded
+func (s *service) tagged(ctx context.Context, tag string) ([]Todo, error) {
+	if !s.tracking() {
+		return nil, nil
+	}
+
+	return s.repo.FindByTag(ctx, tag, nil)
+}
+
+// retagged records the new tags of every todo that had the changed tag, as one operation to undo
+func (s *service) retagged(ctx context.Context, tagged []Todo) {
+	op := Operation{Action: ActionUpdate}
+	for i := range tagged {
+		before := &tagged[i]
+		after, err := s.repo.FindByID(ctx, before.ID)
+		if err != nil {
+			log.Printf("Recording the tags of %s: %v\n", before.ID, err)
+			continue
+		}
+		// merging a tag into itself changes nothing
+		if after.Version == before.Version {
+			continue
+		}
+
+		s.record(ctx, ActionUpdate, before.ID, before, &after)
+		if s.undo != nil {
+			op.add(before, &after)
+		}
+	}
+	s.commit(ctx, op)
+}
+
+func (s *service) findTag(ctx context.Context, name string) (TagCount, error) {
+	all, err := s.repo.ListTags(ctx)
+	if err != nil {
+		return TagCount{}, err
+	}
+
+	for _, c := range all {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+
+	return TagCount{}, ErrTagNotFound{name}
+}
+
+// FindChildren returns a page of the direct subtasks of the todo, narrowed down by the query
+func (s *service) FindChildren(ctx context.Context, id string, q Query) (Page, error) {
+	q.Filter = joinExpr(ParentCond{id}, q.Filter)
+
+	return s.FindPage(ctx, q)
+}
+
+// Tree returns the todo with its subtasks at any depth and their progress
+func (s *service) Tree(ctx context.Context, id string) (Tree, error) {
+	root, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Tree{}, err
+	}
+
+	descendants, err := s.repo.FindDescendants(ctx, id)
+	if err != nil {
+		return Tree{}, err
+	}
+
+	return buildTree(root, descendants), nil
+}
+
+// AddDependency makes the todo wait for the blocker and returns the todo
+func (s *service) AddDependency(ctx context.Context, id, blocker string) (Todo, error) {
+	if err := s.repo.AddDependency(ctx, Dependency{TodoID: id, BlockerID: blocker}); err != nil {
+		return Todo{}, err
+	}
+
+	return s.repo.FindByID(ctx, id)
+}
+
+// RemoveDependency stops the todo from waiting for the blocker and returns the todo
+func (s *service) RemoveDependency(ctx context.Context, id, blocker string) (Todo, error) {
+	if err := s.repo.RemoveDependency(ctx, Dependency{TodoID: id, BlockerID: blocker}); err != nil {
+		return Todo{}, err
+	}
+
+	return s.repo.FindByID(ctx, id)
+}
+
+// FindActionable returns a page of the open todo's that do not wait for other open todo's
+func (s *service) FindActionable(ctx context.Context, q Query) (Page, error) {
+	q.Filter = joinExpr(AndExpr{CompletedCond{false}, BlockedCond{false}}, q.Filter)
+
+	return s.FindPage(ctx, q)
+}
+
+// Graph returns the todo's connected to the todo by dependencies, in both directions,
+// and the order in which they can be completed. It fails if the dependencies contain a cycle.
+func (s *service) Graph(ctx context.Context, id string) (Graph, error) {
+	root, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Graph{}, err
+	}
+
+	g := Graph{Dependencies: []Dependency{}}
+	seen := map[string]bool{id: true}
+	edges := make(map[Dependency]bool)
+
+	for next := []string{id}; len(next) > 0; {
+		deps, err := s.repo.FindDependencies(ctx, next)
+		if err != nil {
+			return Graph{}, err
+		}
+
+		next = nil
+		for _, d := range deps {
+			if !edges[d] {
+				edges[d] = true
+				g.Dependencies = append(g.Dependencies, d)
+			}
+			for _, n := range []string{d.TodoID, d.BlockerID} {
+				if !seen[n] {
+					seen[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+	}
+
+	ids := make([]string, 0, len(seen))
+	for n := range seen {
+		ids = append(ids, n)
+	}
+	slices.Sort(ids)
+
+	for _, n := range ids {
+		td := root
+		if n != id {
+			if td, err = s.repo.FindByID(ctx, n); err != nil {
+				return Graph{}, err
+			}
+		}
+		g.Todos = append(g.Todos, td)
+	}
+	sortDependencies(g.Dependencies)
+
+	if g.Order, err = completionOrder(ids, g.Dependencies); err != nil {
+		return Graph{}, err
+	}
+
+	return g, nil
+}
+
+// ListLists returns the lists sorted by name, the archived lists only when archived is true
+func (s *service) ListLists(ctx context.Context, archived bool) ([]List, error) {
+	return s.repo.ListLists(ctx, archived)
+}
+
+func (s *service) FindList(ctx context.Context, id string) (List, error) {
+	return s.repo.FindList(ctx, id)
+}
+
+func (s *service) AddList(ctx context.Context, l List) (List, error) {
+	l.ID = uuid.NewString()
+	l.Name = strings.TrimSpace(l.Name)
+	l.ArchivedAt = nil
+	if l.Name == "" {
+		return List{}, fmt.Errorf("list name cannot be empty")
+	}
+
+	if err := s.repo.AddList(ctx, l); err != nil {
+		return List{}, err
+	}
+
+	return s.repo.FindList(ctx, l.ID)
+}
+
+func (s *service) RenameList(ctx context.Context, id, name string) (List, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return List{}, fmt.Errorf("list name cannot be empty")
+	}
+
+	l, err := s.repo.FindList(ctx, id)
+	if err != nil {
+		return List{}, err
+	}
+
+	l.Name = name
+	if err := s.repo.UpdateList(ctx, l); err != nil {
+		return List{}, err
+	}
+
+	return s.repo.FindList(ctx, id)
+}
+
+// ArchiveList hides the list from the default listing. Its todo's are kept.
+func (s *service) ArchiveList(ctx context.Context, id string) (List, error) {
+	l, err := s.repo.FindList(ctx, id)
+	if err != nil {
+		return List{}, err
+	}
+	if l.ArchivedAt != nil {
+		return l, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	l.ArchivedAt = &now
+	if err := s.repo.UpdateList(ctx, l); err != nil {
+		return List{}, err
+	}
+
+	return s.repo.FindList(ctx, id)
+}
+
+// DeleteList removes the list together with its todo's when cascade is true, otherwise the list is archived
+func (s *service) DeleteList(ctx context.Context, id string, cascade bool) error {
+	if cascade {
+		var removed []string
+		if s.attachments != nil {
+			page, err := s.repo.FindPage(ctx, Query{Filter: ListCond{id}})
+			if err != nil {
+				return err
+			}
+			for _, t := range page.Todos {
+				descendants, err := s.repo.FindDescendants(ctx, t.ID)
 				if err != nil {
 					return err
 				}
-				for _, td := range tds {
-					select {
-					case <-gCtx.Done():
-						return gCtx.Err()
-					case ch <- td:
-					}
+				removed = append(removed, t.ID)
+				for _, d := range descendants {
+					removed = append(removed, d.ID)
 				}
-
-				return nil
 			}
-		}(t))
+		}
+
+		if err := s.repo.DeleteList(ctx, id); err != nil {
+			return err
+		}
+
+		return s.removeAttachments(ctx, removed)
 	}
 
-	go func() {
-		defer close(ch)
-		if err := g.Wait(); err != nil {
-			log.Printf("Finding by tags: %v", err)
-			errs <- err
+	_, err := s.ArchiveList(ctx, id)
+	return err
+}
+
+// MoveTodo puts the todo in another list. An empty listID takes it out of its list.
+// Todo's cannot be moved into an archived list.
+func (s *service) MoveTodo(ctx context.Context, t Todo, listID string) (Todo, error) {
+	if listID != "" {
+		l, err := s.repo.FindList(ctx, listID)
+		if err != nil {
+			return Todo{}, err
 		}
-		close(errs)
-	}()
+		if l.ArchivedAt != nil {
+			return Todo{}, fmt.Errorf("cannot move a todo into an archived list")
+		}
+	}
 
-	for t := range ch {
-		unique[t.ID] = t
+	t.ListID = listID
+	before := s.snapshot(ctx, t.ID)
+	if err := s.repo.Update(ctx, t.ID, t); err != nil {
+		return Todo{}, err
 	}
 
-	var all []Todo
-	for _, v := range unique {
-		all = append(all, v)
+	return s.recorded(ctx, ActionUpdate, before, t.ID)
+}
+
+// ToggleChecklistItem marks the item of the checklist done, or not done anymore.
+// The todo is only changed if it is still the version that was read.
+func (s *service) ToggleChecklistItem(ctx context.Context, t Todo, itemID string) (Todo, error) {
+	items, err := toggleItem(t.Checklist, itemID)
+	if err != nil {
+		return Todo{}, err
 	}
 
-	return all, <-errs
+	t.Checklist = items
+	return s.Update(ctx, t.ID, t)
 }
 
-func (s *service) MarkCompleted(ctx context.Context, t Todo) (Todo, error) {
+// ReorderChecklist puts the items of the checklist in the order of the IDs, which must name every item once.
+// The todo is only changed if it is still the version that was read.
+func (s *service) ReorderChecklist(ctx context.Context, t Todo, itemIDs []string) (Todo, error) {
+	items, err := reorderItems(t.Checklist, itemIDs)
+	if err != nil {
+		return Todo{}, err
+	}
 
-	now := time.Now()
-	t.CompletedAt = &now
+	t.Checklist = items
+	return s.Update(ctx, t.ID, t)
+}
 
-	if err := s.repo.Update(ctx, t.ID, t); err != nil {
+// History returns the changes of the todo, the oldest first. It is empty when no history is kept
+// or nothing was recorded, and ErrNotFound when there is no such todo, also not in the trash.
+// The history of a purged todo is kept.
+func (s *service) History(ctx context.Context, id string) ([]Event, error) {
+	if s.history != nil {
+		events, err := s.history.FindEvents(ctx, id)
+		if err != nil || len(events) > 0 {
+			return events, err
+		}
+	}
+
+	if _, err := s.repo.FindByID(ctx, id); err == nil {
+		return []Event{}, nil
+	} else if !errors.As(err, new(ErrNotFound)) {
+		return nil, err
+	}
+
+	trash, err := s.repo.ListTrash(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range trash {
+		if t.ID == id {
+			return []Event{}, nil
+		}
+	}
+
+	return nil, ErrNotFound{id}
+}
+
+// Undo reverts the last operation of the client, or the last one that changed the todo when the id is not empty
+func (s *service) Undo(ctx context.Context, id string) (Operation, error) {
+	return s.revert(ctx, id, false)
+}
+
+// Redo reverts the last operation undone by the client, or the last one that changed the todo
+func (s *service) Redo(ctx context.Context, id string) (Operation, error) {
+	return s.revert(ctx, id, true)
+}
+
+// revert applies the inverse of an operation taken from the undo or redo stack, and puts the inverse
+// on the other stack. An operation that conflicts with a later change cannot be reverted anymore and is dropped.
+func (s *service) revert(ctx context.Context, id string, redo bool) (Operation, error) {
+	if s.undo == nil {
+		return Operation{}, ErrNothingToUndo{redo}
+	}
+
+	client := ClientFrom(ctx)
+	op, ok := s.undo.take(redo, client, id)
+	if !ok {
+		return Operation{}, ErrNothingToUndo{redo}
+	}
+
+	inv := op.inverse()
+	if err := s.repo.Apply(ctx, inv.Revisions); err != nil {
+		if !errors.As(err, new(ErrVersionMismatch)) {
+			s.undo.put(redo, client, op)
+		}
+		return Operation{}, err
+	}
+
+	for _, rev := range inv.Revisions {
+		if err := s.reviseComments(ctx, rev); err != nil {
+			log.Printf("Reverting the comments of %s: %v\n", rev.ID, err)
+		}
+		if rev.After == nil {
+			if err := s.removeAttachments(ctx, []string{rev.ID}); err != nil {
+				log.Printf("Removing the attachments of %s: %v\n", rev.ID, err)
+			}
+		}
+		s.record(ctx, rev.action(), rev.ID, rev.Before, rev.After)
+		if rev.Before != nil && rev.After != nil {
+			s.undo.renumber(client, *op.revision(rev.ID).Before, rev.After.Version)
+		}
+	}
+	s.undo.put(!redo, client, inv)
+
+	return inv, nil
+}
+
+// tracking reports whether the changes are recorded, to be listed or undone
+func (s *service) tracking() bool {
+	return s.history != nil || s.undo != nil
+}
+
+// snapshot reads the todo before it is changed, when the changes are recorded
+func (s *service) snapshot(ctx context.Context, id string) *Todo {
+	if !s.tracking() {
+		return nil
+	}
+
+	t, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
+
+// recorded reads the todo changed by a single step and records the change
+func (s *service) recorded(ctx context.Context, action Action, before *Todo, id string) (Todo, error) {
+	op := Operation{Action: action, TodoID: id}
+	after, err := s.revise(ctx, &op, action, before, id)
+	if err != nil {
 		return Todo{}, err
 	}
+	s.commit(ctx, op)
+
+	return after, nil
+}
+
+// revise reads the changed todo, records the change in its history and adds it to the operation
+func (s *service) revise(ctx context.Context, op *Operation, action Action, before *Todo, id string) (Todo, error) {
+	after, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return Todo{}, err
+	}
+
+	s.record(ctx, action, id, before, &after)
+	if s.undo != nil {
+		op.add(before, &after)
+	}
+
+	return after, nil
+}
+
+// commit makes the operation the last one of the client to be undone
+func (s *service) commit(ctx context.Context, op Operation) {
+	if s.undo == nil || len(op.Revisions) == 0 {
+		return
+	}
+
+	s.undo.push(ClientFrom(ctx), op)
+}
+
+// record adds the change to the history of the todo. The change is saved already,
+// so a failure to record it is only logged.
+func (s *service) record(ctx context.Context, action Action, id string, before, after *Todo) {
+	if s.history == nil {
+		return
+	}
+
+	changes, err := diff(before, after)
+	if err == nil {
+		err = s.history.AddEvent(ctx, Event{
+			TodoID:  id,
+			Action:  action,
+			Actor:   ActorFrom(ctx),
+			At:      time.Now().UTC(),
+			Changes: changes,
+		})
+	}
+	if err != nil {
+		log.Printf("Recording %s of %s: %v\n", action, id, err)
+	}
+}
+
+// ListComments returns the comments of the todo, the oldest first
+func (s *service) ListComments(ctx context.Context, todoID string) ([]Comment, error) {
+	if s.comments == nil {
+		return []Comment{}, nil
+	}
+
+	return s.comments.FindComments(ctx, todoID)
+}
+
+// AddComment adds a comment to the todo in the name of the actor of the context
+func (s *service) AddComment(ctx context.Context, todoID, body string) (Comment, error) {
+	if s.comments == nil {
+		return Comment{}, fmt.Errorf("comments are not supported")
+	}
+	if body = strings.TrimSpace(body); body == "" {
+		return Comment{}, ErrEmptyComment{}
+	}
+
+	cm := Comment{
+		ID:        uuid.NewString(),
+		TodoID:    todoID,
+		Author:    ActorFrom(ctx),
+		Body:      body,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.comments.AddComment(ctx, cm); err != nil {
+		return Comment{}, err
+	}
+
+	return s.comments.FindComment(ctx, cm.ID)
+}
+
+// EditComment replaces the body of a comment of the todo. The previous body is kept in the edits.
+func (s *service) EditComment(ctx context.Context, todoID, id, body string) (Comment, error) {
+	if _, err := s.findComment(ctx, todoID, id); err != nil {
+		return Comment{}, err
+	}
+	if body = strings.TrimSpace(body); body == "" {
+		return Comment{}, ErrEmptyComment{}
+	}
+
+	if err := s.comments.UpdateComment(ctx, id, body, time.Now().UTC()); err != nil {
+		return Comment{}, err
+	}
+
+	return s.comments.FindComment(ctx, id)
+}
+
+// DeleteComment removes a comment of the todo
+func (s *service) DeleteComment(ctx context.Context, todoID, id string) error {
+	if _, err := s.findComment(ctx, todoID, id); err != nil {
+		return err
+	}
+
+	return s.comments.DeleteComment(ctx, id)
+}
+
+// findComment returns the comment if it belongs to the todo
+func (s *service) findComment(ctx context.Context, todoID, id string) (Comment, error) {
+	if s.comments == nil {
+		return Comment{}, ErrCommentNotFound{id}
+	}
+
+	cm, err := s.comments.FindComment(ctx, id)
+	if err != nil {
+		return Comment{}, err
+	}
+	if cm.TodoID != todoID {
+		return Comment{}, ErrCommentNotFound{id}
+	}
+
+	return cm, nil
+}
+
+// reviseComments trashes or restores the comments of a todo that an undo moved to or out of the trash
+func (s *service) reviseComments(ctx context.Context, rev Revision) error {
+	if s.comments == nil {
+		return nil
+	}
+
+	switch rev.action() {
+	case ActionDelete:
+		return s.comments.TrashComments(ctx, []string{rev.ID}, time.Now().UTC())
+	case ActionRestore:
+		return s.comments.RestoreComments(ctx, []string{rev.ID})
+	}
+
+	return nil
+}
+
+// ListAttachments returns the attachments of the todo, the oldest first
+func (s *service) ListAttachments(ctx context.Context, todoID string) ([]Attachment, error) {
+	if s.attachments == nil {
+		return []Attachment{}, nil
+	}
+
+	return s.attachments.FindAttachments(ctx, todoID)
+}
+
+// AddAttachment attaches the file to the todo. The content type is sniffed from the content, the declared
+// type is only used for content that cannot be recognized. The same content is stored once.
+func (s *service) AddAttachment(ctx context.Context, todoID, name, contentType string, r io.Reader) (Attachment, error) {
+	if s.attachments == nil {
+		return Attachment{}, fmt.Errorf("attachments are not supported")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, s.attachmentLimit+1))
+	if err != nil {
+		return Attachment{}, err
+	}
+	if int64(len(data)) > s.attachmentLimit {
+		return Attachment{}, ErrAttachmentTooLarge{s.attachmentLimit}
+	}
+
+	sum := sha256.Sum256(data)
+	at := Attachment{
+		ID:          uuid.NewString(),
+		TodoID:      todoID,
+		Name:        attachmentName(name),
+		ContentType: sniffContentType(data, contentType),
+		Size:        int64(len(data)),
+		SHA256:      hex.EncodeToString(sum[:]),
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	s.blobsMu.Lock()
+	defer s.blobsMu.Unlock()
+
+	exists, err := s.blobs.Exists(ctx, at.SHA256)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if !exists {
+		if err := s.blobs.Put(ctx, at.SHA256, bytes.NewReader(data)); err != nil {
+			return Attachment{}, err
+		}
+	}
+
+	if err := s.attachments.AddAttachment(ctx, at); err != nil {
+		return Attachment{}, err
+	}
+
+	return at, nil
+}
+
+// OpenAttachment returns the attachment of the todo with its content, which must be closed
+func (s *service) OpenAttachment(ctx context.Context, todoID, id string) (Attachment, io.ReadCloser, error) {
+	at, err := s.findAttachment(ctx, todoID, id)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	content, err := s.blobs.Get(ctx, at.SHA256)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	return at, content, nil
+}
+
+// DeleteAttachment removes the attachment of the todo. The content is removed when no attachment uses it anymore.
+func (s *service) DeleteAttachment(ctx context.Context, todoID, id string) error {
+	at, err := s.findAttachment(ctx, todoID, id)
+	if err != nil {
+		return err
+	}
+
+	s.blobsMu.Lock()
+	defer s.blobsMu.Unlock()
+
+	if err := s.attachments.DeleteAttachment(ctx, id); err != nil {
+		return err
+	}
+
+	return s.releaseBlob(ctx, at.SHA256)
+}
+
+// findAttachment returns the attachment if it belongs to the todo
+func (s *service) findAttachment(ctx context.Context, todoID, id string) (Attachment, error) {
+	if s.attachments == nil {
+		return Attachment{}, ErrAttachmentNotFound{id}
+	}
+
+	at, err := s.attachments.FindAttachment(ctx, id)
+	if err != nil {
+		return Attachment{}, err
+	}
+	if at.TodoID != todoID {
+		return Attachment{}, ErrAttachmentNotFound{id}
+	}
+
+	return at, nil
+}
+
+// removeAttachments removes the attachments of the todo's that were removed for good, with their content
+func (s *service) removeAttachments(ctx context.Context, todoIDs []string) error {
+	if s.attachments == nil || len(todoIDs) == 0 {
+		return nil
+	}
+
+	s.blobsMu.Lock()
+	defer s.blobsMu.Unlock()
+
+	removed, err := s.attachments.DeleteAttachments(ctx, todoIDs)
+	if err != nil {
+		return err
+	}
+
+	released := make(map[string]bool)
+	for _, at := range removed {
+		if released[at.SHA256] {
+			continue
+		}
+		released[at.SHA256] = true
+		if err := s.releaseBlob(ctx, at.SHA256); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseBlob deletes the content when no attachment uses it anymore. blobsMu must be held.
+func (s *service) releaseBlob(ctx context.Context, sum string) error {
+	n, err := s.attachments.CountBySHA256(ctx, sum)
+	if err != nil || n > 0 {
+		return err
+	}
 
-	return s.repo.FindByID(ctx, t.ID)
+	return s.blobs.Delete(ctx, sum)
 }
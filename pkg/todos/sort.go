@@ -0,0 +1,139 @@
+package todos
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SortField is a todo field that lists can be ordered by
+type SortField string
+
+const (
+	SortByID          SortField = "id"
+	SortByTitle       SortField = "title"
+	SortByPriority    SortField = "priority"
+	SortByDueAt       SortField = "due_at"
+	SortByCompletedAt SortField = "completed_at"
+)
+
+type SortKey struct {
+	Field SortField
+	Desc  bool
+}
+
+// Sort is an ordered list of sort keys. Todo's that are equal on all keys are ordered by ID,
+// so every repository returns the same order for the same data.
+// Titles are compared case insensitive by their bytes; the databases may lower the case of letters outside
+// of ASCII differently. Missing dates sort after all the others, also in descending order.
+type Sort []SortKey
+
+// ParseSort parses a comma separated list of fields, like `priority,-due_at,title`.
+// A leading `-` sorts that field in descending order.
+func ParseSort(s string) (Sort, error) {
+	var srt Sort
+
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+
+		key := SortKey{Field: SortField(strings.TrimPrefix(f, "-")), Desc: strings.HasPrefix(f, "-")}
+		switch key.Field {
+		case SortByID, SortByTitle, SortByPriority, SortByDueAt, SortByCompletedAt:
+		default:
+			return nil, fmt.Errorf("cannot sort by: %q", key.Field)
+		}
+
+		srt = append(srt, key)
+	}
+
+	return srt, nil
+}
+
+func (s Sort) String() string {
+	fields := make([]string, len(s))
+	for i, k := range s {
+		fields[i] = string(k.Field)
+		if k.Desc {
+			fields[i] = "-" + fields[i]
+		}
+	}
+
+	return strings.Join(fields, ",")
+}
+
+// Less reports whether a is ordered before b
+func (s Sort) Less(a, b Todo) bool {
+	return s.Compare(a, b) < 0
+}
+
+// Compare returns -1 if a is ordered before b, 1 if it is ordered after b and 0 if they are the same todo
+func (s Sort) Compare(a, b Todo) int {
+	for _, k := range s {
+		if c := compareMissing(k.Field, a, b); c != 0 {
+			return c
+		}
+		c := compareField(k.Field, a, b)
+		if k.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+
+	return strings.Compare(a.ID, b.ID)
+}
+
+func compareField(f SortField, a, b Todo) int {
+	switch f {
+	case SortByID:
+		return strings.Compare(a.ID, b.ID)
+	case SortByTitle:
+		return strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title))
+	case SortByPriority:
+		return int(a.Priority) - int(b.Priority)
+	case SortByDueAt:
+		return compareTimes(a.DueAt, b.DueAt)
+	case SortByCompletedAt:
+		return compareTimes(a.CompletedAt, b.CompletedAt)
+	}
+
+	return 0
+}
+
+// compareMissing orders the todo's without the date after the others, whatever the direction
+func compareMissing(f SortField, a, b Todo) int {
+	var x, y *time.Time
+	switch f {
+	case SortByDueAt:
+		x, y = a.DueAt, b.DueAt
+	case SortByCompletedAt:
+		x, y = a.CompletedAt, b.CompletedAt
+	}
+
+	switch {
+	case (x == nil) == (y == nil):
+		return 0
+	case x == nil:
+		return 1
+	}
+
+	return -1
+}
+
+// compareTimes orders nil after all the other values
+func compareTimes(a, b *time.Time) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return 1
+	case b == nil:
+		return -1
+	}
+
+	return a.Compare(*b)
+}
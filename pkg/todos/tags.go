@@ -0,0 +1,14 @@
+package todos
+
+import "strings"
+
+// TagCount is a tag with the number of todo's that use it
+type TagCount struct {
+	Name      string `json:"name"`
+	Open      int    `json:"open"`
+	Completed int    `json:"completed"`
+}
+
+func cleanTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
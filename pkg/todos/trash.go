@@ -0,0 +1,29 @@
+package todos
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeTrash empties the trash every `interval` of the todo's deleted more than `retention` ago,
+// until the context is done
+func PurgeTrash(ctx context.Context, svc Service, retention, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		n, err := svc.Purge(ctx, retention)
+		if err != nil {
+			log.Printf("Purging the trash: %v\n", err)
+		} else if n > 0 {
+			log.Printf("Purged %d todos from the trash\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
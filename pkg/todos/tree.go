@@ -0,0 +1,68 @@
+package todos
+
+import "golang.org/x/exp/slices"
+
+// Tree is a todo with its subtasks
+type Tree struct {
+	Todo
+	// Progress is only set on todo's that have subtasks
+	Progress *Progress `json:"progress,omitempty"`
+	Children []Tree    `json:"children"`
+}
+
+// Progress counts the completed subtasks. Only the subtasks without subtasks of their own are counted,
+// so every step has the same weight whatever its depth.
+type Progress struct {
+	Completed int `json:"completed"`
+	Total     int `json:"total"`
+	// Percent is rounded down
+	Percent int `json:"percent"`
+}
+
+// buildTree nests the descendants under the root and computes the progress of every parent
+func buildTree(root Todo, descendants []Todo) Tree {
+	children := make(map[string][]Todo)
+	for _, td := range descendants {
+		children[td.ParentID] = append(children[td.ParentID], td)
+	}
+
+	var build func(Todo) Tree
+	build = func(t Todo) Tree {
+		node := Tree{Todo: t, Children: make([]Tree, 0, len(children[t.ID]))}
+
+		kids := children[t.ID]
+		slices.SortFunc(kids, func(a, b Todo) bool { return a.ID < b.ID })
+
+		for _, c := range kids {
+			node.Children = append(node.Children, build(c))
+		}
+		node.Progress = progressOf(node.Children)
+
+		return node
+	}
+
+	return build(root)
+}
+
+func progressOf(children []Tree) *Progress {
+	if len(children) == 0 {
+		return nil
+	}
+
+	var p Progress
+	for _, c := range children {
+		switch {
+		case c.Progress != nil:
+			p.Completed += c.Progress.Completed
+			p.Total += c.Progress.Total
+		case c.CompletedAt != nil:
+			p.Completed++
+			p.Total++
+		default:
+			p.Total++
+		}
+	}
+	p.Percent = p.Completed * 100 / p.Total
+
+	return &p
+}
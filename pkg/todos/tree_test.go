@@ -0,0 +1,33 @@
+package todos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildTreeProgress(t *testing.T) {
+	now := time.Now()
+
+	root := Todo{ID: "1"}
+	tree := buildTree(root, []Todo{
+		{ID: "2", ParentID: "1", CompletedAt: &now},
+		{ID: "3", ParentID: "1"},
+		{ID: "4", ParentID: "3", CompletedAt: &now},
+		{ID: "5", ParentID: "3"},
+		{ID: "6", ParentID: "3"},
+	})
+
+	if len(tree.Children) != 2 || len(tree.Children[1].Children) != 3 {
+		t.Fatalf("wrong tree: %+v", tree)
+	}
+
+	// 2 and 4 are done out of the steps 2, 4, 5 and 6
+	expected := Progress{Completed: 2, Total: 4, Percent: 50}
+	if tree.Progress == nil || *tree.Progress != expected {
+		t.Fatalf("wrong progress. expected: %+v, got: %+v", expected, tree.Progress)
+	}
+
+	if tree.Children[0].Progress != nil {
+		t.Fatalf("a todo without subtasks has no progress: %+v", tree.Children[0].Progress)
+	}
+}
@@ -0,0 +1,194 @@
+package todos
+
+import (
+	"context"
+	"sync"
+)
+
+// Revision is the change of one todo by an operation. Before is nil for a todo that was added,
+// After is nil for a todo that was removed for good. The todo's in the trash have DeletedAt set.
+type Revision struct {
+	ID     string `json:"id"`
+	Before *Todo  `json:"before"`
+	After  *Todo  `json:"after"`
+}
+
+// action returns the kind of change of the revision, as it is recorded in the history
+func (r Revision) action() Action {
+	switch {
+	case r.Before == nil:
+		return ActionCreate
+	case r.After == nil, r.Before.DeletedAt == nil && r.After.DeletedAt != nil:
+		return ActionDelete
+	case r.Before.DeletedAt != nil && r.After.DeletedAt == nil:
+		return ActionRestore
+	case r.Before.CompletedAt == nil && r.After.CompletedAt != nil:
+		return ActionComplete
+	case r.Before.CompletedAt != nil && r.After.CompletedAt == nil:
+		return ActionReopen
+	default:
+		return ActionUpdate
+	}
+}
+
+// Operation is a call to the service that changed todo's. It is undone as a whole.
+type Operation struct {
+	Action    Action     `json:"action"`
+	TodoID    string     `json:"todo_id"`
+	Revisions []Revision `json:"revisions"`
+}
+
+func (o *Operation) add(before, after *Todo) {
+	id := ""
+	if before != nil {
+		id = before.ID
+	} else if after != nil {
+		id = after.ID
+	}
+
+	o.Revisions = append(o.Revisions, Revision{ID: id, Before: before, After: after})
+}
+
+// inverse returns the operation that reverts o, the last revision first.
+// A todo that is changed back gets the version that follows its version after o.
+func (o Operation) inverse() Operation {
+	inv := Operation{Action: o.Action, TodoID: o.TodoID}
+	for i := len(o.Revisions) - 1; i >= 0; i-- {
+		rev := o.Revisions[i]
+
+		var after *Todo
+		if rev.Before != nil {
+			t := *rev.Before
+			if rev.After != nil {
+				t.Version = rev.After.Version + 1
+			}
+			after = &t
+		}
+
+		inv.Revisions = append(inv.Revisions, Revision{ID: rev.ID, Before: rev.After, After: after})
+	}
+
+	return inv
+}
+
+// undoStack keeps the last operations of every client, to be undone and redone
+type undoStack struct {
+	depth int
+	// undo and redo hold the operations of the clients, the last one at the end
+	undo, redo map[string][]Operation
+	m          sync.Mutex
+}
+
+func newUndoStack(depth int) *undoStack {
+	return &undoStack{
+		depth: depth,
+		undo:  make(map[string][]Operation),
+		redo:  make(map[string][]Operation),
+	}
+}
+
+func (u *undoStack) stack(redo bool) map[string][]Operation {
+	if redo {
+		return u.redo
+	}
+	return u.undo
+}
+
+// push records a new operation of the client. The operations undone before cannot be redone anymore.
+func (u *undoStack) push(client string, op Operation) {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	u.add(u.undo, client, op)
+	delete(u.redo, client)
+}
+
+// put adds the operation on top of the undo or redo stack of the client
+func (u *undoStack) put(redo bool, client string, op Operation) {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	u.add(u.stack(redo), client, op)
+}
+
+// add drops the oldest operation when the stack is full. The lock must be held.
+func (u *undoStack) add(stacks map[string][]Operation, client string, op Operation) {
+	ops := append(stacks[client], op)
+	if len(ops) > u.depth {
+		ops = ops[len(ops)-u.depth:]
+	}
+	stacks[client] = ops
+}
+
+// take removes the last operation of the client from the undo or redo stack.
+// With an id it is the last operation that changed that todo.
+func (u *undoStack) take(redo bool, client, id string) (Operation, bool) {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	stacks := u.stack(redo)
+	ops := stacks[client]
+	for i := len(ops) - 1; i >= 0; i-- {
+		if id != "" && !ops[i].changes(id) {
+			continue
+		}
+
+		op := ops[i]
+		stacks[client] = append(ops[:i:i], ops[i+1:]...)
+		if len(stacks[client]) == 0 {
+			delete(stacks, client)
+		}
+		return op, true
+	}
+
+	return Operation{}, false
+}
+
+// renumber gives the version `to` to the references of the client to the state of the todo,
+// after an operation was reverted to that state with a new version
+func (u *undoStack) renumber(client string, state Todo, to int64) {
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	same := func(t *Todo) bool {
+		return t != nil && t.ID == state.ID && t.Version == state.Version && (t.DeletedAt == nil) == (state.DeletedAt == nil)
+	}
+
+	for _, stacks := range []map[string][]Operation{u.undo, u.redo} {
+		for _, op := range stacks[client] {
+			for i := range op.Revisions {
+				for _, t := range []*Todo{op.Revisions[i].Before, op.Revisions[i].After} {
+					if same(t) {
+						t.Version = to
+					}
+				}
+			}
+		}
+	}
+}
+
+func (o Operation) changes(id string) bool {
+	return o.revision(id) != nil
+}
+
+func (o Operation) revision(id string) *Revision {
+	for i := range o.Revisions {
+		if o.Revisions[i].ID == id {
+			return &o.Revisions[i]
+		}
+	}
+	return nil
+}
+
+type clientCtxKey struct{}
+
+// WithClient returns a context whose operations are undone separately from those of the other clients
+func WithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientCtxKey{}, client)
+}
+
+// ClientFrom returns the client of the context, empty if it has none
+func ClientFrom(ctx context.Context) string {
+	client, _ := ctx.Value(clientCtxKey{}).(string)
+	return client
+}
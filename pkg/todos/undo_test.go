@@ -0,0 +1,116 @@
+package todos
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	ctx := WithClient(context.Background(), "test")
+	svc := NewService(WithRepo(NewInMemoryRepository()), WithUndo(10))
+
+	td, err := svc.Add(ctx, Todo{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	td.Title = "second"
+	if td, err = svc.Update(ctx, td.ID, td); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.MarkCompleted(ctx, td, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Undo(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Undo(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := svc.FindByID(ctx, td.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "first" || got.CompletedAt != nil {
+		t.Fatalf("wrong todo after undo: %+v", got)
+	}
+
+	if _, err := svc.Redo(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ = svc.FindByID(ctx, td.ID); got.Title != "second" || got.CompletedAt != nil {
+		t.Fatalf("wrong todo after redo: %+v", got)
+	}
+
+	// other clients have their own stack
+	if _, err := svc.Undo(WithClient(ctx, "other"), ""); !errors.As(err, new(ErrNothingToUndo)) {
+		t.Fatalf("expected nothing to undo, got: %v", err)
+	}
+
+	// undoing the update again and then the add removes the todo
+	for i := 0; i < 2; i++ {
+		if _, err := svc.Undo(ctx, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := svc.FindByID(ctx, td.ID); err == nil {
+		t.Fatal("todo still found after undoing the add")
+	}
+}
+
+func TestUndoDeleteRestoresSubtasks(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(WithRepo(NewInMemoryRepository()), WithUndo(10))
+
+	parent, err := svc.Add(ctx, Todo{Title: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := svc.Add(ctx, Todo{Title: "child", ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Delete(ctx, parent.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Undo(ctx, parent.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{parent.ID, child.ID} {
+		if _, err := svc.FindByID(ctx, id); err != nil {
+			t.Fatalf("todo %s not restored: %v", id, err)
+		}
+	}
+}
+
+func TestUndoConflict(t *testing.T) {
+	ctx := WithClient(context.Background(), "a")
+	svc := NewService(WithRepo(NewInMemoryRepository()), WithUndo(10))
+
+	td, err := svc.Add(ctx, Todo{Title: "shared"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	td.Title = "changed by a"
+	if td, err = svc.Update(ctx, td.ID, td); err != nil {
+		t.Fatal(err)
+	}
+
+	td.Title = "changed by b"
+	if _, err := svc.Update(WithClient(ctx, "b"), td.ID, td); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Undo(ctx, ""); !errors.As(err, new(ErrVersionMismatch)) {
+		t.Fatalf("expected a version mismatch, got: %v", err)
+	}
+
+	got, _ := svc.FindByID(ctx, td.ID)
+	if got.Title != "changed by b" {
+		t.Fatalf("the change of b was undone: %+v", got)
+	}
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestAttachments(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "with a screenshot"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := *apiURL + "/todos/" + td.ID + "/attachments"
+
+	png := append([]byte("\x89PNG\r\n\x1a\n"), make([]byte, 16)...)
+
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", "screen.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(png)
+	mw.Close()
+
+	resp, err := http.Post(url, mw.FormDataContentType(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("wrong status code for upload: %d", resp.StatusCode)
+	}
+	var at todos.Attachment
+	if err := json.NewDecoder(resp.Body).Decode(&at); err != nil {
+		t.Fatal(err)
+	}
+	if at.Name != "screen.bin" || at.ContentType != "image/png" || at.Size != int64(len(png)) {
+		t.Fatalf("wrong attachment: %+v", at)
+	}
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var all []todos.Attachment
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[0].ID != at.ID {
+		t.Fatalf("wrong attachments: %+v", all)
+	}
+
+	resp, err = http.Get(url + "/" + at.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !bytes.Equal(data, png) {
+		t.Fatalf("wrong download: %d %q", resp.StatusCode, data)
+	}
+	if ct := resp.Header.Get("Content-type"); ct != "image/png" {
+		t.Fatalf("wrong content type: %s", ct)
+	}
+	if cd := resp.Header.Get("Content-Disposition"); cd != "attachment; filename=screen.bin" {
+		t.Fatalf("wrong content disposition: %s", cd)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, url+"/"+at.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("wrong status code for delete: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(url + "/" + at.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("wrong status code for a deleted attachment: %d", resp.StatusCode)
+	}
+}
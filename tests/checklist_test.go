@@ -0,0 +1,71 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestChecklist(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{
+		Title:       "release",
+		Description: "Ship **it** <script>alert(1)</script>",
+		Checklist:   []todos.ChecklistItem{{Text: "tag"}, {Text: "announce"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(td.Checklist) != 2 || td.Checklist[0].ID == "" {
+		t.Fatalf("wrong checklist: %+v", td.Checklist)
+	}
+	tag, announce := td.Checklist[0].ID, td.Checklist[1].ID
+
+	td = postTodoAction(t, td.ID, "checklist/"+tag+"/toggle")
+	if !td.Checklist[0].Done || td.Checklist[1].Done {
+		t.Fatalf("wrong checklist after toggle: %+v", td.Checklist)
+	}
+
+	b, _ := json.Marshal(map[string][]string{"ids": {announce, tag}})
+	req, err := http.NewRequest(http.MethodPut, *apiURL+"/todos/"+td.ID+"/checklist/order", bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&td); err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if td.Checklist[0].ID != announce || td.Checklist[1].ID != tag {
+		t.Fatalf("wrong checklist after reorder: %+v", td.Checklist)
+	}
+
+	resp, err = http.Get(*apiURL + "/todos/" + td.ID + "?render=html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-type"); !strings.HasPrefix(ct, "text/html") {
+		t.Fatalf("wrong content type: %s", ct)
+	}
+	html, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "<strong>it</strong>") || strings.Contains(string(html), "<script") {
+		t.Fatalf("wrong HTML: %s", html)
+	}
+}
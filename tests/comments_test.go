@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestComments(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "discussed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := *apiURL + "/todos/" + td.ID + "/comments"
+
+	status, cm := sendComment(t, http.MethodPost, url, "looks good")
+	if status != http.StatusCreated {
+		t.Fatalf("wrong status code for new comment: %d", status)
+	}
+	if cm.Author != "carol" || cm.Body != "looks good" {
+		t.Fatalf("wrong new comment: %+v", cm)
+	}
+
+	status, cm = sendComment(t, http.MethodPut, url+"/"+cm.ID, "looks great")
+	if status != http.StatusOK {
+		t.Fatalf("wrong status code for edit: %d", status)
+	}
+	if cm.UpdatedAt == nil || len(cm.Edits) != 1 || cm.Edits[0].Body != "looks good" {
+		t.Fatalf("wrong edited comment: %+v", cm)
+	}
+
+	if status, _ := sendComment(t, http.MethodPost, url, "  "); status != http.StatusUnprocessableEntity {
+		t.Fatalf("wrong status code for empty comment: %d", status)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, url+"/"+cm.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("wrong status code for delete: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var all []todos.Comment
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 0 {
+		t.Fatalf("deleted comment still listed: %+v", all)
+	}
+}
+
+func sendComment(t *testing.T, method, url, body string) (int, todos.Comment) {
+	t.Helper()
+
+	b, _ := json.Marshal(map[string]string{"body": body})
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+	req.Header.Set("X-Actor", "carol")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var cm todos.Comment
+	if resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(&cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode, cm
+}
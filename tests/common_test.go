@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/mehix/go-todos/pkg/todos"
@@ -84,3 +85,29 @@ var byTags = func(tags []string) (int, error) {
 
 	return len(all), nil
 }
+
+var searchTags = func(params url.Values) (int, error) {
+	req, err := http.NewRequest(http.MethodGet, *apiURL+"/todos/search/tags", nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-type", "application/json")
+	req.URL.RawQuery = params.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("search failed. Response: %d", resp.StatusCode)
+	}
+
+	var all []todos.Todo
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		return 0, err
+	}
+
+	return len(all), nil
+}
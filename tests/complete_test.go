@@ -43,3 +43,75 @@ func TestCompleteTodo(t *testing.T) {
 		t.Fatalf("todo not completed. CompletedAt is still nil")
 	}
 }
+
+func TestReopenTodo(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "reopened todo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(action string) todos.Todo {
+		req, err := http.NewRequest(http.MethodPost, *apiURL+"/todos/"+td.ID+"/"+action, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var changed todos.Todo
+		if err := json.NewDecoder(resp.Body).Decode(&changed); err != nil {
+			t.Fatal(err)
+		}
+		return changed
+	}
+
+	isListed := func(list string) bool {
+		req, err := http.NewRequest(http.MethodGet, *apiURL+"/todos/"+list, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-type", "application/json")
+		q := req.URL.Query()
+		q.Set("filter", `title:"reopened todo"`)
+		req.URL.RawQuery = q.Encode()
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		var all []todos.Todo
+		if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+			t.Fatal(err)
+		}
+		for _, o := range all {
+			if o.ID == td.ID {
+				return true
+			}
+		}
+		return false
+	}
+
+	if completed := post("complete"); completed.CompletedAt == nil {
+		t.Fatalf("todo not completed. CompletedAt is still nil")
+	}
+	if !isListed("completed") || isListed("open") {
+		t.Fatalf("completed todo is not listed as completed")
+	}
+
+	if reopened := post("reopen"); reopened.CompletedAt != nil {
+		t.Fatalf("todo not reopened. CompletedAt: %v", *reopened.CompletedAt)
+	}
+	if isListed("completed") || !isListed("open") {
+		t.Fatalf("reopened todo is not listed as open")
+	}
+}
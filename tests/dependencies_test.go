@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+	"golang.org/x/exp/slices"
+)
+
+func TestCompleteBlockedTodo(t *testing.T) {
+
+	ctx := context.Background()
+
+	blocker, err := addTodo(ctx, todos.Todo{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked, err := addTodo(ctx, todos.Todo{Title: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := postDependency(t, blocked.ID, blocker.ID); status != http.StatusOK {
+		t.Fatalf("wrong status code adding a dependency. expected: %d, got: %d", http.StatusOK, status)
+	}
+	if status := postDependency(t, blocker.ID, blocked.ID); status != http.StatusConflict {
+		t.Fatalf("wrong status code for a cycle. expected: %d, got: %d", http.StatusConflict, status)
+	}
+
+	if status := complete(t, blocked.ID, ""); status != http.StatusConflict {
+		t.Fatalf("blocked todo completed. status: %d", status)
+	}
+	if status := complete(t, blocker.ID, ""); status != http.StatusOK {
+		t.Fatalf("wrong status code completing the blocker: %d", status)
+	}
+	if status := complete(t, blocked.ID, ""); status != http.StatusOK {
+		t.Fatalf("wrong status code completing after the blocker: %d", status)
+	}
+}
+
+func TestForceCompleteBlockedTodo(t *testing.T) {
+
+	ctx := context.Background()
+
+	blocker, err := addTodo(ctx, todos.Todo{Title: "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocked, err := addTodo(ctx, todos.Todo{Title: "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := postDependency(t, blocked.ID, blocker.ID); status != http.StatusOK {
+		t.Fatalf("wrong status code adding a dependency: %d", status)
+	}
+	if status := complete(t, blocked.ID, "?force=true"); status != http.StatusOK {
+		t.Fatalf("wrong status code forcing completion: %d", status)
+	}
+}
+
+func TestCompleteTreeWithBlockedSubtask(t *testing.T) {
+
+	ctx := context.Background()
+
+	parent, err := addTodo(ctx, todos.Todo{Title: "move house"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	boxes, err := addTodo(ctx, todos.Todo{Title: "pack the boxes", ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	van, err := addTodo(ctx, todos.Todo{Title: "load the van", ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := addTodo(ctx, todos.Todo{Title: "get the keys"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a blocker in the same tree is completed with it
+	if status := postDependency(t, van.ID, boxes.ID); status != http.StatusOK {
+		t.Fatalf("wrong status code adding a dependency: %d", status)
+	}
+	if status := postDependency(t, van.ID, keys.ID); status != http.StatusOK {
+		t.Fatalf("wrong status code adding a dependency: %d", status)
+	}
+
+	if status := complete(t, parent.ID, "?descendants=true"); status != http.StatusConflict {
+		t.Fatalf("tree with a blocked subtask completed. status: %d", status)
+	}
+	if tree := getTree(t, parent.ID); tree.CompletedAt != nil || tree.Progress == nil || tree.Progress.Completed != 0 {
+		t.Fatalf("todo's completed by a rejected request: %+v", tree.Progress)
+	}
+
+	if status := complete(t, keys.ID, ""); status != http.StatusOK {
+		t.Fatalf("wrong status code completing the blocker: %d", status)
+	}
+	if status := complete(t, parent.ID, "?descendants=true"); status != http.StatusOK {
+		t.Fatalf("wrong status code completing the tree: %d", status)
+	}
+}
+
+func TestForceCompleteTreeWithBlockedSubtask(t *testing.T) {
+
+	ctx := context.Background()
+
+	parent, err := addTodo(ctx, todos.Todo{Title: "move house"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	van, err := addTodo(ctx, todos.Todo{Title: "load the van", ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := addTodo(ctx, todos.Todo{Title: "get the keys"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := postDependency(t, van.ID, keys.ID); status != http.StatusOK {
+		t.Fatalf("wrong status code adding a dependency: %d", status)
+	}
+	if status := complete(t, parent.ID, "?descendants=true&force=true"); status != http.StatusOK {
+		t.Fatalf("wrong status code forcing completion of the tree: %d", status)
+	}
+	if tree := getTree(t, parent.ID); tree.Progress == nil || tree.Progress.Percent != 100 {
+		t.Fatalf("subtasks not completed: %+v", tree.Progress)
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+
+	ctx := context.Background()
+
+	var ids []string
+	for _, title := range []string{"a", "b", "c"} {
+		td, err := addTodo(ctx, todos.Todo{Title: title})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, td.ID)
+	}
+
+	// a blocks b, b blocks c
+	postDependency(t, ids[1], ids[0])
+	postDependency(t, ids[2], ids[1])
+
+	resp, err := http.Get(*apiURL + "/todos/" + ids[1] + "/graph")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var g todos.Graph
+	if err := json.NewDecoder(resp.Body).Decode(&g); err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(g.Order, ids) {
+		t.Fatalf("wrong completion order. expected: %v, got: %v", ids, g.Order)
+	}
+	if len(g.Todos) != 3 || len(g.Dependencies) != 2 {
+		t.Fatalf("wrong graph: %+v", g)
+	}
+}
+
+func postDependency(t *testing.T, id, blocker string) int {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if err := json.NewEncoder(&payload).Encode(map[string]string{"id": blocker}); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *apiURL+"/todos/"+id+"/dependencies", &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func complete(t *testing.T, id, params string) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, *apiURL+"/todos/"+id+"/complete"+params, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
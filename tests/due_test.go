@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestOverdueTodo(t *testing.T) {
+
+	ctx := context.Background()
+
+	dueAt := time.Now().Add(-time.Hour).In(time.FixedZone("", 2*60*60)).Truncate(time.Second)
+
+	td, err := addTodo(ctx, todos.Todo{Title: "overdue todo", DueAt: &dueAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if td.DueAt == nil || !td.DueAt.Equal(dueAt) {
+		t.Fatalf("wrong due_at. expected: %v, got: %v", dueAt, td.DueAt)
+	}
+	if _, offset := td.DueAt.Zone(); offset != 2*60*60 {
+		t.Fatalf("due_at lost its timezone. got offset: %d", offset)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, *apiURL+"/todos/overdue", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var overdue []todos.Todo
+	if err := json.NewDecoder(resp.Body).Decode(&overdue); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range overdue {
+		if o.ID == td.ID {
+			return
+		}
+	}
+	t.Fatalf("todo %s not in the overdue list", td.ID)
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestETagPreconditions(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "versioned todo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	do := func(method, body string, header http.Header) *http.Response {
+		req, err := http.NewRequest(method, *apiURL+"/todos/"+td.ID, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header = header
+		req.Header.Set("Content-type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		return resp
+	}
+
+	resp := do(http.MethodGet, "", http.Header{})
+	tag := resp.Header.Get("ETag")
+	if tag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	if resp := do(http.MethodGet, "", http.Header{"If-None-Match": {tag}}); resp.StatusCode != http.StatusNotModified {
+		t.Fatalf("wrong status code for a matching If-None-Match. expected: %d, got: %d", http.StatusNotModified, resp.StatusCode)
+	}
+
+	resp = do(http.MethodPut, `{"title": "first change"}`, http.Header{"If-Match": {tag}})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code for a matching If-Match. expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+	if resp.Header.Get("ETag") == tag {
+		t.Fatal("ETag did not change after update")
+	}
+
+	// a second client still has the first version
+	for _, method := range []string{http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		resp := do(method, `{"title": "lost change"}`, http.Header{"If-Match": {tag}})
+		if resp.StatusCode != http.StatusPreconditionFailed {
+			t.Fatalf("wrong status code for %s with an old If-Match. expected: %d, got: %d", method, http.StatusPreconditionFailed, resp.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestFilterTodos(t *testing.T) {
+
+	ctx := context.Background()
+
+	tag := uuid.NewString()
+
+	want, err := addTodo(ctx, todos.Todo{Title: "Send the invoice", Tags: []string{tag, "work"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addTodo(ctx, todos.Todo{Title: "Pay the invoice", Tags: []string{tag, "blocked"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, *apiURL+"/todos/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+	q := req.URL.Query()
+	q.Set("filter", `tag:`+tag+` AND NOT tag:blocked AND completed:false AND title~"invoice"`)
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var found []todos.Todo
+	if err := json.NewDecoder(resp.Body).Decode(&found); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 1 || found[0].ID != want.ID {
+		t.Fatalf("wrong filter result. expected: [%s], got: %v", want.ID, found)
+	}
+}
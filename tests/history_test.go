@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestHistoryOfChanges(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "tracked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td.Title = "tracked and renamed"
+	b, _ := json.Marshal(td)
+	req, err := http.NewRequest(http.MethodPut, *apiURL+"/todos/"+td.ID, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+	req.Header.Set("X-Actor", "bob")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code after update: %d", resp.StatusCode)
+	}
+
+	resp, err = http.Get(*apiURL + "/todos/" + td.ID + "/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code for history: %d", resp.StatusCode)
+	}
+
+	var events []todos.Event
+	if err := json.NewDecoder(resp.Body).Decode(&events); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 2 || events[0].Action != todos.ActionCreate || events[1].Action != todos.ActionUpdate {
+		t.Fatalf("wrong history: %+v", events)
+	}
+
+	update := events[1]
+	if update.Actor != "bob" || len(update.Changes) != 1 || update.Changes[0].Field != "title" {
+		t.Fatalf("wrong update event: %+v", update)
+	}
+}
+
+func TestHistoryOfMissingTodo(t *testing.T) {
+
+	resp, err := http.Get(*apiURL + "/trash/" + uuid.NewString() + "/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("wrong status code for history: %d", resp.StatusCode)
+	}
+}
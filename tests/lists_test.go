@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestMoveTodoBetweenLists(t *testing.T) {
+
+	ctx := context.Background()
+
+	home, err := addList(ctx, "home "+uuid.NewString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	work, err := addList(ctx, "work "+uuid.NewString())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := addTodo(ctx, todos.Todo{Title: "in a list", ListID: home.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload bytes.Buffer
+	if err := json.NewEncoder(&payload).Encode(map[string]string{"list_id": work.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *apiURL+"/todos/"+td.ID+"/move", &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code after move. expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	if n, err := countListTodos(home.ID); err != nil || n != 0 {
+		t.Fatalf("todo still in the old list: %d, %v", n, err)
+	}
+	if n, err := countListTodos(work.ID); err != nil || n != 1 {
+		t.Fatalf("todo not in the new list: %d, %v", n, err)
+	}
+}
+
+func TestDeleteListCascade(t *testing.T) {
+
+	ctx := context.Background()
+
+	l, err := addList(ctx, "cascade "+uuid.NewString())
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := addTodo(ctx, todos.Todo{Title: "deleted with the list", ListID: l.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without cascade the list is archived and keeps its todos
+	if status, err := deleteList(l.ID, false); err != nil || status != http.StatusNoContent {
+		t.Fatalf("list not archived: %d, %v", status, err)
+	}
+	if n, err := countListTodos(l.ID); err != nil || n != 1 {
+		t.Fatalf("archived list lost its todos: %d, %v", n, err)
+	}
+
+	if status, err := deleteList(l.ID, true); err != nil || status != http.StatusNoContent {
+		t.Fatalf("list not deleted: %d, %v", status, err)
+	}
+
+	resp, err := http.Get(*apiURL + "/todos/" + td.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("todo of a deleted list still found. status: %d", resp.StatusCode)
+	}
+}
+
+func TestAddTodoToMissingList(t *testing.T) {
+	if _, err := addTodo(context.Background(), todos.Todo{Title: "no list", ListID: uuid.NewString()}); err == nil {
+		t.Fatal("todo added to a list that does not exist")
+	}
+}
+
+func addList(ctx context.Context, name string) (todos.List, error) {
+	var payload bytes.Buffer
+	if err := json.NewEncoder(&payload).Encode(todos.List{Name: name}); err != nil {
+		return todos.List{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *apiURL+"/lists/", &payload)
+	if err != nil {
+		return todos.List{}, err
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return todos.List{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return todos.List{}, fmt.Errorf("list not created. Response: %d", resp.StatusCode)
+	}
+
+	var l todos.List
+	err = json.NewDecoder(resp.Body).Decode(&l)
+
+	return l, err
+}
+
+func deleteList(id string, cascade bool) (int, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/lists/%s?cascade=%t", *apiURL, id, cascade), nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode, nil
+}
+
+func countListTodos(id string) (int, error) {
+	resp, err := http.Get(*apiURL + "/lists/" + id + "/todos")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("listing todos failed. Response: %d", resp.StatusCode)
+	}
+
+	var all []todos.Todo
+	err = json.NewDecoder(resp.Body).Decode(&all)
+
+	return len(all), err
+}
@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+var nextLink = regexp.MustCompile(`<([^>]+)>; rel="next"`)
+
+func TestPaginateByTag(t *testing.T) {
+
+	ctx := context.Background()
+
+	tag := uuid.NewString()
+	for i := 0; i < 5; i++ {
+		if _, err := addTodo(ctx, todos.Todo{Title: "paged todo", Tags: []string{tag}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := make(map[string]bool)
+	next := "/todos/search/tags?limit=2&sort=title&q=" + tag
+	pages := 0
+	for next != "" {
+		pages++
+
+		req, err := http.NewRequest(http.MethodGet, *apiURL+next, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-type", "application/json")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var page []todos.Todo
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, td := range page {
+			if seen[td.ID] {
+				t.Fatalf("todo %s returned twice", td.ID)
+			}
+			seen[td.ID] = true
+		}
+
+		next = ""
+		if m := nextLink.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+			next = m[1]
+		}
+	}
+
+	if pages != 3 || len(seen) != 5 {
+		t.Fatalf("wrong pagination. expected: 5 todos in 3 pages, got: %d todos in %d pages", len(seen), pages)
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestPatchTodo(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "old title", Tags: []string{"tag1", "tag2"}, Priority: todos.PriorityLow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patch := func(contentType, body string) todos.Todo {
+		req, err := http.NewRequest(http.MethodPatch, *apiURL+"/todos/"+td.ID, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-type", contentType)
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("wrong status code after patch. expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+		}
+
+		var patched todos.Todo
+		if err := json.NewDecoder(resp.Body).Decode(&patched); err != nil {
+			t.Fatal(err)
+		}
+		return patched
+	}
+
+	patched := patch("application/merge-patch+json", `{"title": "new title"}`)
+	if patched.Title != "new title" || len(patched.Tags) != 2 || patched.Priority != todos.PriorityLow {
+		t.Fatalf("merge patch changed more than the title: %+v", patched)
+	}
+
+	// the fields managed by the server cannot be patched
+	patched = patch("application/merge-patch+json", `{"series_id": "zzz", "occurrence": 7, "deleted_at": "2020-01-01T00:00:00Z"}`)
+	if patched.SeriesID != "" || patched.Occurrence != 0 || patched.DeletedAt != nil {
+		t.Fatalf("server fields were patched: %+v", patched)
+	}
+
+	patched = patch("application/json-patch+json", `[{"op": "remove", "path": "/tags/0"}, {"op": "replace", "path": "/priority", "value": "high"}]`)
+	if patched.Title != "new title" || len(patched.Tags) != 1 || patched.Tags[0] != "tag2" || patched.Priority != todos.PriorityHigh {
+		t.Fatalf("wrong todo after json patch: %+v", patched)
+	}
+}
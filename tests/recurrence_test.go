@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestCompleteRecurringTodo(t *testing.T) {
+
+	ctx := context.Background()
+
+	due := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	rule, err := todos.ParseRecurrence("FREQ=DAILY;COUNT=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := addTodo(ctx, todos.Todo{Title: "water the plants", DueAt: &due, Recurrence: &rule})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.SeriesID != first.ID || first.Occurrence != 1 {
+		t.Fatalf("wrong series of a new recurring todo: %s, %d", first.SeriesID, first.Occurrence)
+	}
+
+	if status := complete(t, first.ID, ""); status != http.StatusOK {
+		t.Fatalf("wrong status code completing: %d", status)
+	}
+
+	series := findSeries(t, first.ID)
+	if len(series) != 2 {
+		t.Fatalf("next occurrence not added. got %d todos in the series", len(series))
+	}
+
+	var second todos.Todo
+	for _, td := range series {
+		if td.ID != first.ID {
+			second = td
+		}
+	}
+	if second.Occurrence != 2 || second.DueAt == nil || !second.DueAt.Equal(due.AddDate(0, 0, 1)) {
+		t.Fatalf("wrong next occurrence: %+v", second)
+	}
+
+	// COUNT=2 ends the series with the second occurrence
+	if status := complete(t, second.ID, ""); status != http.StatusOK {
+		t.Fatalf("wrong status code completing: %d", status)
+	}
+	if n := len(findSeries(t, first.ID)); n != 2 {
+		t.Fatalf("occurrence added after the end of the series. got %d todos", n)
+	}
+}
+
+func TestSkipOccurrence(t *testing.T) {
+
+	ctx := context.Background()
+
+	due := time.Date(2030, 1, 7, 10, 0, 0, 0, time.UTC)
+	rule, err := todos.ParseRecurrence("FREQ=WEEKLY;BYDAY=MO,FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	td, err := addTodo(ctx, todos.Todo{Title: "standup notes", DueAt: &due, Recurrence: &rule})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	skipped := postTodoAction(t, td.ID, "skip")
+	if skipped.DueAt == nil || !skipped.DueAt.Equal(time.Date(2030, 1, 11, 10, 0, 0, 0, time.UTC)) || skipped.Occurrence != 2 {
+		t.Fatalf("wrong skipped occurrence: %+v", skipped)
+	}
+
+	ended := postTodoAction(t, td.ID, "end-series")
+	if ended.Recurrence != nil || ended.SeriesID != td.ID {
+		t.Fatalf("series not ended: %+v", ended)
+	}
+}
+
+func TestCompleteTreeWithRecurringSubtask(t *testing.T) {
+
+	ctx := context.Background()
+
+	due := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	rule, err := todos.ParseRecurrence("FREQ=DAILY;COUNT=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, err := addTodo(ctx, todos.Todo{Title: "garden"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := addTodo(ctx, todos.Todo{Title: "water the plants", DueAt: &due, Recurrence: &rule, ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status := complete(t, parent.ID, "?descendants=true"); status != http.StatusOK {
+		t.Fatalf("wrong status code completing the tree: %d", status)
+	}
+	if n := len(findSeries(t, sub.ID)); n != 2 {
+		t.Fatalf("next occurrence of the subtask not added. got %d todos in the series", n)
+	}
+}
+
+func findSeries(t *testing.T, id string) []todos.Todo {
+	t.Helper()
+
+	resp, err := http.Get(*apiURL + "/todos/?filter=" + url.QueryEscape("series:"+id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var all []todos.Todo
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		t.Fatal(err)
+	}
+
+	return all
+}
+
+func postTodoAction(t *testing.T, id, action string) todos.Todo {
+	t.Helper()
+
+	resp, err := http.Post(*apiURL+"/todos/"+id+"/"+action, "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code for %s: %d", action, resp.StatusCode)
+	}
+
+	var td todos.Todo
+	if err := json.NewDecoder(resp.Body).Decode(&td); err != nil {
+		t.Fatal(err)
+	}
+
+	return td
+}
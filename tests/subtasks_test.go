@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestCompleteWithDescendants(t *testing.T) {
+
+	ctx := context.Background()
+
+	parent, err := addTodo(ctx, todos.Todo{Title: "big item"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, err := addTodo(ctx, todos.Todo{Title: "step", ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := addTodo(ctx, todos.Todo{Title: "sub step", ParentID: step.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	tree := getTree(t, parent.ID)
+	if tree.Progress == nil || tree.Progress.Completed != 0 || tree.Progress.Total != 1 {
+		t.Fatalf("wrong progress before completing: %+v", tree.Progress)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, *apiURL+"/todos/"+parent.ID+"/complete?descendants=true", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code after complete. expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	tree = getTree(t, parent.ID)
+	if tree.Progress == nil || tree.Progress.Percent != 100 {
+		t.Fatalf("subtasks not completed: %+v", tree.Progress)
+	}
+	if len(tree.Children) != 1 || tree.Children[0].CompletedAt == nil {
+		t.Fatalf("wrong children: %+v", tree.Children)
+	}
+}
+
+func TestMoveUnderSubtaskIsRejected(t *testing.T) {
+
+	ctx := context.Background()
+
+	parent, err := addTodo(ctx, todos.Todo{Title: "parent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := addTodo(ctx, todos.Todo{Title: "child", ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var payload bytes.Buffer
+	if err := json.NewEncoder(&payload).Encode(map[string]string{"parent_id": child.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, *apiURL+"/todos/"+parent.ID, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/merge-patch+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnprocessableEntity {
+		t.Fatalf("wrong status code for a cycle. expected: %d, got: %d", http.StatusUnprocessableEntity, resp.StatusCode)
+	}
+}
+
+func getTree(t *testing.T, id string) todos.Tree {
+	t.Helper()
+
+	resp, err := http.Get(*apiURL + "/todos/" + id + "?tree=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var tree todos.Tree
+	if err := json.NewDecoder(resp.Body).Decode(&tree); err != nil {
+		t.Fatal(err)
+	}
+
+	return tree
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestRenameTag(t *testing.T) {
+
+	ctx := context.Background()
+
+	from, to := uuid.NewString(), "renamed, "+uuid.NewString()
+
+	if _, err := addTodo(ctx, todos.Todo{Title: "tagged", Tags: []string{from}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload bytes.Buffer
+	if err := json.NewEncoder(&payload).Encode(map[string]string{"name": to}); err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodPut, *apiURL+"/tags/"+url.PathEscape(from), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code after rename. expected: %d, got: %d", http.StatusOK, resp.StatusCode)
+	}
+
+	var renamed todos.TagCount
+	if err := json.NewDecoder(resp.Body).Decode(&renamed); err != nil {
+		t.Fatal(err)
+	}
+	if renamed.Name != to || renamed.Open != 1 {
+		t.Fatalf("wrong renamed tag: %+v", renamed)
+	}
+
+	tags, err := listTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tg := range tags {
+		if tg.Name == from {
+			t.Fatalf("tag %s still listed after rename", from)
+		}
+	}
+}
+
+func listTags() ([]todos.TagCount, error) {
+	req, err := http.NewRequest(http.MethodGet, *apiURL+"/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var all []todos.TagCount
+	err = json.NewDecoder(resp.Body).Decode(&all)
+
+	return all, err
+}
@@ -2,6 +2,7 @@ package tests
 
 import (
 	"context"
+	"net/url"
 	"testing"
 
 	"github.com/google/uuid"
@@ -48,3 +49,34 @@ func TestSearchByTags(t *testing.T) {
 		t.Fatalf("wrong number of results. expected: %d, got: %d", 3, withAllTags)
 	}
 }
+
+func TestSearchByAllAndNoneTags(t *testing.T) {
+
+	ctx := context.Background()
+
+	work, urgent, waiting := uuid.NewString(), uuid.NewString(), uuid.NewString()
+
+	addTodo(ctx, todos.Todo{Title: "work", Tags: []string{work}})
+	addTodo(ctx, todos.Todo{Title: "urgent work", Tags: []string{work, urgent}})
+	addTodo(ctx, todos.Todo{Title: "urgent work, waiting", Tags: []string{work, urgent, waiting}})
+
+	tests := []struct {
+		params url.Values
+		count  int
+	}{
+		{url.Values{"q": {work + "," + urgent}}, 3},
+		{url.Values{"q": {work + "," + urgent}, "mode": {"all"}}, 2},
+		{url.Values{"all": {work + "," + urgent}, "none": {waiting}}, 1},
+		{url.Values{"q": {work}, "none": {urgent}}, 1},
+	}
+
+	for _, tt := range tests {
+		count, err := searchTags(tt.params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != tt.count {
+			t.Fatalf("wrong number of results for %v. expected: %d, got: %d", tt.params, tt.count, count)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestDeleteAndRestore(t *testing.T) {
+
+	ctx := context.Background()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "deleted by accident"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, *apiURL+"/todos/"+td.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("wrong status code after delete: %d", resp.StatusCode)
+	}
+
+	if !inTrash(t, td.ID) {
+		t.Fatal("deleted todo not in the trash")
+	}
+
+	resp, err = http.Post(*apiURL+"/trash/"+td.ID+"/restore", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("wrong status code after restore: %d", resp.StatusCode)
+	}
+
+	var restored todos.Todo
+	if err := json.NewDecoder(resp.Body).Decode(&restored); err != nil {
+		t.Fatal(err)
+	}
+	if restored.ID != td.ID || restored.DeletedAt != nil {
+		t.Fatalf("wrong restored todo: %+v", restored)
+	}
+
+	if inTrash(t, td.ID) {
+		t.Fatal("restored todo still in the trash")
+	}
+}
+
+func inTrash(t *testing.T, id string) bool {
+	t.Helper()
+
+	resp, err := http.Get(*apiURL + "/trash/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var all []todos.Todo
+	if err := json.NewDecoder(resp.Body).Decode(&all); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, td := range all {
+		if td.ID == id {
+			return true
+		}
+	}
+	return false
+}
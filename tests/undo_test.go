@@ -0,0 +1,84 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mehix/go-todos/pkg/todos"
+)
+
+func TestUndoDelete(t *testing.T) {
+
+	ctx := context.Background()
+	client := uuid.NewString()
+
+	td, err := addTodo(ctx, todos.Todo{Title: "deleted and undone"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, *apiURL+"/todos/"+td.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Client-ID", client)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("wrong status code after delete: %d", resp.StatusCode)
+	}
+
+	// the other clients cannot undo the delete
+	if status, _ := postUndo(t, "/todos/"+td.ID+"/undo", uuid.NewString()); status != http.StatusConflict {
+		t.Fatalf("wrong status code for undo of another client: %d", status)
+	}
+
+	status, op := postUndo(t, "/todos/"+td.ID+"/undo", client)
+	if status != http.StatusOK {
+		t.Fatalf("wrong status code for undo: %d", status)
+	}
+	if op.Action != todos.ActionDelete || len(op.Revisions) != 1 || op.Revisions[0].After.DeletedAt != nil {
+		t.Fatalf("wrong undone operation: %+v", op)
+	}
+	if inTrash(t, td.ID) {
+		t.Fatal("undone delete still in the trash")
+	}
+
+	if status, _ := postUndo(t, "/redo", client); status != http.StatusOK {
+		t.Fatalf("wrong status code for redo: %d", status)
+	}
+	if !inTrash(t, td.ID) {
+		t.Fatal("redone delete not in the trash")
+	}
+}
+
+func postUndo(t *testing.T, path, client string) (int, todos.Operation) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, *apiURL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Client-ID", client)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var op todos.Operation
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode, op
+}